@@ -1,10 +1,22 @@
 // Command shortener предоставляет HTTP-сервер для сокращения URL-адресов.
+//
+// Без подкоманды запускается сервер. Поддерживаемые подкоманды:
+//
+//	shortener migrate [flags] up|down|status  # управление миграциями базы данных
+//	shortener export [flags] [file]           # выгрузка всех URL в NDJSON или CSV
+//	shortener import [flags] [file]           # загрузка URL из выгрузки
+//
+// Без файла export пишет в stdout, а import читает stdin.
+// Хранилище выбирается теми же флагами и переменными окружения, что и у сервера.
 package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log"
+	"os"
+	"strings"
 
 	"github.com/Eorthus/shorturl/internal/app"
 	"github.com/Eorthus/shorturl/internal/config"
@@ -19,23 +31,57 @@ var (
 )
 
 func main() {
-	// Вывод информации о сборке
-	printBuildInfo()
-
 	// Инициализация логгера
 	logger, _ := zap.NewProduction()
 	defer func() {
 		if err := logger.Sync(); err != nil {
-			fmt.Printf("Warning: error syncing logger: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Warning: error syncing logger: %v\n", err)
 		}
 	}()
 
+	// Подкоманда передается первым аргументом, флаги разбираются после нее
+	command := ""
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		command = os.Args[1]
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+
+	// Флаги подкоманд регистрируются до разбора общей конфигурации
+	var transfer transferOptions
+	if command == "export" || command == "import" {
+		transfer.defineFlags(flag.CommandLine)
+	}
+
 	// Загрузка конфигурации
 	cfg, err := config.LoadConfig()
 	if err != nil {
 		logger.Fatal("Failed to load config", zap.Error(err))
 	}
 
+	switch command {
+	case "":
+	case "migrate":
+		if err := runMigrate(context.Background(), cfg, flag.Args(), os.Stdout); err != nil {
+			logger.Fatal("Migration failed", zap.Error(err))
+		}
+		return
+	case "export":
+		if err := runExport(context.Background(), cfg, transfer, flag.Args(), os.Stdout, os.Stderr); err != nil {
+			logger.Fatal("Export failed", zap.Error(err))
+		}
+		return
+	case "import":
+		if err := runImport(context.Background(), cfg, transfer, flag.Args(), os.Stdin, os.Stdout); err != nil {
+			logger.Fatal("Import failed", zap.Error(err))
+		}
+		return
+	default:
+		logger.Fatal("Unknown command", zap.String("command", command))
+	}
+
+	// Вывод информации о сборке; подкоманды его не печатают, чтобы не портить вывод export
+	printBuildInfo()
+
 	// Создание приложения
 	application, err := app.New(cfg, logger)
 	if err != nil {
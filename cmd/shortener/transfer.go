@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Eorthus/shorturl/internal/config"
+	"github.com/Eorthus/shorturl/internal/dump"
+	"github.com/Eorthus/shorturl/internal/storage"
+)
+
+// transferOptions флаги подкоманд export и import
+type transferOptions struct {
+	format    string
+	resume    bool
+	dryRun    bool
+	batchSize int
+}
+
+// defineFlags регистрирует флаги подкоманд до разбора конфигурации
+func (o *transferOptions) defineFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.format, "format", "", "Dump format: ndjson or csv (default by file extension)")
+	fs.BoolVar(&o.resume, "resume", false, "Skip records that are already imported")
+	fs.BoolVar(&o.dryRun, "dry-run", false, "Validate the dump without saving records")
+	fs.IntVar(&o.batchSize, "batch-size", dump.DefaultBatchSize, "Records per import transaction")
+}
+
+// dumpFormat возвращает формат из флага или по расширению файла
+func (o *transferOptions) dumpFormat(path string) (dump.Format, error) {
+	if o.format != "" {
+		return dump.ParseFormat(o.format)
+	}
+	return dump.FormatFromPath(path), nil
+}
+
+// dumpPath возвращает путь к выгрузке; пустой путь и "-" означают стандартный поток
+func dumpPath(args []string, command string) (string, error) {
+	switch len(args) {
+	case 0:
+		return "-", nil
+	case 1:
+		return args[0], nil
+	default:
+		return "", fmt.Errorf("usage: shortener %s [flags] [file]", command)
+	}
+}
+
+// openStorage открывает хранилище из конфигурации и возвращает функцию его закрытия
+func openStorage(ctx context.Context, cfg *config.Config) (storage.Storage, func(), error) {
+	store, err := storage.InitStorage(ctx, cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	closeStore := func() {
+		if closer, ok := store.(io.Closer); ok {
+			closer.Close()
+		}
+	}
+	return store, closeStore, nil
+}
+
+// runExport выполняет подкоманду export [file]
+func runExport(ctx context.Context, cfg *config.Config, opts transferOptions, args []string, stdout, stderr io.Writer) (err error) {
+	path, err := dumpPath(args, "export")
+	if err != nil {
+		return err
+	}
+	format, err := opts.dumpFormat(path)
+	if err != nil {
+		return err
+	}
+
+	store, closeStore, err := openStorage(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	out := stdout
+	if path != "-" {
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			err = errors.Join(err, file.Close())
+		}()
+		out = file
+	}
+
+	count, err := dump.Export(ctx, store, out, format)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stderr, "exported %d records\n", count)
+	return nil
+}
+
+// runImport выполняет подкоманду import [file]
+func runImport(ctx context.Context, cfg *config.Config, opts transferOptions, args []string, stdin io.Reader, stdout io.Writer) error {
+	path, err := dumpPath(args, "import")
+	if err != nil {
+		return err
+	}
+	format, err := opts.dumpFormat(path)
+	if err != nil {
+		return err
+	}
+
+	store, closeStore, err := openStorage(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer closeStore()
+
+	in := stdin
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	stats, err := dump.Import(ctx, store, in, format, dump.ImportOptions{
+		BatchSize: opts.batchSize,
+		Resume:    opts.resume,
+		DryRun:    opts.dryRun,
+	})
+	prefix := ""
+	if opts.dryRun {
+		prefix = "dry run: "
+	}
+	fmt.Fprintf(stdout, "%sread %d, imported %d, skipped %d records\n", prefix, stats.Read, stats.Imported, stats.Skipped)
+	return err
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"expvar"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// Purger окончательно удаляет давно удаленные URL
+type Purger interface {
+	// Purge выполняет очистку и возвращает количество удаленных URL
+	Purge(ctx context.Context) (int, error)
+}
+
+// hiddenVars переменные expvar, которые не отдаются даже администратору:
+// cmdline содержит флаги запуска, в том числе токен и строку подключения к базе
+var hiddenVars = map[string]bool{"cmdline": true}
+
+// PurgeResponse ответ на запуск очистки
+type PurgeResponse struct {
+	Purged int `json:"purged"`
+}
+
+// AdminHandler обрабатывает служебные запросы операторов
+type AdminHandler struct {
+	purger Purger
+	logger *zap.Logger
+}
+
+// NewAdminHandler создает обработчик служебных запросов
+func NewAdminHandler(purger Purger, logger *zap.Logger) *AdminHandler {
+	return &AdminHandler{
+		purger: purger,
+		logger: logger,
+	}
+}
+
+// HandlePurge запускает внеплановую очистку удаленных URL
+// и возвращает количество окончательно удаленных записей
+func (h *AdminHandler) HandlePurge(w http.ResponseWriter, r *http.Request) {
+	purged, err := h.purger.Purge(r.Context())
+	if err != nil {
+		h.logger.Error("Failed to purge deleted URLs", zap.Error(err), zap.Int("purged", purged))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(PurgeResponse{Purged: purged})
+}
+
+// HandleVars отдает метрики expvar, в том числе счетчики кэша, очистки
+// и выделения идентификаторов, без аргументов командной строки
+func (h *AdminHandler) HandleVars(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	first := true
+	expvar.Do(func(kv expvar.KeyValue) {
+		if hiddenVars[kv.Key] {
+			return
+		}
+		if !first {
+			buf.WriteString(",")
+		}
+		first = false
+		key, _ := json.Marshal(kv.Key)
+		buf.Write(key)
+		buf.WriteString(":")
+		buf.WriteString(kv.Value.String())
+	})
+	buf.WriteString("}\n")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+type stubPurger struct {
+	purged int
+	err    error
+}
+
+func (p stubPurger) Purge(ctx context.Context) (int, error) {
+	return p.purged, p.err
+}
+
+func TestHandlePurge(t *testing.T) {
+	tests := []struct {
+		name           string
+		purger         stubPurger
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Успешная очистка",
+			purger:         stubPurger{purged: 42},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"purged":42}`,
+		},
+		{
+			name:           "Ошибка хранилища",
+			purger:         stubPurger{purged: 10, err: errors.New("storage failure")},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "Internal server error\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewAdminHandler(tt.purger, zaptest.NewLogger(t))
+			req := httptest.NewRequest(http.MethodPost, "/api/admin/purge", nil)
+			rr := httptest.NewRecorder()
+
+			handler.HandlePurge(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == http.StatusOK {
+				assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			} else {
+				assert.Equal(t, tt.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandleVars(t *testing.T) {
+	if expvar.Get("admin_test_counter") == nil {
+		expvar.NewInt("admin_test_counter").Set(7)
+	}
+	handler := NewAdminHandler(stubPurger{}, zaptest.NewLogger(t))
+	rr := httptest.NewRecorder()
+	handler.HandleVars(rr, httptest.NewRequest(http.MethodGet, "/api/admin/vars", nil))
+
+	require.Equal(t, http.StatusOK, rr.Code)
+	var vars map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &vars))
+	assert.JSONEq(t, "7", string(vars["admin_test_counter"]))
+	assert.Contains(t, vars, "memstats")
+	assert.NotContains(t, vars, "cmdline", "Флаги запуска содержат секреты")
+}
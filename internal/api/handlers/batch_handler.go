@@ -2,7 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/Eorthus/shorturl/internal/apperrors"
 	"github.com/Eorthus/shorturl/internal/middleware"
@@ -42,9 +45,12 @@ func (h *URLHandler) HandleBatchShorten(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(responses)
 }
 
-// HandleGetUserURLs возвращает все URL, созданные пользователем.
+// HandleGetUserURLs возвращает страницу URL, созданных пользователем.
 // Требует аутентификации пользователя.
-// Возвращает массив URLData в формате JSON.
+// Параметры запроса: limit, cursor, sort (created_at или -created_at),
+// deleted (true или false) и search (подстрока оригинального URL).
+// Возвращает массив URLData в формате JSON, ссылка на следующую
+// страницу передается в заголовке Link.
 func (h *URLHandler) HandleGetUserURLs(w http.ResponseWriter, r *http.Request) {
 	userID := middleware.GetUserID(r)
 
@@ -53,7 +59,13 @@ func (h *URLHandler) HandleGetUserURLs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	urls, err := h.urlService.GetUserURLs(r.Context(), userID)
+	query, err := parseURLQuery(r.URL.Query())
+	if err != nil {
+		apperrors.HandleHTTPError(w, err, h.logger)
+		return
+	}
+
+	page, err := h.urlService.GetUserURLsPage(r.Context(), userID, query)
 	if err != nil {
 		apperrors.HandleHTTPError(w, err, h.logger)
 		return
@@ -61,14 +73,49 @@ func (h *URLHandler) HandleGetUserURLs(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
-	if len(urls) == 0 {
+	if page.NextCursor != "" {
+		next := r.URL.Query()
+		next.Set("cursor", page.NextCursor)
+		w.Header().Set("Link", fmt.Sprintf(`<%s%s?%s>; rel="next"`, h.cfg.BaseURL, r.URL.Path, next.Encode()))
+	}
+
+	if len(page.URLs) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
+	urls := page.URLs
 	for i := range urls {
 		urls[i].ShortURL = h.cfg.BaseURL + "/" + urls[i].ShortURL
 	}
 
 	json.NewEncoder(w).Encode(urls)
 }
+
+// parseURLQuery разбирает параметры выборки URL пользователя
+func parseURLQuery(values url.Values) (models.URLQuery, error) {
+	query := models.URLQuery{
+		Cursor: values.Get("cursor"),
+		Sort:   models.SortOrder(values.Get("sort")),
+		Search: values.Get("search"),
+		Tag:    values.Get("tag"),
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return query, apperrors.ErrInvalidQuery
+		}
+		query.Limit = n
+	}
+
+	if deleted := values.Get("deleted"); deleted != "" {
+		value, err := strconv.ParseBool(deleted)
+		if err != nil {
+			return query, apperrors.ErrInvalidQuery
+		}
+		query.Deleted = &value
+	}
+
+	return query, nil
+}
@@ -2,12 +2,16 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/Eorthus/shorturl/internal/middleware"
 	"github.com/Eorthus/shorturl/internal/models"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -70,3 +74,76 @@ func TestHandleBatchShorten(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleGetUserURLs(t *testing.T) {
+	r, store := setupRouter(t)
+	userID := "pageuser"
+	for _, shortID := range []string{"u1", "u2", "u3"} {
+		require.NoError(t, store.SaveURL(context.Background(), shortID, "https://"+shortID+".example.com", userID))
+	}
+
+	get := func(t *testing.T, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.AddCookie(&http.Cookie{
+			Name:  "user_token",
+			Value: userID + ":" + middleware.GenerateSignature(userID),
+		})
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Постраничная выдача", func(t *testing.T) {
+		rr := get(t, "/api/user/urls?limit=2&sort=-created_at")
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var urls []models.URLData
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &urls))
+		require.Len(t, urls, 2)
+		assert.Equal(t, "http://localhost:8080/u3", urls[0].ShortURL)
+
+		link := rr.Header().Get("Link")
+		require.True(t, strings.HasPrefix(link, "<http://localhost:8080/api/user/urls?"), link)
+		require.True(t, strings.HasSuffix(link, `>; rel="next"`), link)
+
+		next, err := url.Parse(strings.TrimSuffix(strings.TrimPrefix(link, "<"), `>; rel="next"`))
+		require.NoError(t, err)
+		assert.Equal(t, "-created_at", next.Query().Get("sort"), "Параметры выборки сохраняются в ссылке")
+
+		rr = get(t, next.RequestURI())
+		require.Equal(t, http.StatusOK, rr.Code)
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &urls))
+		require.Len(t, urls, 1)
+		assert.Equal(t, "http://localhost:8080/u1", urls[0].ShortURL)
+		assert.Empty(t, rr.Header().Get("Link"))
+	})
+
+	t.Run("Фильтры", func(t *testing.T) {
+		rr := get(t, "/api/user/urls?search=U2")
+		require.Equal(t, http.StatusOK, rr.Code)
+		var urls []models.URLData
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &urls))
+		require.Len(t, urls, 1)
+
+		rr = get(t, "/api/user/urls?deleted=true")
+		assert.Equal(t, http.StatusNoContent, rr.Code)
+	})
+
+	t.Run("Некорректные параметры", func(t *testing.T) {
+		for _, query := range []string{"limit=0", "limit=abc", "deleted=maybe", "sort=name", "cursor=broken"} {
+			rr := get(t, "/api/user/urls?"+query)
+			assert.Equal(t, http.StatusBadRequest, rr.Code, query)
+		}
+	})
+
+	t.Run("Срок действия", func(t *testing.T) {
+		expiresAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+		require.NoError(t, store.SaveURLData(context.Background(), []models.URLData{
+			{ShortURL: "u4", OriginalURL: "https://u4.example.com", UserID: userID, ExpiresAt: &expiresAt},
+		}))
+
+		rr := get(t, "/api/user/urls?search=u4")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `[{"short_url":"http://localhost:8080/u4","original_url":"https://u4.example.com","expires_at":"2030-01-02T03:04:05Z"}]`, rr.Body.String())
+	})
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Eorthus/shorturl/internal/models"
+	"go.uber.org/zap"
+)
+
+// DefaultComingSoonStatus код ответа ссылки, которая еще не начала работать,
+// если в конфигурации задан недопустимый код
+const DefaultComingSoonStatus = http.StatusServiceUnavailable
+
+// comingSoonPage встроенная страница ссылки, которая еще не начала работать
+var comingSoonPage = template.Must(template.New("coming_soon").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Coming soon</title>
+</head>
+<body>
+<main>
+<h1>{{if .Title}}{{.Title}}{{else}}Coming soon{{end}}</h1>
+<p>This link opens on <time datetime="{{.NotBefore.Format "2006-01-02T15:04:05Z07:00"}}">{{.NotBefore.Format "2 January 2006, 15:04 MST"}}</time>.</p>
+</main>
+</body>
+</html>
+`))
+
+// comingSoonData данные шаблона страницы ссылки, которая еще не начала работать
+type comingSoonData struct {
+	// ShortURL - короткий URL
+	ShortURL string
+	// Title - заголовок ссылки
+	Title string
+	// NotBefore - время активации в UTC
+	NotBefore time.Time
+}
+
+// LoadComingSoonPage читает HTML-шаблон страницы ссылки, которая еще не начала работать.
+// Шаблону доступны поля .ShortURL, .Title и .NotBefore.
+func LoadComingSoonPage(path string) (*template.Template, error) {
+	return template.ParseFiles(path)
+}
+
+// renderComingSoon отвечает на переход по ссылке до ее времени активации.
+// Retry-After сообщает число секунд до активации.
+func (h *URLHandler) renderComingSoon(w http.ResponseWriter, url models.URLData) {
+	var buf bytes.Buffer
+	err := h.comingSoon.Execute(&buf, comingSoonData{
+		ShortURL:  h.cfg.BaseURL + "/" + url.ShortURL,
+		Title:     url.Title,
+		NotBefore: url.NotBefore.UTC(),
+	})
+	if err != nil {
+		h.logger.Error("Failed to render coming soon page", zap.Error(err))
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	wait := url.NotBefore.Sub(h.now())
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+	w.WriteHeader(h.comingSoonStatus)
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Eorthus/shorturl/internal/config"
+	"github.com/Eorthus/shorturl/internal/models"
+	"github.com/Eorthus/shorturl/internal/service"
+	"github.com/Eorthus/shorturl/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestComingSoon(t *testing.T) {
+	launch := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := launch.Add(-90 * time.Second)
+
+	newRouter := func(t *testing.T, cfg *config.Config, opts ...Option) *chi.Mux {
+		store, err := storage.NewMemoryStorage(context.Background())
+		require.NoError(t, err)
+		cfg.BaseURL = "http://localhost:8080"
+		urlService := service.NewURLService(store, service.WithClock(func() time.Time { return now }))
+		handler := NewURLHandler(cfg, urlService, zaptest.NewLogger(t), opts...)
+
+		r := chi.NewRouter()
+		r.Get("/{shortID}", handler.HandleGet)
+		r.Post("/{shortID}", handler.HandleUnlock)
+		r.Get("/{shortID}+", handler.HandlePreview)
+		r.Post("/api/shorten", handler.HandleJSONPost)
+
+		for _, body := range []string{
+			`{"url": "https://launch.example.com", "alias": "launch", "title": "Запуск", "not_before": "2030-01-01T15:00:00+03:00"}`,
+			`{"url": "https://secret.example.com", "alias": "secret", "password": "pw", "not_before": "2030-01-01T12:00:00Z"}`,
+		} {
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBufferString(body)))
+			require.Equal(t, http.StatusCreated, rr.Code)
+		}
+		return r
+	}
+	get := func(r http.Handler, target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		return rr
+	}
+
+	t.Run("Граница активации", func(t *testing.T) {
+		r := newRouter(t, &config.Config{})
+		defer func() { now = launch.Add(-90 * time.Second) }()
+
+		rr := get(r, "/launch")
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.Equal(t, "90", rr.Header().Get("Retry-After"))
+		assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+		assert.Empty(t, rr.Header().Get("Location"))
+		assert.Contains(t, rr.Body.String(), "Запуск")
+		assert.Contains(t, rr.Body.String(), "1 January 2030, 12:00 UTC")
+
+		now = launch.Add(-time.Millisecond)
+		rr = get(r, "/launch")
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+
+		now = launch
+		rr = get(r, "/launch")
+		assert.Equal(t, http.StatusTemporaryRedirect, rr.Code, "Ссылка работает с момента активации")
+		assert.Equal(t, "https://launch.example.com", rr.Header().Get("Location"))
+	})
+
+	t.Run("Срок действия по тем же часам", func(t *testing.T) {
+		r := newRouter(t, &config.Config{})
+		defer func() { now = launch.Add(-90 * time.Second) }()
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/shorten",
+			bytes.NewBufferString(`{"url": "https://promo.example.com", "alias": "promo", "ttl": "1h"}`)))
+		require.Equal(t, http.StatusCreated, rr.Code)
+
+		assert.Equal(t, http.StatusTemporaryRedirect, get(r, "/promo").Code)
+		now = now.Add(time.Hour)
+		assert.Equal(t, http.StatusGone, get(r, "/promo").Code, "Срок истекает по часам сервиса, а не по системным")
+	})
+
+	t.Run("Предпросмотр не раскрывает адрес", func(t *testing.T) {
+		r := newRouter(t, &config.Config{})
+		req := httptest.NewRequest(http.MethodGet, "/launch+", nil)
+		req.Header.Set("Accept", "application/json")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var preview models.LinkPreview
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &preview))
+		assert.Empty(t, preview.OriginalURL)
+		require.NotNil(t, preview.NotBefore)
+		assert.Equal(t, launch, *preview.NotBefore)
+	})
+
+	t.Run("Пароль до активации", func(t *testing.T) {
+		r := newRouter(t, &config.Config{})
+		req := httptest.NewRequest(http.MethodPost, "/secret", strings.NewReader("password=pw"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.Empty(t, rr.Result().Cookies())
+	})
+
+	t.Run("Своя страница и код", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "coming_soon.html")
+		require.NoError(t, os.WriteFile(path, []byte(`<p>{{.ShortURL}} откроется {{.NotBefore.Format "02.01.2006"}}</p>`), 0o600))
+		page, err := LoadComingSoonPage(path)
+		require.NoError(t, err)
+
+		r := newRouter(t, &config.Config{ComingSoonStatus: http.StatusNotFound}, WithComingSoonPage(page))
+		rr := get(r, "/launch")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+		assert.Equal(t, "<p>http://localhost:8080/launch откроется 01.01.2030</p>", rr.Body.String())
+
+		_, err = LoadComingSoonPage(filepath.Join(t.TempDir(), "missing.html"))
+		assert.Error(t, err)
+	})
+}
@@ -42,3 +42,36 @@ func (h *URLHandler) HandleDeleteURLs(w http.ResponseWriter, r *http.Request) {
 
 	w.WriteHeader(http.StatusAccepted)
 }
+
+// HandleRestoreURLs восстанавливает удаленные URL пользователя.
+// Принимает массив коротких идентификаторов в формате JSON и возвращает
+// массив восстановленных. Чужие, не удаленные и окончательно удаленные URL пропускаются.
+func (h *URLHandler) HandleRestoreURLs(w http.ResponseWriter, r *http.Request) {
+	var shortIDs []string
+	if err := json.NewDecoder(r.Body).Decode(&shortIDs); err != nil {
+		apperrors.HandleHTTPError(w, apperrors.ErrInvalidJSONFormat, h.logger)
+		return
+	}
+
+	userID := middleware.GetUserID(r)
+	if userID == "" {
+		apperrors.HandleHTTPError(w, apperrors.AppError{
+			Status:  http.StatusUnauthorized,
+			Message: "Unauthorized",
+		}, h.logger)
+		return
+	}
+
+	restored, err := h.urlService.RestoreUserURLs(r.Context(), shortIDs, userID)
+	if err != nil {
+		apperrors.HandleHTTPError(w, err, h.logger)
+		return
+	}
+	if restored == nil {
+		restored = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(restored)
+}
@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/Eorthus/shorturl/internal/middleware"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -22,3 +26,57 @@ func TestHandleDeleteURLs_Simple(t *testing.T) {
 
 	assert.Equal(t, http.StatusAccepted, rr.Code)
 }
+
+func TestHandleRestoreURLs(t *testing.T) {
+	r, store := setupRouter(t)
+	ctx := context.Background()
+	require.NoError(t, store.SaveURL(ctx, "undo1", "https://undo1.example.com", "owner"))
+	require.NoError(t, store.SaveURL(ctx, "undo2", "https://undo2.example.com", "owner"))
+	require.NoError(t, store.MarkURLsAsDeleted(ctx, []string{"undo1", "undo2"}, "owner"))
+
+	send := func(body, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/user/urls/restore", strings.NewReader(body))
+		if userID != "" {
+			req.AddCookie(&http.Cookie{
+				Name:  "user_token",
+				Value: userID + ":" + middleware.GenerateSignature(userID),
+			})
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Без авторизации", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, send(`["undo1"]`, "").Code)
+	})
+
+	t.Run("Некорректный JSON", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, send(`["undo1"`, "owner").Code)
+	})
+
+	t.Run("Чужие URL", func(t *testing.T) {
+		rr := send(`["undo1", "undo2"]`, "stranger")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `[]`, rr.Body.String())
+	})
+
+	t.Run("Восстановление", func(t *testing.T) {
+		rr := send(`["undo1", "missing"]`, "owner")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+		var restored []string
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &restored))
+		assert.Equal(t, []string{"undo1"}, restored)
+
+		rr = httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/undo1", nil))
+		assert.Equal(t, http.StatusTemporaryRedirect, rr.Code)
+		assert.Equal(t, "https://undo1.example.com", rr.Header().Get("Location"))
+
+		rr = httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/undo2", nil))
+		assert.Equal(t, http.StatusGone, rr.Code)
+	})
+}
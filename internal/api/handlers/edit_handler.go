@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/Eorthus/shorturl/internal/apperrors"
+	"github.com/Eorthus/shorturl/internal/middleware"
+	"github.com/Eorthus/shorturl/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// HandleUpdateURL обрабатывает PATCH-запросы на изменение URL пользователя.
+// Принимает JSON с необязательными полями "url", "tags" и "note" и возвращает обновленный URL.
+// Чужой или удаленный URL дает 404, уже сокращенный адрес — 409.
+func (h *URLHandler) HandleUpdateURL(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var request models.UpdateURLRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		apperrors.HandleHTTPError(w, apperrors.ErrInvalidJSONFormat, h.logger)
+		return
+	}
+
+	url, err := h.urlService.UpdateLink(r.Context(), chi.URLParam(r, "shortID"), userID, request)
+	if err != nil {
+		apperrors.HandleHTTPError(w, err, h.logger)
+		return
+	}
+
+	url.ShortURL = h.cfg.BaseURL + "/" + url.ShortURL
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(url)
+}
+
+// HandleSetRouting обрабатывает PUT-запросы с правилами выбора адреса назначения URL пользователя.
+// Принимает JSON с полями "rules" и "variants", пустые правила удаляются.
+// Чужой или удаленный URL дает 404.
+func (h *URLHandler) HandleSetRouting(w http.ResponseWriter, r *http.Request) {
+	var rules models.Routing
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		apperrors.HandleHTTPError(w, apperrors.ErrInvalidJSONFormat, h.logger)
+		return
+	}
+	h.setRouting(w, r, &rules)
+}
+
+// HandleDeleteRouting удаляет правила выбора адреса назначения URL пользователя.
+func (h *URLHandler) HandleDeleteRouting(w http.ResponseWriter, r *http.Request) {
+	h.setRouting(w, r, nil)
+}
+
+// setRouting сохраняет правила URL пользователя и отвечает 204
+func (h *URLHandler) setRouting(w http.ResponseWriter, r *http.Request, rules *models.Routing) {
+	userID := middleware.GetUserID(r)
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.urlService.SetLinkRouting(r.Context(), chi.URLParam(r, "shortID"), userID, rules); err != nil {
+		apperrors.HandleHTTPError(w, err, h.logger)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleGetUserTags возвращает метки URL пользователя с числом URL, начиная с самых частых.
+// Удаленные URL не учитываются.
+func (h *URLHandler) HandleGetUserTags(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	tags, err := h.urlService.GetUserTags(r.Context(), userID)
+	if err != nil {
+		apperrors.HandleHTTPError(w, err, h.logger)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if len(tags) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	json.NewEncoder(w).Encode(tags)
+}
+
+// HandleGetURLHistory возвращает версии адреса назначения URL пользователя
+// от первой до текущей. Чужой URL дает 404.
+func (h *URLHandler) HandleGetURLHistory(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	if userID == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	versions, err := h.urlService.GetLinkHistory(r.Context(), chi.URLParam(r, "shortID"), userID)
+	if err != nil {
+		apperrors.HandleHTTPError(w, err, h.logger)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(versions)
+}
@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Eorthus/shorturl/internal/config"
+	"github.com/Eorthus/shorturl/internal/middleware"
+	"github.com/Eorthus/shorturl/internal/models"
+	"github.com/Eorthus/shorturl/internal/service"
+	"github.com/Eorthus/shorturl/internal/storage"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func TestHandleUpdateURL(t *testing.T) {
+	r, store := setupRouter(t)
+	ctx := context.Background()
+	require.NoError(t, store.SaveURL(ctx, "edit1", "https://old.example.com", "owner"))
+	require.NoError(t, store.SaveURL(ctx, "edit2", "https://taken.example.com", "owner"))
+
+	send := func(method, target, body, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if userID != "" {
+			req.AddCookie(&http.Cookie{
+				Name:  "user_token",
+				Value: userID + ":" + middleware.GenerateSignature(userID),
+			})
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	tests := []struct {
+		name           string
+		target         string
+		body           string
+		userID         string
+		expectedStatus int
+	}{
+		{"Без авторизации", "/api/user/urls/edit1", `{"url": "https://new.example.com"}`, "", http.StatusUnauthorized},
+		{"Чужой URL", "/api/user/urls/edit1", `{"url": "https://new.example.com"}`, "stranger", http.StatusNotFound},
+		{"Несуществующий URL", "/api/user/urls/missing", `{"url": "https://new.example.com"}`, "owner", http.StatusNotFound},
+		{"Некорректный JSON", "/api/user/urls/edit1", `{"url":`, "owner", http.StatusBadRequest},
+		{"Некорректный URL", "/api/user/urls/edit1", `{"url": "not a url"}`, "owner", http.StatusBadRequest},
+		{"Адрес уже сокращен", "/api/user/urls/edit1", `{"url": "https://taken.example.com"}`, "owner", http.StatusConflict},
+		{"Успешное изменение", "/api/user/urls/edit1", `{"url": "https://new.example.com"}`, "owner", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := send(http.MethodPatch, tt.target, tt.body, tt.userID)
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+		})
+	}
+
+	t.Run("Перенаправление на новый адрес", func(t *testing.T) {
+		rr := send(http.MethodGet, "/edit1", "", "")
+		assert.Equal(t, http.StatusTemporaryRedirect, rr.Code)
+		assert.Equal(t, "https://new.example.com", rr.Header().Get("Location"))
+	})
+
+	t.Run("История изменений", func(t *testing.T) {
+		rr := send(http.MethodGet, "/api/user/urls/edit1/history", "", "owner")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+		var versions []models.URLVersion
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &versions))
+		require.Len(t, versions, 2)
+		assert.Equal(t, "https://old.example.com", versions[0].OriginalURL)
+		assert.NotNil(t, versions[0].ActiveUntil)
+		assert.Equal(t, "https://new.example.com", versions[1].OriginalURL)
+		assert.Nil(t, versions[1].ActiveUntil)
+
+		rr = send(http.MethodGet, "/api/user/urls/edit1/history", "", "stranger")
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("Прежний адрес можно сократить заново", func(t *testing.T) {
+		rr := send(http.MethodPost, "/", "https://old.example.com", "owner")
+		assert.Equal(t, http.StatusCreated, rr.Code)
+	})
+}
+
+func TestHandleURLLabels(t *testing.T) {
+	r, _ := setupRouter(t)
+	userID := "labeluser"
+
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.AddCookie(&http.Cookie{
+			Name:  "user_token",
+			Value: userID + ":" + middleware.GenerateSignature(userID),
+		})
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := send(http.MethodGet, "/api/user/tags", "")
+	assert.Equal(t, http.StatusNoContent, rr.Code, "Меток пока нет")
+
+	rr = send(http.MethodPost, "/api/shorten", `{"url":"https://labels.example.com","alias":"labeled","tags":["Promo","spring"],"note":"Рассылка"}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	rr = send(http.MethodPost, "/api/shorten", `{"url":"https://other.example.com","tags":["promo"]}`)
+	require.Equal(t, http.StatusCreated, rr.Code)
+	rr = send(http.MethodPost, "/api/shorten", `{"url":"https://bad.example.com","tags":["no spaces"]}`)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+
+	t.Run("Изменение меток и заметки", func(t *testing.T) {
+		rr := send(http.MethodPatch, "/api/user/urls/labeled", `{"tags":["spring","news"]}`)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var url models.URLData
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &url))
+		assert.Equal(t, "http://localhost:8080/labeled", url.ShortURL)
+		assert.Equal(t, "https://labels.example.com", url.OriginalURL)
+		assert.Equal(t, []string{"spring", "news"}, url.Tags)
+		assert.Equal(t, "Рассылка", url.Note, "Заметка не передана и не меняется")
+
+		rr = send(http.MethodPatch, "/api/user/urls/labeled", `{}`)
+		assert.Equal(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("Фильтр по метке", func(t *testing.T) {
+		rr := send(http.MethodGet, "/api/user/urls?tag=News", "")
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var urls []models.URLData
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &urls))
+		require.Len(t, urls, 1)
+		assert.Equal(t, "http://localhost:8080/labeled", urls[0].ShortURL)
+		assert.Equal(t, "Рассылка", urls[0].Note)
+	})
+
+	t.Run("Число URL по меткам", func(t *testing.T) {
+		rr := send(http.MethodGet, "/api/user/tags", "")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+		var tags []models.TagCount
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &tags))
+		assert.Equal(t, []models.TagCount{{Tag: "news", Count: 1}, {Tag: "promo", Count: 1}, {Tag: "spring", Count: 1}}, tags)
+	})
+
+	t.Run("Без авторизации", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/user/tags", nil))
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+}
+
+func TestHandleRouting(t *testing.T) {
+	r, store := setupRouter(t)
+	require.NoError(t, store.SaveURL(context.Background(), "split", "https://example.com", "owner"))
+
+	send := func(method, target, body, userID string, headers map[string]string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		if userID != "" {
+			req.AddCookie(&http.Cookie{
+				Name:  "user_token",
+				Value: userID + ":" + middleware.GenerateSignature(userID),
+			})
+		}
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rules := `{
+		"rules": [{"platform": "Android", "url": "https://play.example.com"}],
+		"variants": [
+			{"name": "a", "url": "https://example.com/a", "weight": 1},
+			{"name": "b", "url": "https://example.com/b", "weight": 1}
+		]
+	}`
+
+	tests := []struct {
+		name           string
+		body           string
+		userID         string
+		expectedStatus int
+	}{
+		{"Без авторизации", rules, "", http.StatusUnauthorized},
+		{"Чужой URL", rules, "stranger", http.StatusNotFound},
+		{"Некорректный JSON", `{"rules":`, "owner", http.StatusBadRequest},
+		{"Правило без условий", `{"rules":[{"url":"https://example.com/x"}]}`, "owner", http.StatusBadRequest},
+		{"Нулевой вес", `{"variants":[{"name":"a","url":"https://example.com/a","weight":0}]}`, "owner", http.StatusBadRequest},
+		{"Повтор имени варианта", `{"variants":[{"name":"a","url":"https://example.com/a","weight":1},{"name":"a","url":"https://example.com/b","weight":1}]}`,
+			"owner", http.StatusBadRequest},
+		{"Успешное сохранение", rules, "owner", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := send(http.MethodPut, "/api/user/urls/split/routing", tt.body, tt.userID, nil)
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+		})
+	}
+
+	t.Run("Правило по платформе", func(t *testing.T) {
+		rr := send(http.MethodGet, "/split", "", "", map[string]string{"User-Agent": "Mozilla/5.0 (Linux; Android 14; Pixel 8)"})
+		assert.Equal(t, http.StatusTemporaryRedirect, rr.Code)
+		assert.Equal(t, "https://play.example.com", rr.Header().Get("Location"))
+		assert.Empty(t, rr.Result().Cookies(), "Правило не назначает вариант")
+	})
+
+	t.Run("Вариант закрепляется за посетителем", func(t *testing.T) {
+		rr := send(http.MethodGet, "/split", "", "", nil)
+		require.Equal(t, http.StatusTemporaryRedirect, rr.Code)
+		assert.Equal(t, "private, no-store", rr.Header().Get("Cache-Control"))
+
+		cookies := rr.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, "/split", cookies[0].Path)
+		location := rr.Header().Get("Location")
+		assert.Equal(t, "https://example.com/"+cookies[0].Value, location)
+
+		for range 10 {
+			rr := send(http.MethodGet, "/split", "", "", nil, cookies[0])
+			assert.Equal(t, location, rr.Header().Get("Location"))
+			assert.Empty(t, rr.Result().Cookies(), "Cookie уже установлена")
+		}
+	})
+
+	t.Run("Удаление правил", func(t *testing.T) {
+		rr := send(http.MethodDelete, "/api/user/urls/split/routing", "", "owner", nil)
+		require.Equal(t, http.StatusNoContent, rr.Code)
+
+		rr = send(http.MethodGet, "/split", "", "", nil, &http.Cookie{Name: "link_variant", Value: "a"})
+		assert.Equal(t, "https://example.com", rr.Header().Get("Location"))
+	})
+
+	t.Run("Правила при создании ссылки", func(t *testing.T) {
+		rr := send(http.MethodPost, "/api/shorten",
+			`{"url":"https://lang.example.com","alias":"lang","routing":{"rules":[{"language":"ru","url":"https://lang.example.com/ru"}]}}`, "owner", nil)
+		require.Equal(t, http.StatusCreated, rr.Code)
+
+		rr = send(http.MethodGet, "/lang", "", "", map[string]string{"Accept-Language": "ru-RU,ru;q=0.9,en;q=0.8"})
+		assert.Equal(t, "https://lang.example.com/ru", rr.Header().Get("Location"))
+		rr = send(http.MethodGet, "/lang", "", "", map[string]string{"Accept-Language": "en-US"})
+		assert.Equal(t, "https://lang.example.com", rr.Header().Get("Location"))
+	})
+
+	t.Run("Срок cookie варианта по часам обработчика", func(t *testing.T) {
+		now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+		store, err := storage.NewMemoryStorage(context.Background())
+		require.NoError(t, err)
+		require.NoError(t, store.SaveURLData(context.Background(), []models.URLData{{
+			ShortURL:    "ab",
+			OriginalURL: "https://example.com",
+			Routing:     &models.Routing{Variants: []models.RoutingVariant{{Name: "a", URL: "https://example.com/a", Weight: 1}}},
+		}}))
+		urlService := service.NewURLService(store, service.WithClock(func() time.Time { return now }))
+		handler := NewURLHandler(&config.Config{}, urlService, zaptest.NewLogger(t))
+		router := chi.NewRouter()
+		router.Get("/{shortID}", handler.HandleGet)
+
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/ab", nil))
+		require.Equal(t, http.StatusTemporaryRedirect, rr.Code)
+		cookies := rr.Result().Cookies()
+		require.Len(t, cookies, 1)
+		assert.Equal(t, now.Add(middleware.VariantTTL), cookies[0].Expires)
+	})
+}
@@ -3,20 +3,32 @@
 // Основные обработчики:
 //   - HandlePost: создание короткого URL из текстового запроса
 //   - HandleGet: получение оригинального URL по короткому идентификатору
+//   - HandleUnlock: проверка пароля защищенной ссылки
+//   - HandlePreview: предпросмотр адреса назначения по адресу ссылки с суффиксом "+"
+//   - HandleQR: QR-код короткого URL в PNG или SVG
 //   - HandleJSONPost: создание короткого URL из JSON-запроса
 //   - HandleBatchShorten: пакетное создание коротких URL
-//   - HandleGetUserURLs: получение всех URL пользователя
+//   - HandleGetUserURLs: получение страницы URL пользователя
 //   - HandleDeleteURLs: удаление URL пользователя
+//   - HandleRestoreURLs: восстановление удаленных URL пользователя
+//   - HandleImportURLs, HandleExportURLs: импорт URL пользователя из CSV и выгрузка в CSV или JSON
+//   - HandleUpdateURL: изменение адреса назначения, заголовка, меток и заметки URL пользователя
+//   - HandleGetURLHistory: история адресов назначения URL пользователя
+//   - HandleGetUserTags: метки URL пользователя
+//   - HandleSetRouting, HandleDeleteRouting: правила выбора адреса назначения
 //
 // Примеры использования смотрите в example_test.go.
 package handlers
 
 import (
 	"bytes"
+	"html/template"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/Eorthus/shorturl/internal/config"
+	"github.com/Eorthus/shorturl/internal/ratelimit"
 	"github.com/Eorthus/shorturl/internal/service"
 	"go.uber.org/zap"
 )
@@ -26,14 +38,36 @@ import (
 // Поддерживает следующие операции:
 //   - Сокращение URL через POST запрос
 //   - Получение оригинального URL через GET запрос
+//   - Ввод пароля защищенной ссылки
+//   - Предпросмотр адреса назначения
+//   - QR-коды коротких URL
 //   - JSON API для сокращения URL
 //   - Пакетное сокращение URL
 //   - Получение URL пользователя
 //   - Удаление URL
+//   - Изменение адреса назначения и его история
 type URLHandler struct {
 	cfg        *config.Config
 	urlService *service.URLService
 	logger     *zap.Logger
+	// unlockLimiter ограничивает попытки ввода пароля
+	unlockLimiter *ratelimit.Limiter
+	// now часы сервиса, по которым проверяются активация и срок действия ссылок
+	now func() time.Time
+	// comingSoon страница ссылки, которая еще не начала работать, и ее код ответа
+	comingSoon       *template.Template
+	comingSoonStatus int
+}
+
+// Option настраивает URLHandler
+type Option func(*URLHandler)
+
+// WithComingSoonPage заменяет встроенную страницу ссылки, которая еще не начала работать,
+// см. LoadComingSoonPage
+func WithComingSoonPage(page *template.Template) Option {
+	return func(h *URLHandler) {
+		h.comingSoon = page
+	}
 }
 
 // NewURLHandler создает новый экземпляр URLHandler с указанными зависимостями.
@@ -42,16 +76,38 @@ type URLHandler struct {
 //   - cfg: конфигурация сервера
 //   - urlService: сервис для работы с URL
 //   - logger: логгер для записи событий
+//   - opts: необязательные настройки, например WithComingSoonPage
 //
 // Возвращает:
 //
 //	Новый экземпляр URLHandler
-func NewURLHandler(cfg *config.Config, urlService *service.URLService, logger *zap.Logger) *URLHandler {
-	return &URLHandler{
-		cfg:        cfg,
-		urlService: urlService,
-		logger:     logger,
+func NewURLHandler(cfg *config.Config, urlService *service.URLService, logger *zap.Logger, opts ...Option) *URLHandler {
+	attempts, window := cfg.UnlockAttempts, cfg.UnlockWindow
+	if attempts <= 0 {
+		attempts = DefaultUnlockAttempts
+	}
+	if window <= 0 {
+		window = DefaultUnlockWindow
+	}
+
+	status := cfg.ComingSoonStatus
+	if status < 200 || status > 599 {
+		status = DefaultComingSoonStatus
+	}
+
+	h := &URLHandler{
+		cfg:              cfg,
+		urlService:       urlService,
+		logger:           logger,
+		unlockLimiter:    ratelimit.New(attempts, window),
+		now:              urlService.Now,
+		comingSoon:       comingSoonPage,
+		comingSoonStatus: status,
+	}
+	for _, opt := range opts {
+		opt(h)
 	}
+	return h
 }
 
 // BufferPool представляет пул буферов для оптимизации памяти
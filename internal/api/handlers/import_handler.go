@@ -0,0 +1,360 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Eorthus/shorturl/internal/apperrors"
+	"github.com/Eorthus/shorturl/internal/middleware"
+	"github.com/Eorthus/shorturl/internal/models"
+	"github.com/Eorthus/shorturl/internal/service"
+	"go.uber.org/zap"
+)
+
+const (
+	// MaxImportRows максимальное число строк в импортируемом файле без заголовка
+	MaxImportRows = 10000
+	// MaxImportSize максимальный размер импортируемого файла в байтах
+	MaxImportSize = 8 << 20
+)
+
+// exportCSVHeader колонки CSV-выгрузки URL пользователя
+var exportCSVHeader = []string{"short_url", "original_url", "created_at", "is_deleted", "deleted_at", "not_before", "expires_at", "clicks_left", "protected", "redirect_code", "passthrough", "title", "tags", "note", "routing"}
+
+// importRow строка импортируемого файла
+type importRow struct {
+	line int
+	req  models.BatchRequest
+	err  error
+}
+
+// HandleImportURLs создает ссылки пользователя из CSV.
+// Первая строка — заголовок с колонками original_url (обязательна), alias, tags
+// и expires_at в любом порядке, остальные колонки пропускаются. Метки разделяются
+// пробелами, запятыми или точками с запятой, expires_at задается в RFC 3339.
+// Недопустимые строки не прерывают импорт, итог каждой строки возвращается в ImportReport.
+// Файл больше MaxImportSize отклоняется с 413.
+func (h *URLHandler) HandleImportURLs(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	if userID == "" {
+		apperrors.HandleHTTPError(w, apperrors.AppError{
+			Status:  http.StatusUnauthorized,
+			Message: "Unauthorized",
+		}, h.logger)
+		return
+	}
+
+	rows, err := parseImportCSV(http.MaxBytesReader(w, r.Body, MaxImportSize))
+	if err != nil {
+		apperrors.HandleHTTPError(w, err, h.logger)
+		return
+	}
+
+	report := models.ImportReport{Results: make([]models.ImportResult, len(rows))}
+	requests := make([]models.BatchRequest, 0, len(rows))
+	pending := make([]int, 0, len(rows))
+	for i, row := range rows {
+		report.Results[i] = models.ImportResult{Row: row.line, OriginalURL: row.req.OriginalURL}
+		if row.err != nil {
+			report.Results[i].Status = models.ImportFailed
+			report.Results[i].Error = row.err.Error()
+			continue
+		}
+		requests = append(requests, row.req)
+		pending = append(pending, i)
+	}
+
+	results, err := h.urlService.ImportURLBatch(r.Context(), requests, userID)
+	if err != nil {
+		apperrors.HandleHTTPError(w, err, h.logger)
+		return
+	}
+
+	for j, result := range results {
+		i := pending[j]
+		result.Row = rows[i].line
+		if result.ShortURL != "" {
+			result.ShortURL = h.cfg.BaseURL + "/" + result.ShortURL
+		}
+		report.Results[i] = result
+	}
+	for _, result := range report.Results {
+		switch result.Status {
+		case models.ImportCreated:
+			report.Created++
+		case models.ImportExists:
+			report.Existing++
+		default:
+			report.Failed++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
+
+// parseImportCSV читает строки импорта. Нечитаемая строка становится
+// строкой с ошибкой, весь файл отклоняется только при ошибке заголовка,
+// чтения тела запроса или превышении MaxImportRows.
+func parseImportCSV(body io.Reader) ([]importRow, error) {
+	cr := csv.NewReader(body)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	var maxBytesErr *http.MaxBytesError
+	header, err := cr.Read()
+	if errors.As(err, &maxBytesErr) {
+		return nil, apperrors.ErrImportFileTooLarge
+	}
+	if err != nil {
+		return nil, apperrors.ErrInvalidCSV
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		// Таблицы часто сохраняют UTF-8 с BOM в начале файла
+		name = strings.TrimPrefix(name, "\ufeff")
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["original_url"]; !ok {
+		return nil, apperrors.ErrInvalidCSV
+	}
+
+	var rows []importRow
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return rows, nil
+		}
+		if errors.As(err, &maxBytesErr) {
+			return nil, apperrors.ErrImportFileTooLarge
+		}
+		var parseErr *csv.ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			return nil, err
+		}
+		if len(rows) == MaxImportRows {
+			return nil, apperrors.ErrImportTooLarge
+		}
+
+		if err != nil {
+			rows = append(rows, importRow{line: parseErr.StartLine, err: apperrors.ErrInvalidCSV})
+			continue
+		}
+		line, _ := cr.FieldPos(0)
+		rows = append(rows, parseImportRecord(line, record, columns))
+	}
+}
+
+// parseImportRecord разбирает строку импорта
+func parseImportRecord(line int, record []string, columns map[string]int) importRow {
+	field := func(name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	row := importRow{line: line, req: models.BatchRequest{
+		OriginalURL: field("original_url"),
+		Alias:       field("alias"),
+	}}
+	if row.req.OriginalURL == "" {
+		row.err = apperrors.ErrEmptyURL
+		return row
+	}
+	if tags := strings.FieldsFunc(field("tags"), func(c rune) bool {
+		return c == ',' || c == ';' || c == ' ' || c == '\t'
+	}); len(tags) > 0 {
+		row.req.Tags = tags
+	}
+	if value := field("expires_at"); value != "" {
+		expiresAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			row.err = apperrors.ErrInvalidExpiry
+			return row
+		}
+		row.req.ExpiresAt = &expiresAt
+	}
+	return row
+}
+
+// HandleExportURLs выгружает все URL пользователя, включая удаленные,
+// в CSV (format=csv, по умолчанию) или JSON (format=json).
+// URL читаются из хранилища страницами и сразу передаются клиенту.
+func (h *URLHandler) HandleExportURLs(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r)
+	if userID == "" {
+		apperrors.HandleHTTPError(w, apperrors.AppError{
+			Status:  http.StatusUnauthorized,
+			Message: "Unauthorized",
+		}, h.logger)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "":
+		format = "csv"
+	case "csv", "json":
+	default:
+		apperrors.HandleHTTPError(w, apperrors.ErrInvalidQuery, h.logger)
+		return
+	}
+
+	query := models.URLQuery{Limit: service.MaxPageLimit}
+	page, err := h.urlService.GetUserURLsPage(r.Context(), userID, query)
+	if err != nil {
+		apperrors.HandleHTTPError(w, err, h.logger)
+		return
+	}
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.Header().Set("Content-Disposition", `attachment; filename="urls.`+format+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	bw := bufio.NewWriter(w)
+	var cw *csv.Writer
+	if format == "csv" {
+		cw = csv.NewWriter(bw)
+		cw.Write(exportCSVHeader)
+	} else {
+		bw.WriteString("[")
+	}
+
+	first := true
+	for {
+		for _, url := range page.URLs {
+			record := h.exportedURL(url)
+			if cw != nil {
+				err = cw.Write(exportCSVRecord(record))
+			} else {
+				if !first {
+					bw.WriteString(",")
+				}
+				var data []byte
+				if data, err = json.Marshal(record); err == nil {
+					_, err = bw.Write(data)
+				}
+			}
+			if err != nil {
+				// Заголовки уже отправлены, клиент получит неполную выгрузку
+				h.logger.Error("Failed to export URLs", zap.Error(err))
+				return
+			}
+			first = false
+		}
+
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+		if page, err = h.urlService.GetUserURLsPage(r.Context(), userID, query); err != nil {
+			h.logger.Error("Failed to export URLs", zap.Error(err))
+			return
+		}
+	}
+
+	if cw != nil {
+		cw.Flush()
+	} else {
+		bw.WriteString("]\n")
+	}
+	bw.Flush()
+}
+
+// exportedURL преобразует URL в запись выгрузки
+func (h *URLHandler) exportedURL(url models.URLData) models.ExportedURL {
+	return models.ExportedURL{
+		ShortURL:     h.cfg.BaseURL + "/" + url.ShortURL,
+		OriginalURL:  url.OriginalURL,
+		CreatedAt:    url.CreatedAt.UTC(),
+		IsDeleted:    url.IsDeleted,
+		DeletedAt:    utcTime(url.DeletedAt),
+		NotBefore:    utcTime(url.NotBefore),
+		ExpiresAt:    utcTime(url.ExpiresAt),
+		ClicksLeft:   url.ClicksLeft,
+		Protected:    url.Protected(),
+		RedirectCode: url.RedirectCode,
+		Passthrough:  url.Passthrough,
+		Title:        url.Title,
+		Tags:         url.Tags,
+		Note:         url.Note,
+		Routing:      url.Routing,
+	}
+}
+
+// exportCSVRecord форматирует запись выгрузки в колонки exportCSVHeader.
+// Ячейки экранируются csvSafe.
+func exportCSVRecord(url models.ExportedURL) []string {
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+	clicksLeft, redirectCode, routing := "", "", ""
+	if url.ClicksLeft != nil {
+		clicksLeft = strconv.Itoa(*url.ClicksLeft)
+	}
+	if url.RedirectCode != 0 {
+		redirectCode = strconv.Itoa(url.RedirectCode)
+	}
+	if url.Routing != nil {
+		data, _ := json.Marshal(url.Routing)
+		routing = string(data)
+	}
+
+	record := []string{
+		url.ShortURL,
+		url.OriginalURL,
+		url.CreatedAt.Format(time.RFC3339),
+		strconv.FormatBool(url.IsDeleted),
+		formatTime(url.DeletedAt),
+		formatTime(url.NotBefore),
+		formatTime(url.ExpiresAt),
+		clicksLeft,
+		strconv.FormatBool(url.Protected),
+		redirectCode,
+		strconv.FormatBool(url.Passthrough),
+		url.Title,
+		strings.Join(url.Tags, " "),
+		url.Note,
+		routing,
+	}
+	for i, cell := range record {
+		record[i] = csvSafe(cell)
+	}
+	return record
+}
+
+// csvSafe защищает от внедрения формул: табличные редакторы исполняют ячейку,
+// начинающуюся с =, +, -, @, табуляции или возврата каретки, поэтому
+// к такой ячейке добавляется ведущий апостроф, который редактор не показывает
+func csvSafe(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// utcTime приводит необязательное время к UTC
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
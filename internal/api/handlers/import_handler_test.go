@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Eorthus/shorturl/internal/middleware"
+	"github.com/Eorthus/shorturl/internal/models"
+	"github.com/Eorthus/shorturl/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleImportURLs(t *testing.T) {
+	r, store := setupRouter(t)
+	ctx := context.Background()
+	require.NoError(t, store.SaveURL(ctx, "taken", "https://taken.example.com", "other"))
+
+	send := func(body, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/user/urls/import", strings.NewReader(body))
+		req.Header.Set("Content-Type", "text/csv")
+		if userID != "" {
+			req.AddCookie(&http.Cookie{
+				Name:  "user_token",
+				Value: userID + ":" + middleware.GenerateSignature(userID),
+			})
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Без авторизации", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, send("original_url\nhttps://a.example.com\n", "").Code)
+	})
+
+	t.Run("Некорректный заголовок", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, send("", "owner").Code)
+		assert.Equal(t, http.StatusBadRequest, send("url,alias\nhttps://a.example.com,a\n", "owner").Code)
+	})
+
+	t.Run("Отчет по строкам", func(t *testing.T) {
+		body := "\ufeffAlias,Original_URL,Tags,Expires_At,Comment\n" +
+			"spring,https://spring.example.com,\"promo, spring\",2030-01-01T00:00:00Z,первая\n" +
+			",not a url,,,\n" +
+			"taken,https://new.example.com,,,\n" +
+			",https://taken.example.com,,,\n" +
+			",https://late.example.com,,yesterday,\n" +
+			",,,,\n" +
+			"\"broken,https://broken.example.com\n"
+		rr := send(body, "owner")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+		var report models.ImportReport
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &report))
+		assert.Equal(t, 1, report.Created)
+		assert.Equal(t, 1, report.Existing)
+		assert.Equal(t, 5, report.Failed)
+		require.Len(t, report.Results, 7)
+
+		assert.Equal(t, models.ImportResult{Row: 2, OriginalURL: "https://spring.example.com",
+			ShortURL: "http://localhost:8080/spring", Status: models.ImportCreated}, report.Results[0])
+		for i, expected := range []struct {
+			row    int
+			status models.ImportStatus
+			err    string
+		}{
+			{3, models.ImportFailed, "Invalid URL format"},
+			{4, models.ImportFailed, "Alias already taken"},
+			{5, models.ImportExists, ""},
+			{6, models.ImportFailed, "Invalid expiry"},
+			{7, models.ImportFailed, "Empty URL"},
+			{8, models.ImportFailed, "Invalid CSV"},
+		} {
+			result := report.Results[i+1]
+			assert.Equal(t, expected.row, result.Row)
+			assert.Equal(t, expected.status, result.Status, "Строка %d", expected.row)
+			assert.Equal(t, expected.err, result.Error, "Строка %d", expected.row)
+		}
+		assert.Equal(t, "http://localhost:8080/taken", report.Results[3].ShortURL)
+
+		url, found, err := store.GetURLData(ctx, "spring")
+		require.NoError(t, err)
+		require.True(t, found)
+		assert.Equal(t, "owner", url.UserID)
+		assert.Equal(t, []string{"promo", "spring"}, url.Tags)
+		require.NotNil(t, url.ExpiresAt)
+		assert.Equal(t, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), url.ExpiresAt.UTC())
+	})
+
+	t.Run("Слишком много строк", func(t *testing.T) {
+		var body strings.Builder
+		body.WriteString("original_url\n")
+		for i := range MaxImportRows + 1 {
+			fmt.Fprintf(&body, "https://bulk%d.example.com\n", i)
+		}
+		assert.Equal(t, http.StatusRequestEntityTooLarge, send(body.String(), "owner").Code)
+
+		_, found, err := store.GetURLData(ctx, "bulk0")
+		require.NoError(t, err)
+		assert.False(t, found, "Ссылки не создаются, если файл отклонен")
+	})
+
+	t.Run("Слишком большой файл", func(t *testing.T) {
+		body := "original_url,note\nhttps://huge.example.com," + strings.Repeat("x", MaxImportSize) + "\n"
+		rr := send(body, "owner")
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+		assert.Contains(t, rr.Body.String(), "Import file too large")
+
+		shortID, _ := store.GetShortIDByLongURL(ctx, "https://huge.example.com")
+		assert.Empty(t, shortID, "Ссылки не создаются, если файл отклонен")
+	})
+}
+
+func TestHandleExportURLs(t *testing.T) {
+	r, store := setupRouter(t)
+	ctx := context.Background()
+
+	createdAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	expiresAt := createdAt.Add(24 * time.Hour)
+	clicks := 3
+	urls := []models.URLData{
+		{ShortURL: "first", OriginalURL: "https://first.example.com", UserID: "owner", CreatedAt: createdAt,
+			ExpiresAt: &expiresAt, ClicksLeft: &clicks, PasswordHash: "hash", Title: "Первая", Tags: []string{"promo", "spring"}, Note: "a, \"b\""},
+		{ShortURL: "formula", OriginalURL: "https://formula.example.com", UserID: "owner", CreatedAt: createdAt.Add(-time.Second),
+			Title: "=HYPERLINK(\"https://evil.example.com\")", Tags: []string{"+cmd"}, Note: "@SUM(A1)"},
+		{ShortURL: "foreign", OriginalURL: "https://foreign.example.com", UserID: "other", CreatedAt: createdAt},
+	}
+	// Больше одной страницы, чтобы выгрузка прошла по курсору
+	for i := range service.MaxPageLimit {
+		urls = append(urls, models.URLData{
+			ShortURL:    fmt.Sprintf("bulk%d", i),
+			OriginalURL: fmt.Sprintf("https://bulk%d.example.com", i),
+			UserID:      "owner",
+			CreatedAt:   createdAt.Add(time.Duration(i+1) * time.Second),
+		})
+	}
+	require.NoError(t, store.SaveURLData(ctx, urls))
+	require.NoError(t, store.MarkURLsAsDeleted(ctx, []string{"bulk0"}, "owner"))
+
+	get := func(target, userID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if userID != "" {
+			req.AddCookie(&http.Cookie{
+				Name:  "user_token",
+				Value: userID + ":" + middleware.GenerateSignature(userID),
+			})
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Без авторизации", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, get("/api/user/urls/export", "").Code)
+	})
+
+	t.Run("Неизвестный формат", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, get("/api/user/urls/export?format=xml", "owner").Code)
+	})
+
+	t.Run("CSV", func(t *testing.T) {
+		rr := get("/api/user/urls/export", "owner")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "text/csv; charset=utf-8", rr.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="urls.csv"`, rr.Header().Get("Content-Disposition"))
+
+		records, err := csv.NewReader(rr.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, service.MaxPageLimit+3)
+		assert.Equal(t, exportCSVHeader, records[0])
+		assert.Equal(t, "http://localhost:8080/formula", records[1][0])
+		assert.Equal(t, []string{"'=HYPERLINK(\"https://evil.example.com\")", "'+cmd", "'@SUM(A1)"}, records[1][11:14],
+			"Формулы экранируются апострофом")
+		records = records[1:]
+		assert.Equal(t, []string{"http://localhost:8080/first", "https://first.example.com", "2024-05-01T10:00:00Z", "false", "",
+			"", "2024-05-02T10:00:00Z", "3", "true", "", "false", "Первая", "promo spring", "a, \"b\"", ""}, records[1])
+		assert.Equal(t, "http://localhost:8080/bulk0", records[2][0])
+		assert.Equal(t, "true", records[2][3], "Удаленные URL выгружаются")
+		assert.NotEmpty(t, records[2][4])
+		assert.Equal(t, "http://localhost:8080/bulk999", records[len(records)-1][0])
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		rr := get("/api/user/urls/export?format=json", "owner")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+		assert.NotContains(t, rr.Body.String(), "hash", "Хеш пароля не выгружается")
+
+		var exported []models.ExportedURL
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &exported))
+		require.Len(t, exported, service.MaxPageLimit+2)
+		assert.Equal(t, "=HYPERLINK(\"https://evil.example.com\")", exported[0].Title, "JSON выгружается без экранирования")
+		assert.Equal(t, "http://localhost:8080/first", exported[1].ShortURL)
+		assert.True(t, exported[1].Protected)
+		assert.Equal(t, []string{"promo", "spring"}, exported[1].Tags)
+		assert.True(t, exported[2].IsDeleted)
+	})
+
+	t.Run("Пустая выгрузка", func(t *testing.T) {
+		rr := get("/api/user/urls/export?format=json", "nobody")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.JSONEq(t, `[]`, rr.Body.String())
+	})
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/Eorthus/shorturl/internal/apperrors"
+	"github.com/Eorthus/shorturl/internal/middleware"
+	"github.com/Eorthus/shorturl/internal/models"
+	"github.com/go-chi/chi/v5"
+)
+
+// previewPage страница предпросмотра; адрес назначения показывается текстом
+// и ссылкой, по которой переход засчитывается как обычно
+var previewPage = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{if .Title}}{{.Title}}{{else}}Link preview{{end}}</title>
+</head>
+<body>
+<main>
+{{if .Title}}<h1>{{.Title}}</h1>
+{{end}}<dl>
+<dt>Short link</dt>
+<dd>{{.ShortURL}}</dd>
+<dt>Destination</dt>
+<dd>{{if .NotBefore}}Hidden until the link opens on {{.NotBefore.Format "2 January 2006, 15:04 MST"}}.{{else if .Protected}}Hidden, the link is protected by a password.{{else}}{{.OriginalURL}}{{end}}</dd>
+<dt>Created</dt>
+<dd><time datetime="{{.CreatedAt.Format "2006-01-02T15:04:05Z07:00"}}">{{.CreatedAt.Format "2 January 2006"}}</time></dd>
+</dl>
+<p><a href="{{.ShortURL}}" rel="nofollow noopener">Continue</a></p>
+</main>
+</body>
+</html>
+`))
+
+// HandlePreview показывает адрес назначения ссылки вместо перенаправления.
+// Отвечает на GET /{shortID}+: JSON при заголовке Accept: application/json,
+// иначе HTML-страница. Переход не засчитывается. Для удаленных, истекших
+// и исчерпавших переходы ссылок отвечает 410 Gone, адрес назначения
+// защищенной ссылки без cookie разблокировки и ссылки до времени активации
+// не раскрывается.
+func (h *URLHandler) HandlePreview(w http.ResponseWriter, r *http.Request) {
+	shortID := chi.URLParam(r, "shortID")
+
+	url, gone, err := h.urlService.GetLink(r.Context(), shortID)
+	if err != nil {
+		apperrors.HandleHTTPError(w, err, h.logger)
+		return
+	}
+
+	w.Header().Set("Vary", "Accept")
+	if unavailable(url, gone) {
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+
+	now := h.now()
+	preview := models.LinkPreview{
+		ShortURL:  h.cfg.BaseURL + "/" + url.ShortURL,
+		Title:     url.Title,
+		CreatedAt: url.CreatedAt.UTC(),
+	}
+	switch {
+	case url.Pending(now):
+		// Адрес назначения не раскрывается до запуска
+		notBefore := url.NotBefore.UTC()
+		preview.NotBefore = &notBefore
+	case url.Protected() && !middleware.IsUnlocked(r, shortID, url.PasswordHash, now):
+		preview.Protected = true
+	default:
+		preview.OriginalURL = url.OriginalURL
+	}
+
+	// Владелец может сменить адрес назначения, поэтому страница не кэшируется
+	w.Header().Set("Cache-Control", "no-store")
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(preview)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	previewPage.Execute(w, preview)
+}
+
+// unavailable проверяет, что по ссылке больше нельзя перейти:
+// она удалена, истекла или исчерпала переходы
+func unavailable(url models.URLData, gone bool) bool {
+	return gone || (url.ClicksLeft != nil && *url.ClicksLeft <= 0)
+}
+
+// wantsJSON проверяет, что клиент запросил JSON в заголовке Accept
+func wantsJSON(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, _ := strings.Cut(accept, ";")
+		if strings.EqualFold(strings.TrimSpace(mediaType), "application/json") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/Eorthus/shorturl/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlePreview(t *testing.T) {
+	r, store := setupRouter(t)
+
+	shorten := func(body string) {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/shorten", bytes.NewBufferString(body)))
+		require.Equal(t, http.StatusCreated, rr.Code)
+	}
+	shorten(`{"url": "https://example.com/spring?a=1&b=2", "alias": "spring", "title": "<b>Весна</b>", "max_clicks": 1}`)
+	shorten(`{"url": "https://secret.com", "alias": "secret", "password": "pw"}`)
+
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, store.SaveURLData(context.Background(), []models.URLData{
+		{ShortURL: "expired", OriginalURL: "https://expired.com", ExpiresAt: &past},
+		{ShortURL: "deleted", OriginalURL: "https://deleted.com", UserID: "user1"},
+	}))
+	require.NoError(t, store.MarkURLsAsDeleted(context.Background(), []string{"deleted"}, "user1"))
+
+	preview := func(target, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("HTML-страница", func(t *testing.T) {
+		rr := preview("/spring+", "text/html,application/xhtml+xml")
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+		assert.Equal(t, "Accept", rr.Header().Get("Vary"))
+		assert.Empty(t, rr.Header().Get("Location"))
+		body := rr.Body.String()
+		assert.Contains(t, body, "https://example.com/spring?a=1&amp;b=2")
+		assert.Contains(t, body, "&lt;b&gt;Весна&lt;/b&gt;", "Заголовок экранируется")
+		assert.NotContains(t, body, "<b>")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		rr := preview("/spring+", "application/json; charset=utf-8")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+		var link models.LinkPreview
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &link))
+		assert.Equal(t, "http://localhost:8080/spring", link.ShortURL)
+		assert.Equal(t, "https://example.com/spring?a=1&b=2", link.OriginalURL)
+		assert.Equal(t, "<b>Весна</b>", link.Title)
+		assert.WithinDuration(t, time.Now(), link.CreatedAt, time.Minute)
+		assert.False(t, link.Protected)
+	})
+
+	t.Run("Предпросмотр не засчитывает переход", func(t *testing.T) {
+		rr := preview("/spring", "")
+		assert.Equal(t, http.StatusTemporaryRedirect, rr.Code)
+		assert.Equal(t, http.StatusGone, preview("/spring+", "").Code, "Переходы исчерпаны")
+	})
+
+	t.Run("Защищенная ссылка", func(t *testing.T) {
+		rr := preview("/secret+", "application/json")
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var link models.LinkPreview
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &link))
+		assert.True(t, link.Protected)
+		assert.Empty(t, link.OriginalURL, "Адрес назначения не раскрывается")
+		assert.NotContains(t, preview("/secret+", "").Body.String(), "secret.com")
+	})
+
+	t.Run("Защищенная ссылка после ввода пароля", func(t *testing.T) {
+		// Cookie хранит настоящий клиент, чтобы проверить ее путь, а не подставлять вручную
+		server := httptest.NewServer(r)
+		defer server.Close()
+		jar, err := cookiejar.New(nil)
+		require.NoError(t, err)
+		client := &http.Client{
+			Jar: jar,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+
+		resp, err := client.PostForm(server.URL+"/secret", url.Values{"password": {"pw"}})
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusSeeOther, resp.StatusCode)
+
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/secret+", nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", "application/json")
+		resp, err = client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var link models.LinkPreview
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&link))
+		assert.False(t, link.Protected)
+		assert.Equal(t, "https://secret.com", link.OriginalURL)
+	})
+
+	t.Run("Удаленные и истекшие ссылки", func(t *testing.T) {
+		assert.Equal(t, http.StatusGone, preview("/expired+", "").Code)
+		assert.Equal(t, http.StatusGone, preview("/deleted+", "application/json").Code)
+		assert.Equal(t, http.StatusNotFound, preview("/missing+", "").Code)
+	})
+}
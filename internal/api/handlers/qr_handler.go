@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+
+	"github.com/Eorthus/shorturl/internal/apperrors"
+	"github.com/Eorthus/shorturl/internal/qr"
+	"github.com/go-chi/chi/v5"
+)
+
+// Параметры QR-кода по умолчанию и их допустимые значения
+const (
+	DefaultQRSize  = 256
+	MinQRSize      = 32
+	MaxQRSize      = 2048
+	MaxQRMargin    = 16
+	DefaultQRLevel = "M"
+)
+
+// qrOptions параметры запроса QR-кода
+type qrOptions struct {
+	svg    bool
+	size   int
+	margin int
+	level  qr.Level
+}
+
+// HandleQR отдает QR-код короткого URL в PNG или SVG.
+// Параметры запроса: format (png или svg), size (сторона в пикселях),
+// margin (свободное поле в модулях) и level (коррекция ошибок L, M, Q или H).
+// Несуществующая ссылка дает 404, удаленная, истекшая или исчерпавшая переходы — 410.
+func (h *URLHandler) HandleQR(w http.ResponseWriter, r *http.Request) {
+	shortID := chi.URLParam(r, "shortID")
+
+	opts, err := parseQROptions(r)
+	if err != nil {
+		apperrors.HandleHTTPError(w, err, h.logger)
+		return
+	}
+
+	url, gone, err := h.urlService.GetLink(r.Context(), shortID)
+	if err != nil {
+		apperrors.HandleHTTPError(w, err, h.logger)
+		return
+	}
+	if unavailable(url, gone) {
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+
+	code, err := qr.Encode(h.cfg.BaseURL+"/"+url.ShortURL, opts.level)
+	if err != nil {
+		apperrors.HandleHTTPError(w, err, h.logger)
+		return
+	}
+
+	var body []byte
+	contentType := "image/png"
+	if opts.svg {
+		contentType = "image/svg+xml"
+		var buf bytes.Buffer
+		err = code.WriteSVG(&buf, opts.size, opts.margin)
+		body = buf.Bytes()
+	} else {
+		body, err = code.PNG(opts.size, opts.margin)
+	}
+	if err != nil {
+		apperrors.HandleHTTPError(w, err, h.logger)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// parseQROptions разбирает параметры QR-кода, пустые параметры заменяются значениями по умолчанию
+func parseQROptions(r *http.Request) (qrOptions, error) {
+	values := r.URL.Query()
+	opts := qrOptions{size: DefaultQRSize, margin: qr.DefaultMargin}
+
+	switch values.Get("format") {
+	case "", "png":
+	case "svg":
+		opts.svg = true
+	default:
+		return qrOptions{}, apperrors.ErrInvalidQRParams
+	}
+
+	var err error
+	if value := values.Get("size"); value != "" {
+		if opts.size, err = strconv.Atoi(value); err != nil || opts.size < MinQRSize || opts.size > MaxQRSize {
+			return qrOptions{}, apperrors.ErrInvalidQRParams
+		}
+	}
+	if value := values.Get("margin"); value != "" {
+		if opts.margin, err = strconv.Atoi(value); err != nil || opts.margin < 0 || opts.margin > MaxQRMargin {
+			return qrOptions{}, apperrors.ErrInvalidQRParams
+		}
+	}
+
+	level := values.Get("level")
+	if level == "" {
+		level = DefaultQRLevel
+	}
+	if opts.level, err = qr.ParseLevel(level); err != nil {
+		return qrOptions{}, apperrors.ErrInvalidQRParams
+	}
+	return opts, nil
+}
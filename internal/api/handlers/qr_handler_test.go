@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Eorthus/shorturl/internal/models"
+	"github.com/Eorthus/shorturl/internal/qr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleQR(t *testing.T) {
+	r, store := setupRouter(t)
+
+	require.NoError(t, store.SaveURLData(context.Background(), []models.URLData{
+		{ShortURL: "poster", OriginalURL: "https://poster.example.com"},
+		{ShortURL: "deleted", OriginalURL: "https://deleted.example.com", UserID: "user1"},
+		{ShortURL: "docs", OriginalURL: "https://docs.example.com/v1", Passthrough: true},
+	}))
+	require.NoError(t, store.MarkURLsAsDeleted(context.Background(), []string{"deleted"}, "user1"))
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, target, nil))
+		return rr
+	}
+
+	t.Run("PNG по умолчанию", func(t *testing.T) {
+		rr := get("/api/qr/poster")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+
+		img, err := png.Decode(bytes.NewReader(rr.Body.Bytes()))
+		require.NoError(t, err)
+		assert.Equal(t, DefaultQRSize, img.Bounds().Dx())
+		assert.Equal(t, DefaultQRSize, img.Bounds().Dy())
+
+		code, err := qr.Encode("http://localhost:8080/poster", qr.M)
+		require.NoError(t, err)
+		expected, err := code.PNG(DefaultQRSize, qr.DefaultMargin)
+		require.NoError(t, err)
+		assert.Equal(t, expected, rr.Body.Bytes(), "Кодируется короткий URL")
+	})
+
+	t.Run("SVG с параметрами", func(t *testing.T) {
+		rr := get("/api/qr/poster?format=svg&size=512&margin=0&level=h")
+		require.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "image/svg+xml", rr.Header().Get("Content-Type"))
+
+		code, err := qr.Encode("http://localhost:8080/poster", qr.H)
+		require.NoError(t, err)
+		var expected bytes.Buffer
+		require.NoError(t, code.WriteSVG(&expected, 512, 0))
+		assert.Equal(t, expected.String(), rr.Body.String())
+	})
+
+	t.Run("Недопустимые параметры", func(t *testing.T) {
+		for _, query := range []string{"format=gif", "size=abc", "size=10", "size=5000", "margin=-1", "margin=17", "level=X"} {
+			assert.Equal(t, http.StatusBadRequest, get("/api/qr/poster?"+query).Code, query)
+		}
+	})
+
+	t.Run("Путь qr у passthrough ссылки не занят", func(t *testing.T) {
+		rr := get("/docs/qr")
+		assert.Equal(t, http.StatusTemporaryRedirect, rr.Code)
+		assert.Equal(t, "https://docs.example.com/v1/qr", rr.Header().Get("Location"))
+	})
+
+	t.Run("Несуществующие и удаленные ссылки", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, get("/api/qr/missing").Code)
+		assert.Equal(t, http.StatusGone, get("/api/qr/deleted").Code)
+	})
+}
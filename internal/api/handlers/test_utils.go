@@ -29,12 +29,25 @@ func setupRouter(t *testing.T) (*chi.Mux, storage.Storage) {
 	r := chi.NewRouter()
 	r.Route("/", func(r chi.Router) {
 		r.Get("/{shortID}", handler.HandleGet)
+		r.Post("/{shortID}", handler.HandleUnlock)
+		r.Get("/{shortID}/*", handler.HandleGet)
+		r.Get("/{shortID}+", handler.HandlePreview)
+		r.Get("/api/qr/{shortID}", handler.HandleQR)
+		r.Post("/{shortID}/*", handler.HandleUnlock)
 		r.Post("/", handler.HandlePost)
 		r.Post("/api/shorten", handler.HandleJSONPost)
 		r.Get("/ping", handler.HandlePing)
 		r.Post("/api/shorten/batch", handler.HandleBatchShorten)
 		r.Get("/api/user/urls", handler.HandleGetUserURLs) // Новый handler
 		r.Delete("/api/user/urls", handler.HandleDeleteURLs)
+		r.Post("/api/user/urls/restore", handler.HandleRestoreURLs)
+		r.Post("/api/user/urls/import", handler.HandleImportURLs)
+		r.Get("/api/user/urls/export", handler.HandleExportURLs)
+		r.Patch("/api/user/urls/{shortID}", handler.HandleUpdateURL)
+		r.Get("/api/user/urls/{shortID}/history", handler.HandleGetURLHistory)
+		r.Get("/api/user/tags", handler.HandleGetUserTags)
+		r.Put("/api/user/urls/{shortID}/routing", handler.HandleSetRouting)
+		r.Delete("/api/user/urls/{shortID}/routing", handler.HandleDeleteRouting)
 	})
 
 	return r, store
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"html/template"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Eorthus/shorturl/internal/apperrors"
+	"github.com/Eorthus/shorturl/internal/middleware"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// Ограничение попыток ввода пароля, если оно не задано в конфигурации
+const (
+	DefaultUnlockAttempts = 5
+	DefaultUnlockWindow   = time.Minute
+)
+
+// passwordForm форма ввода пароля; без action она отправляется на адрес самой ссылки
+var passwordForm = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Protected link</title>
+</head>
+<body>
+<form method="post">
+<p>This link is protected by a password.</p>
+{{if .}}<p role="alert">{{.}}</p>
+{{end}}<input type="password" name="password" autocomplete="current-password" autofocus required>
+<button type="submit">Open</button>
+</form>
+</body>
+</html>
+`))
+
+// HandleUnlock обрабатывает отправку формы пароля защищенной ссылки.
+// Верный пароль запоминается в подписанной cookie и перенаправляет
+// на оригинальный URL с кодом 303, неверный возвращает форму с кодом 403.
+// Попытки ограничены по адресу клиента и ссылке, превышение дает 429.
+func (h *URLHandler) HandleUnlock(w http.ResponseWriter, r *http.Request) {
+	shortID := chi.URLParam(r, "shortID")
+
+	if !h.unlockLimiter.Allow(clientIP(r) + "|" + shortID) {
+		w.Header().Set("Retry-After", retryAfter(h.unlockLimiter.Window()))
+		renderPasswordForm(w, http.StatusTooManyRequests, "Too many attempts, try again later.")
+		return
+	}
+
+	url, gone, err := h.getLink(r, shortID)
+	if err != nil {
+		apperrors.HandleHTTPError(w, err, h.logger)
+		return
+	}
+	if gone {
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+	if url.Pending(h.now()) {
+		h.renderComingSoon(w, url)
+		return
+	}
+
+	if !h.urlService.CheckPassword(url, r.PostFormValue("password")) {
+		h.logger.Info("Wrong link password", zap.String("short_id", shortID))
+		renderPasswordForm(w, http.StatusForbidden, "Wrong password.")
+		return
+	}
+
+	if url.Protected() {
+		middleware.SetUnlockCookie(w, shortID, url.PasswordHash, h.now())
+	}
+	h.redirect(w, r, url, http.StatusSeeOther)
+}
+
+// renderPasswordForm отдает форму ввода пароля с необязательным сообщением об ошибке
+func renderPasswordForm(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	passwordForm.Execute(w, message)
+}
+
+// clientIP возвращает адрес клиента без порта
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// retryAfter форматирует длительность для заголовка Retry-After в секундах
+func retryAfter(d time.Duration) string {
+	return strconv.Itoa(int(d.Round(time.Second) / time.Second))
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleUnlock(t *testing.T) {
+	r, _ := setupRouter(t)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/api/shorten",
+		bytes.NewBufferString(`{"url": "https://secret.com", "alias": "secret", "password": "pw"}`)))
+	require.Equal(t, http.StatusCreated, rr.Code)
+
+	unlock := func(password, remoteAddr string) *httptest.ResponseRecorder {
+		form := url.Values{"password": {password}}
+		req := httptest.NewRequest(http.MethodPost, "/secret", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Форма вместо перенаправления", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/secret", nil))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "text/html; charset=utf-8", rr.Header().Get("Content-Type"))
+		assert.Contains(t, rr.Body.String(), `type="password"`)
+		assert.Empty(t, rr.Header().Get("Location"))
+	})
+
+	t.Run("Неверный пароль", func(t *testing.T) {
+		rr := unlock("wrong", "192.0.2.1:1234")
+
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.Contains(t, rr.Body.String(), "Wrong password.")
+		assert.Empty(t, rr.Result().Cookies())
+	})
+
+	t.Run("Верный пароль", func(t *testing.T) {
+		rr := unlock("pw", "192.0.2.1:1234")
+
+		assert.Equal(t, http.StatusSeeOther, rr.Code)
+		assert.Equal(t, "https://secret.com", rr.Header().Get("Location"))
+
+		var unlockCookie *http.Cookie
+		for _, cookie := range rr.Result().Cookies() {
+			if strings.HasPrefix(cookie.Name, "link_unlock_") {
+				unlockCookie = cookie
+			}
+		}
+		require.NotNil(t, unlockCookie, "Разблокировка запоминается в cookie")
+
+		req := httptest.NewRequest(http.MethodGet, "/secret", nil)
+		req.AddCookie(unlockCookie)
+		rr = httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusTemporaryRedirect, rr.Code)
+		assert.Equal(t, "https://secret.com", rr.Header().Get("Location"))
+	})
+
+	t.Run("Ограничение попыток", func(t *testing.T) {
+		for range DefaultUnlockAttempts {
+			assert.Equal(t, http.StatusForbidden, unlock("wrong", "203.0.113.7:1234").Code)
+		}
+		rr := unlock("pw", "203.0.113.7:1234")
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code, "Лимит действует и для верного пароля")
+		assert.Equal(t, "60", rr.Header().Get("Retry-After"))
+
+		assert.Equal(t, http.StatusSeeOther, unlock("pw", "203.0.113.8:1234").Code, "Другие клиенты не блокируются")
+	})
+
+	t.Run("Несуществующая ссылка", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/missing", strings.NewReader("password=pw"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusNotFound, rr.Code)
+	})
+}
@@ -6,10 +6,13 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/Eorthus/shorturl/internal/apperrors"
 	"github.com/Eorthus/shorturl/internal/middleware"
 	"github.com/Eorthus/shorturl/internal/models"
+	"github.com/Eorthus/shorturl/internal/routing"
+	"github.com/Eorthus/shorturl/internal/service"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
@@ -50,26 +53,106 @@ func (h *URLHandler) HandlePost(w http.ResponseWriter, r *http.Request) {
 
 // HandleGet обрабатывает GET-запросы для получения оригинального URL.
 // Короткий идентификатор передается в URL запроса.
-// Выполняет перенаправление на оригинальный URL.
+// Выполняет перенаправление на оригинальный URL, для удаленных,
+// истекших и исчерпавших переходы ссылок отвечает 410 Gone, до времени
+// активации ссылки — настраиваемой страницей, см. WithComingSoonPage.
+// Для защищенной ссылки без cookie разблокировки показывает форму ввода пароля.
+// Код перенаправления задается ссылкой, продолжение пути после идентификатора
+// допускается только для ссылок в режиме passthrough. Адрес назначения
+// выбирается правилами ссылки, см. routing.Evaluate. Предпросмотр без
+// перенаправления отдает HandlePreview.
 func (h *URLHandler) HandleGet(w http.ResponseWriter, r *http.Request) {
 	shortID := chi.URLParam(r, "shortID")
 
-	longURL, isDeleted, err := h.urlService.GetOriginalURL(r.Context(), shortID)
+	url, gone, err := h.getLink(r, shortID)
 	if err != nil {
 		apperrors.HandleHTTPError(w, err, h.logger)
 		return
 	}
 
-	if isDeleted {
+	if gone {
 		w.WriteHeader(http.StatusGone)
 		return
 	}
 
-	http.Redirect(w, r, longURL, http.StatusTemporaryRedirect)
+	now := h.now()
+	if url.Pending(now) {
+		h.renderComingSoon(w, url)
+		return
+	}
+
+	if url.Protected() && !middleware.IsUnlocked(r, shortID, url.PasswordHash, now) {
+		renderPasswordForm(w, http.StatusOK, "")
+		return
+	}
+
+	h.redirect(w, r, url, url.RedirectStatus())
+}
+
+// getLink находит ссылку запроса; продолжение пути у ссылки без passthrough дает ErrNoSuchURL
+func (h *URLHandler) getLink(r *http.Request, shortID string) (models.URLData, bool, error) {
+	url, gone, err := h.urlService.GetLink(r.Context(), shortID)
+	if err == nil && pathSuffix(r) != "" && !url.Passthrough {
+		return models.URLData{}, false, apperrors.ErrNoSuchURL
+	}
+	return url, gone, err
+}
+
+// pathSuffix возвращает экранированное продолжение пути после короткого идентификатора
+func pathSuffix(r *http.Request) string {
+	_, suffix, _ := strings.Cut(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/")
+	return suffix
+}
+
+// redirect засчитывает переход и перенаправляет на адрес назначения ссылки
+func (h *URLHandler) redirect(w http.ResponseWriter, r *http.Request, url models.URLData, code int) {
+	ok, err := h.urlService.Visit(r.Context(), url)
+	if err != nil {
+		apperrors.HandleHTTPError(w, err, h.logger)
+		return
+	}
+	if !ok {
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+	// Браузеры бессрочно кэшируют постоянные перенаправления и перестают
+	// обращаться к сервису, поэтому ограничения ссылки не проверялись бы
+	if url.Restricted() {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+
+	http.Redirect(w, r, service.Destination(h.route(w, r, url), pathSuffix(r), r.URL.RawQuery), code)
+}
+
+// route подставляет адрес назначения, выбранный правилами ссылки,
+// и запоминает вариант A/B-распределения в cookie посетителя
+func (h *URLHandler) route(w http.ResponseWriter, r *http.Request, url models.URLData) models.URLData {
+	if url.Routing == nil {
+		return url
+	}
+
+	visit := routing.Visit{
+		UserAgent:      r.UserAgent(),
+		AcceptLanguage: r.Header.Get("Accept-Language"),
+		Referer:        r.Referer(),
+		Variant:        middleware.GetVariant(r),
+		VisitorID:      uuid.New().String(),
+	}
+	result := routing.Evaluate(url, visit)
+	if result.Variant != "" && result.Variant != visit.Variant {
+		middleware.SetVariantCookie(w, url.ShortURL, result.Variant, h.now())
+	}
+	// Адрес зависит от посетителя, поэтому перенаправление не кэшируется
+	w.Header().Set("Cache-Control", "private, no-store")
+
+	url.OriginalURL = result.URL
+	return url
 }
 
 // HandleJSONPost обрабатывает POST-запросы для создания коротких URL в формате JSON.
-// Принимает JSON с полем "url".
+// Принимает JSON с полем "url" и необязательными полями "alias",
+// "expires_at" (RFC 3339), "ttl" (например, "24h" или число секунд),
+// "max_clicks", "password", "redirect_code", "passthrough", "tags", "note" и "routing".
 // Возвращает JSON с полем "result", содержащим короткий URL.
 func (h *URLHandler) HandleJSONPost(w http.ResponseWriter, r *http.Request) {
 	buf := BufferPool.Get().(*bytes.Buffer)
@@ -94,7 +177,20 @@ func (h *URLHandler) HandleJSONPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	shortID, err := h.urlService.ShortenURL(r.Context(), request.URL, userID)
+	shortID, err := h.urlService.CreateLink(r.Context(), request.URL, userID, service.LinkOptions{
+		Alias:        request.Alias,
+		NotBefore:    request.NotBefore,
+		ExpiresAt:    request.ExpiresAt,
+		TTL:          time.Duration(request.TTL),
+		MaxClicks:    request.MaxClicks,
+		Password:     request.Password,
+		RedirectCode: request.RedirectCode,
+		Passthrough:  request.Passthrough,
+		Title:        request.Title,
+		Tags:         request.Tags,
+		Note:         request.Note,
+		Routing:      request.Routing,
+	})
 	if err != nil {
 		if err == apperrors.ErrURLExists {
 			w.Header().Set("Content-Type", "application/json")
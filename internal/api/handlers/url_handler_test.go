@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Eorthus/shorturl/internal/models"
 	"github.com/stretchr/testify/assert"
@@ -67,6 +68,12 @@ func TestHandleGet(t *testing.T) {
 	err := store.SaveURL(ctx, shortID, longURL, "testuser")
 	require.NoError(t, err)
 
+	expired := time.Now().Add(-time.Minute)
+	err = store.SaveURLData(ctx, []models.URLData{
+		{ShortURL: "expired", OriginalURL: "https://expired.com", UserID: "testuser", ExpiresAt: &expired},
+	})
+	require.NoError(t, err)
+
 	tests := []struct {
 		name           string
 		shortID        string
@@ -75,6 +82,7 @@ func TestHandleGet(t *testing.T) {
 	}{
 		{"Existing short URL", shortID, http.StatusTemporaryRedirect, longURL},
 		{"Non-existing short URL", "nonexistent", http.StatusNotFound, ""},
+		{"Expired short URL", "expired", http.StatusGone, ""},
 	}
 
 	for _, tt := range tests {
@@ -165,6 +173,125 @@ func TestHandleJSONPost(t *testing.T) {
 	}
 }
 
+func TestHandleJSONPost_Alias(t *testing.T) {
+	r, _ := setupRouter(t)
+
+	tests := []struct {
+		name           string
+		requestBody    string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "Свободный псевдоним",
+			requestBody:    `{"url": "https://alias.com", "alias": "promo"}`,
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"result":"http://localhost:8080/promo"}`,
+		},
+		{
+			name:           "Занятый псевдоним",
+			requestBody:    `{"url": "https://other.com", "alias": "promo"}`,
+			expectedStatus: http.StatusConflict,
+			expectedBody:   "Alias already taken\n",
+		},
+		{
+			name:           "Срок действия",
+			requestBody:    `{"url": "https://ttl.com", "alias": "ttl-link", "ttl": "24h"}`,
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"result":"http://localhost:8080/ttl-link"}`,
+		},
+		{
+			name:           "Одноразовая ссылка",
+			requestBody:    `{"url": "https://once.com", "alias": "once", "max_clicks": 1}`,
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"result":"http://localhost:8080/once"}`,
+		},
+		{
+			name:           "Срок действия в прошлом",
+			requestBody:    `{"url": "https://past.com", "expires_at": "2000-01-01T00:00:00Z"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "Invalid expiry\n",
+		},
+		{
+			name:           "Постоянное перенаправление с passthrough",
+			requestBody:    `{"url": "https://promo.com/landing?ref=1", "alias": "sale", "redirect_code": 301, "passthrough": true}`,
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"result":"http://localhost:8080/sale"}`,
+		},
+		{
+			name:           "Постоянное перенаправление с ограничением переходов",
+			requestBody:    `{"url": "https://limited.com", "alias": "limited", "redirect_code": 308, "max_clicks": 2}`,
+			expectedStatus: http.StatusCreated,
+			expectedBody:   `{"result":"http://localhost:8080/limited"}`,
+		},
+		{
+			name:           "Неподдерживаемый код перенаправления",
+			requestBody:    `{"url": "https://bad-code.com", "redirect_code": 200}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "Invalid redirect_code\n",
+		},
+		{
+			name:           "Зарезервированный псевдоним",
+			requestBody:    `{"url": "https://other.com", "alias": "api"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "Invalid alias\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("POST", "/api/shorten", bytes.NewBufferString(tt.requestBody))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			req.AddCookie(&http.Cookie{
+				Name:  "user_token",
+				Value: "testuser:testsignature",
+			})
+
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == http.StatusCreated {
+				assert.JSONEq(t, tt.expectedBody, rr.Body.String())
+			} else {
+				assert.Equal(t, tt.expectedBody, rr.Body.String())
+			}
+		})
+	}
+
+	t.Run("Перенаправление с passthrough", func(t *testing.T) {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/sale/spring/shoes?utm_source=ads", nil))
+		assert.Equal(t, http.StatusMovedPermanently, rr.Code)
+		assert.Equal(t, "https://promo.com/landing/spring/shoes?ref=1&utm_source=ads", rr.Header().Get("Location"))
+		assert.Empty(t, rr.Header().Get("Cache-Control"), "Ссылку без ограничений можно кэшировать")
+
+		rr = httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/promo/extra?utm_source=ads", nil))
+		assert.Equal(t, http.StatusNotFound, rr.Code, "Продолжение пути только для ссылок с passthrough")
+	})
+
+	t.Run("Постоянное перенаправление ограниченной ссылки не кэшируется", func(t *testing.T) {
+		for _, expectedStatus := range []int{http.StatusPermanentRedirect, http.StatusPermanentRedirect, http.StatusGone} {
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/limited", nil))
+			require.Equal(t, expectedStatus, rr.Code)
+			if expectedStatus == http.StatusPermanentRedirect {
+				assert.Equal(t, "no-store", rr.Header().Get("Cache-Control"))
+			}
+		}
+	})
+
+	t.Run("Переходы по одноразовой ссылке", func(t *testing.T) {
+		for _, expectedStatus := range []int{http.StatusTemporaryRedirect, http.StatusGone, http.StatusGone} {
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/once", nil))
+			assert.Equal(t, expectedStatus, rr.Code)
+		}
+	})
+}
+
 func TestHandlePing(t *testing.T) {
 	r, _ := setupRouter(t)
 
@@ -12,8 +12,10 @@ import (
 	"go.uber.org/zap"
 )
 
-// NewRouter создает и настраивает маршрутизатор HTTP запросов
-func NewRouter(cfg *config.Config, urlService *service.URLService, logger *zap.Logger, store storage.Storage) chi.Router {
+// NewRouter создает и настраивает маршрутизатор HTTP запросов,
+// opts передаются обработчику URL
+func NewRouter(cfg *config.Config, urlService *service.URLService, logger *zap.Logger, store storage.Storage, purger handlers.Purger,
+	opts ...handlers.Option) chi.Router {
 	r := chi.NewRouter()
 
 	r.Use(middleware.Logger(logger))
@@ -22,13 +24,19 @@ func NewRouter(cfg *config.Config, urlService *service.URLService, logger *zap.L
 	r.Use(middleware.DBContextMiddleware(store))
 	r.Use(middleware.AuthMiddleware) // Добавляем middleware аутентификации
 
-	handler := handlers.NewURLHandler(cfg, urlService, logger)
+	handler := handlers.NewURLHandler(cfg, urlService, logger, opts...)
 
 	r.Group(func(r chi.Router) {
 		r.Use(middleware.GETLogger(logger))
 		r.Get("/{shortID}", handler.HandleGet)
+		r.Get("/{shortID}/*", handler.HandleGet)    // Продолжение пути для ссылок с passthrough
+		r.Get("/{shortID}+", handler.HandlePreview) // Предпросмотр адреса назначения
 		r.Get("/ping", handler.HandlePing)
+		r.Get("/api/qr/{shortID}", handler.HandleQR)       // QR-код короткого URL
 		r.Get("/api/user/urls", handler.HandleGetUserURLs) // Новый handler
+		r.Get("/api/user/urls/export", handler.HandleExportURLs)
+		r.Get("/api/user/urls/{shortID}/history", handler.HandleGetURLHistory)
+		r.Get("/api/user/tags", handler.HandleGetUserTags)
 	})
 
 	// Применяем логгер для всех POST запросов
@@ -37,9 +45,24 @@ func NewRouter(cfg *config.Config, urlService *service.URLService, logger *zap.L
 		r.Post("/", handler.HandlePost)
 		r.Post("/api/shorten", handler.HandleJSONPost)
 		r.Post("/api/shorten/batch", handler.HandleBatchShorten)
+		r.Post("/api/user/urls/restore", handler.HandleRestoreURLs)
+		r.Post("/api/user/urls/import", handler.HandleImportURLs)
+		r.Post("/{shortID}", handler.HandleUnlock) // Пароль защищенной ссылки
+		r.Post("/{shortID}/*", handler.HandleUnlock)
 	})
 
 	r.Delete("/api/user/urls", handler.HandleDeleteURLs)
+	r.Patch("/api/user/urls/{shortID}", handler.HandleUpdateURL)
+	r.Put("/api/user/urls/{shortID}/routing", handler.HandleSetRouting)
+	r.Delete("/api/user/urls/{shortID}/routing", handler.HandleDeleteRouting)
+
+	// Служебные эндпоинты доступны только с токеном администратора
+	adminHandler := handlers.NewAdminHandler(purger, logger)
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.AdminMiddleware(cfg.AdminToken))
+		r.Post("/api/admin/purge", adminHandler.HandlePurge)
+		r.Get("/api/admin/vars", adminHandler.HandleVars) // Метрики, в том числе счетчики кэша
+	})
 
 	return r
 }
@@ -3,7 +3,9 @@ package app
 
 import (
 	"context"
+	"expvar"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,7 +13,9 @@ import (
 	"time"
 
 	"github.com/Eorthus/shorturl/internal/api"
+	"github.com/Eorthus/shorturl/internal/api/handlers"
 	"github.com/Eorthus/shorturl/internal/config"
+	"github.com/Eorthus/shorturl/internal/idgen"
 	"github.com/Eorthus/shorturl/internal/service"
 	"github.com/Eorthus/shorturl/internal/storage"
 	"github.com/Eorthus/shorturl/internal/tls"
@@ -24,6 +28,7 @@ type Application struct {
 	logger  *zap.Logger
 	srv     *http.Server
 	storage storage.Storage
+	purger  *Purger
 }
 
 // New создает новое приложение
@@ -36,11 +41,48 @@ func New(cfg *config.Config, logger *zap.Logger) (*Application, error) {
 		return nil, fmt.Errorf("failed to initialize storage: %w", err)
 	}
 
+	// Публикуем счетчики кэша в /api/admin/vars
+	if cached, ok := store.(*storage.CachedStorage); ok && expvar.Get("storage_cache") == nil {
+		expvar.Publish("storage_cache", expvar.Func(func() any {
+			return cached.Stats()
+		}))
+	}
+
+	// Генератор коротких идентификаторов
+	ids, err := idgen.New(idgen.Options{
+		Strategy: cfg.ShortIDStrategy,
+		Alphabet: cfg.ShortIDAlphabet,
+		Length:   cfg.ShortIDLength,
+		Key:      cfg.ShortIDKey,
+	})
+	if err != nil {
+		if closer, ok := store.(io.Closer); ok {
+			closer.Close()
+		}
+		return nil, fmt.Errorf("invalid short ID settings: %w", err)
+	}
+
+	// Страница ссылок, которые еще не начали работать
+	var handlerOpts []handlers.Option
+	if cfg.ComingSoonPage != "" {
+		page, err := handlers.LoadComingSoonPage(cfg.ComingSoonPage)
+		if err != nil {
+			if closer, ok := store.(io.Closer); ok {
+				closer.Close()
+			}
+			return nil, fmt.Errorf("invalid coming soon page: %w", err)
+		}
+		handlerOpts = append(handlerOpts, handlers.WithComingSoonPage(page))
+	}
+
 	// Инициализация сервиса
-	urlService := service.NewURLService(store)
+	urlService := service.NewURLService(store, service.WithIDGenerator(ids))
+
+	// Очистка удаленных URL
+	purger := NewPurger(store, cfg.DeletedRetention, cfg.PurgeInterval, cfg.PurgeBatchSize, logger)
 
 	// Инициализация роутера
-	router := api.NewRouter(cfg, urlService, logger, store)
+	router := api.NewRouter(cfg, urlService, logger, store, purger, handlerOpts...)
 
 	// Создаем HTTP сервер
 	srv := &http.Server{
@@ -53,6 +95,7 @@ func New(cfg *config.Config, logger *zap.Logger) (*Application, error) {
 		logger:  logger,
 		srv:     srv,
 		storage: store,
+		purger:  purger,
 	}, nil
 }
 
@@ -69,6 +112,19 @@ func (a *Application) Run(ctx context.Context) error {
 	// Канал для ошибок сервера
 	errChan := make(chan error, 1)
 
+	// Плановая очистка останавливается до закрытия хранилища
+	purgeCtx, stopPurge := context.WithCancel(ctx)
+	purgeDone := make(chan struct{})
+	go func() {
+		defer close(purgeDone)
+		a.purger.Run(purgeCtx)
+	}()
+	stopPurging := func() {
+		stopPurge()
+		<-purgeDone
+	}
+	defer stopPurging()
+
 	// Запускаем сервер в отдельной горутине
 	go func() {
 		a.logger.Info("Starting server",
@@ -76,6 +132,11 @@ func (a *Application) Run(ctx context.Context) error {
 			zap.String("base_url", a.cfg.BaseURL),
 			zap.String("file_storage_path", a.cfg.FileStoragePath),
 			zap.String("database_dsn", a.cfg.DatabaseDSN),
+			zap.String("bolt_storage_path", a.cfg.BoltStoragePath),
+			zap.Int("cache_size", a.cfg.CacheSize),
+			zap.String("short_id_strategy", a.cfg.ShortIDStrategy),
+			zap.Duration("deleted_retention", a.cfg.DeletedRetention),
+			zap.Duration("purge_interval", a.cfg.PurgeInterval),
 			zap.Bool("https_enabled", a.cfg.EnableHTTPS),
 		)
 
@@ -113,6 +174,7 @@ func (a *Application) Run(ctx context.Context) error {
 		a.logger.Info("Shutdown requested through context")
 	}
 
+	stopPurging()
 	return a.Shutdown()
 }
 
@@ -128,6 +190,22 @@ func (a *Application) Shutdown() error {
 		return fmt.Errorf("server shutdown error: %w", err)
 	}
 
+	// Закрываем хранилище, если оно держит ресурсы
+	if closer, ok := a.storage.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			a.logger.Error("Storage close error", zap.Error(err))
+			return fmt.Errorf("storage close error: %w", err)
+		}
+	}
+
+	if cached, ok := a.storage.(*storage.CachedStorage); ok {
+		stats := cached.Stats()
+		a.logger.Info("Storage cache stats",
+			zap.Int64("hits", stats.Hits),
+			zap.Int64("misses", stats.Misses),
+		)
+	}
+
 	a.logger.Info("Server shutdown complete")
 	return nil
 }
@@ -0,0 +1,101 @@
+package app
+
+import (
+	"context"
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/Eorthus/shorturl/internal/storage"
+	"go.uber.org/zap"
+)
+
+// purgedURLs общее количество окончательно удаленных URL, публикуется в /api/admin/vars
+var purgedURLs = expvar.NewInt("purged_urls")
+
+// Purger периодически окончательно удаляет URL, помеченные удаленными
+// или истекшие дольше срока хранения.
+//
+// Очистка идет пакетами по batchSize, каждый пакет — отдельная операция
+// хранилища, поэтому блокировки не удерживаются надолго.
+// Плановый и ручной запуски не выполняются одновременно.
+type Purger struct {
+	store     storage.Storage
+	retention time.Duration
+	interval  time.Duration
+	batchSize int
+	logger    *zap.Logger
+
+	mutex sync.Mutex
+	now   func() time.Time
+}
+
+// NewPurger создает очистку удаленных URL.
+// Нулевой или отрицательный interval отключает плановый запуск.
+func NewPurger(store storage.Storage, retention, interval time.Duration, batchSize int, logger *zap.Logger) *Purger {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	return &Purger{
+		store:     store,
+		retention: retention,
+		interval:  interval,
+		batchSize: batchSize,
+		logger:    logger,
+		now:       time.Now,
+	}
+}
+
+// Run запускает очистку по расписанию и блокирует до отмены ctx
+func (p *Purger) Run(ctx context.Context) {
+	if p.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// Ошибка уже записана в лог, следующий запуск продолжит с того же места
+			_, _ = p.Purge(ctx)
+		}
+	}
+}
+
+// Purge удаляет пакетами все URL, удаленные или истекшие раньше срока хранения,
+// и возвращает их количество. При ошибке возвращается количество URL,
+// удаленных до нее.
+func (p *Purger) Purge(ctx context.Context) (int, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	start := p.now()
+	before := start.Add(-p.retention)
+	total, batches := 0, 0
+	for {
+		purged, err := p.store.PurgeDeletedURLs(ctx, before, p.batchSize)
+		total += len(purged)
+		purgedURLs.Add(int64(len(purged)))
+		if err != nil {
+			p.logger.Error("Failed to purge deleted URLs", zap.Error(err), zap.Int("purged", total))
+			return total, err
+		}
+		// Неполный пакет не означает конца: кандидатов могли восстановить во время очистки
+		if len(purged) == 0 {
+			break
+		}
+		batches++
+	}
+
+	p.logger.Info("Deleted URLs purged",
+		zap.Int("purged", total),
+		zap.Int("batches", batches),
+		zap.Time("deleted_before", before),
+		zap.Duration("duration", p.now().Sub(start)),
+	)
+	return total, nil
+}
@@ -0,0 +1,178 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+
+	"github.com/Eorthus/shorturl/internal/models"
+	"github.com/Eorthus/shorturl/internal/storage"
+)
+
+// failingStorage возвращает ошибку после заданного числа пакетов очистки
+type failingStorage struct {
+	storage.Storage
+	batches int
+}
+
+func (s *failingStorage) PurgeDeletedURLs(ctx context.Context, before time.Time, limit int) ([]models.URLData, error) {
+	if s.batches == 0 {
+		return nil, errors.New("storage failure")
+	}
+	s.batches--
+	return s.Storage.PurgeDeletedURLs(ctx, before, limit)
+}
+
+// shortBatchStorage отдает первый пакет очистки неполным, как если бы одного
+// кандидата восстановили между отбором и удалением
+type shortBatchStorage struct {
+	storage.Storage
+	calls int
+}
+
+func (s *shortBatchStorage) PurgeDeletedURLs(ctx context.Context, before time.Time, limit int) ([]models.URLData, error) {
+	s.calls++
+	if s.calls == 1 {
+		limit--
+	}
+	return s.Storage.PurgeDeletedURLs(ctx, before, limit)
+}
+
+func newPurgeStorage(t *testing.T, now time.Time) storage.Storage {
+	store, err := storage.NewMemoryStorage(context.Background())
+	require.NoError(t, err)
+
+	old := now.Add(-48 * time.Hour)
+	recent := now.Add(-time.Hour)
+	urls := []models.URLData{
+		{ShortURL: "recent", OriginalURL: "https://recent.example.com", UserID: "user1", IsDeleted: true, CreatedAt: old, DeletedAt: &recent},
+		{ShortURL: "alive", OriginalURL: "https://alive.example.com", UserID: "user1", CreatedAt: old},
+	}
+	for i := range 5 {
+		urls = append(urls, models.URLData{
+			ShortURL:    fmt.Sprintf("old%d", i),
+			OriginalURL: fmt.Sprintf("https://old.example.com/%d", i),
+			UserID:      "user1",
+			IsDeleted:   true,
+			CreatedAt:   old,
+			DeletedAt:   &old,
+		})
+	}
+	require.NoError(t, store.SaveURLData(context.Background(), urls))
+	return store
+}
+
+func TestPurger_Purge(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Удаляет пакетами только устаревшие URL", func(t *testing.T) {
+		store := newPurgeStorage(t, now)
+		purger := NewPurger(store, 24*time.Hour, 0, 2, zaptest.NewLogger(t))
+		purger.now = func() time.Time { return now }
+
+		purged, err := purger.Purge(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 5, purged)
+
+		urls, err := store.GetUserURLs(ctx, "user1")
+		require.NoError(t, err)
+		require.Len(t, urls, 2)
+
+		purged, err = purger.Purge(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, purged, "Повторная очистка не должна ничего удалять")
+	})
+
+	t.Run("Неполный пакет не завершает очистку", func(t *testing.T) {
+		store := &shortBatchStorage{Storage: newPurgeStorage(t, now)}
+		purger := NewPurger(store, 24*time.Hour, 0, 3, zaptest.NewLogger(t))
+		purger.now = func() time.Time { return now }
+
+		purged, err := purger.Purge(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 5, purged)
+		assert.Equal(t, 3, store.calls, "Очистка заканчивается только пустым пакетом")
+	})
+
+	t.Run("Восстановление во время очистки", func(t *testing.T) {
+		store, err := storage.NewMemoryStorage(ctx)
+		require.NoError(t, err)
+		const links = 200
+		shortIDs := make([]string, links)
+		for i := range links {
+			shortIDs[i] = fmt.Sprintf("undo%d", i)
+			require.NoError(t, store.SaveURL(ctx, shortIDs[i], fmt.Sprintf("https://undo%d.example.com", i), "user1"))
+		}
+		require.NoError(t, store.MarkURLsAsDeleted(ctx, shortIDs, "user1"))
+
+		purger := NewPurger(store, -time.Hour, 0, 5, zaptest.NewLogger(t))
+		restored := make(map[string]bool)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := len(shortIDs) - 1; i >= 0; i -= 2 {
+				ids, err := store.RestoreURLs(ctx, []string{shortIDs[i]}, "user1")
+				assert.NoError(t, err)
+				for _, id := range ids {
+					restored[id] = true
+				}
+			}
+		}()
+		_, err = purger.Purge(ctx)
+		require.NoError(t, err)
+		<-done
+
+		for _, shortID := range shortIDs {
+			url, found, err := store.GetURLData(ctx, shortID)
+			require.NoError(t, err)
+			if restored[shortID] {
+				require.True(t, found, shortID)
+				assert.False(t, url.IsDeleted, shortID)
+			} else {
+				assert.False(t, found, "Удаленная ссылка %s должна быть очищена за один запуск", shortID)
+			}
+		}
+	})
+
+	t.Run("Ошибка возвращает уже удаленное количество", func(t *testing.T) {
+		store := &failingStorage{Storage: newPurgeStorage(t, now), batches: 1}
+		purger := NewPurger(store, 24*time.Hour, 0, 2, zaptest.NewLogger(t))
+		purger.now = func() time.Time { return now }
+
+		purged, err := purger.Purge(ctx)
+		assert.Error(t, err)
+		assert.Equal(t, 2, purged)
+	})
+}
+
+func TestPurger_Run(t *testing.T) {
+	now := time.Now()
+	store := newPurgeStorage(t, now)
+	purger := NewPurger(store, 24*time.Hour, 10*time.Millisecond, 100, zaptest.NewLogger(t))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		purger.Run(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		urls, err := store.GetUserURLs(context.Background(), "user1")
+		return err == nil && len(urls) == 2
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run должен завершаться при отмене контекста")
+	}
+}
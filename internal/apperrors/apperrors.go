@@ -31,6 +31,36 @@ var (
 	ErrInvalidJSONFormat = AppError{Status: http.StatusBadRequest, Message: "Invalid JSON format"}
 	// ErrEmptyURL возникает при попытке сохранить пустой URL
 	ErrEmptyURL = AppError{Status: http.StatusBadRequest, Message: "Empty URL"}
+	// ErrInvalidQuery возникает при некорректных параметрах выборки
+	ErrInvalidQuery = AppError{Status: http.StatusBadRequest, Message: "Invalid query parameters"}
+	// ErrInvalidAlias возникает при недопустимом пользовательском псевдониме
+	ErrInvalidAlias = AppError{Status: http.StatusBadRequest, Message: "Invalid alias"}
+	// ErrInvalidExpiry возникает при некорректном сроке действия ссылки
+	ErrInvalidExpiry = AppError{Status: http.StatusBadRequest, Message: "Invalid expiry"}
+	// ErrInvalidNotBefore возникает, если время активации не раньше окончания действия ссылки
+	ErrInvalidNotBefore = AppError{Status: http.StatusBadRequest, Message: "Invalid not_before"}
+	// ErrInvalidMaxClicks возникает при отрицательном ограничении числа переходов
+	ErrInvalidMaxClicks = AppError{Status: http.StatusBadRequest, Message: "Invalid max_clicks"}
+	// ErrInvalidRedirectCode возникает при неподдерживаемом коде перенаправления
+	ErrInvalidRedirectCode = AppError{Status: http.StatusBadRequest, Message: "Invalid redirect_code"}
+	// ErrInvalidTags возникает при недопустимых метках ссылки
+	ErrInvalidTags = AppError{Status: http.StatusBadRequest, Message: "Invalid tags"}
+	// ErrInvalidTitle возникает при слишком длинном или многострочном заголовке
+	ErrInvalidTitle = AppError{Status: http.StatusBadRequest, Message: "Invalid title"}
+	// ErrInvalidNote возникает при слишком длинной заметке
+	ErrInvalidNote = AppError{Status: http.StatusBadRequest, Message: "Invalid note"}
+	// ErrInvalidRouting возникает при некорректных правилах выбора адреса назначения
+	ErrInvalidRouting = AppError{Status: http.StatusBadRequest, Message: "Invalid routing"}
+	// ErrInvalidQRParams возникает при недопустимых параметрах QR-кода
+	ErrInvalidQRParams = AppError{Status: http.StatusBadRequest, Message: "Invalid QR code parameters"}
+	// ErrInvalidCSV возникает при нечитаемом CSV или заголовке без колонки original_url
+	ErrInvalidCSV = AppError{Status: http.StatusBadRequest, Message: "Invalid CSV"}
+	// ErrImportTooLarge возникает, если в импортируемом файле слишком много строк
+	ErrImportTooLarge = AppError{Status: http.StatusRequestEntityTooLarge, Message: "Too many rows to import"}
+	// ErrImportFileTooLarge возникает, если импортируемый файл превышает допустимый размер
+	ErrImportFileTooLarge = AppError{Status: http.StatusRequestEntityTooLarge, Message: "Import file too large"}
+	// ErrAliasTaken возникает, если псевдоним уже занят другой ссылкой
+	ErrAliasTaken = AppError{Status: http.StatusConflict, Message: "Alias already taken"}
 )
 
 // HandleHTTPError обрабатывает ошибку и отправляет соответствующий HTTP-ответ
@@ -4,7 +4,9 @@ package config
 import (
 	"flag"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/caarlos0/env/v6"
 )
@@ -14,11 +16,36 @@ type Config struct {
 	ServerAddress   string `env:"SERVER_ADDRESS" envDefault:"localhost:8080"`
 	BaseURL         string `env:"BASE_URL" envDefault:"http://localhost:8080"`
 	FileStoragePath string `env:"FILE_STORAGE_PATH" envDefault:"url_storage.json"`
-	DatabaseDSN     string `env:"DATABASE_DSN" envDefault:""`
-	EnableHTTPS     bool   `env:"ENABLE_HTTPS" envDefault:"false"`
-	CertFile        string `env:"CERT_FILE" envDefault:"server.crt"`
-	KeyFile         string `env:"KEY_FILE" envDefault:"server.key"`
-	ConfigFile      string `env:"CONFIG" envDefault:""`
+	FileCompactSize int64  `env:"FILE_STORAGE_COMPACT_SIZE" envDefault:"4194304"`
+	// FileSync режим сброса файлового журнала на диск: always или none
+	FileSync        string        `env:"FILE_STORAGE_SYNC" envDefault:"always"`
+	DatabaseDSN     string        `env:"DATABASE_DSN" envDefault:""`
+	BoltStoragePath string        `env:"BOLT_STORAGE_PATH" envDefault:""`
+	CacheSize       int           `env:"CACHE_SIZE" envDefault:"0"`
+	CacheTTL        time.Duration `env:"CACHE_TTL" envDefault:"5m"`
+	// Генерация коротких идентификаторов, см. пакет idgen
+	ShortIDStrategy string `env:"SHORT_ID_STRATEGY" envDefault:"random"`
+	ShortIDAlphabet string `env:"SHORT_ID_ALPHABET" envDefault:"base62"`
+	ShortIDLength   int    `env:"SHORT_ID_LENGTH" envDefault:"8"`
+	ShortIDKey      string `env:"SHORT_ID_KEY" envDefault:""`
+	// DeletedRetention срок, после которого удаленные URL удаляются окончательно
+	DeletedRetention time.Duration `env:"DELETED_RETENTION" envDefault:"720h"`
+	// PurgeInterval период фоновой очистки, 0 отключает ее
+	PurgeInterval  time.Duration `env:"PURGE_INTERVAL" envDefault:"1h"`
+	PurgeBatchSize int           `env:"PURGE_BATCH_SIZE" envDefault:"500"`
+	// Ограничение попыток ввода пароля защищенной ссылки с одного адреса
+	UnlockAttempts int           `env:"UNLOCK_ATTEMPTS" envDefault:"5"`
+	UnlockWindow   time.Duration `env:"UNLOCK_WINDOW" envDefault:"1m"`
+	// Ответ на переход по ссылке до ее времени активации: код и путь к HTML-шаблону,
+	// пустой путь включает встроенную страницу
+	ComingSoonStatus int    `env:"COMING_SOON_STATUS" envDefault:"503"`
+	ComingSoonPage   string `env:"COMING_SOON_PAGE" envDefault:""`
+	// AdminToken токен доступа к /api/admin, пустой токен отключает эти эндпоинты
+	AdminToken  string `env:"ADMIN_TOKEN" envDefault:""`
+	EnableHTTPS bool   `env:"ENABLE_HTTPS" envDefault:"false"`
+	CertFile    string `env:"CERT_FILE" envDefault:"server.crt"`
+	KeyFile     string `env:"KEY_FILE" envDefault:"server.key"`
+	ConfigFile  string `env:"CONFIG" envDefault:""`
 }
 
 // ParseConfig создает конфигурацию из переменных окружения.
@@ -39,6 +66,23 @@ func DefineFlags(cfg *Config) {
 	flag.StringVar(&cfg.BaseURL, "b", cfg.BaseURL, "Base address for shortened URL")                 // Базовый URL для сокращенных ссылок
 	flag.StringVar(&cfg.FileStoragePath, "f", cfg.FileStoragePath, "File storage path for URL data") // Путь к файлу хранения
 	flag.StringVar(&cfg.DatabaseDSN, "d", cfg.DatabaseDSN, "Database connection string")             // Строка подключения к базе данных
+	flag.StringVar(&cfg.BoltStoragePath, "bolt", cfg.BoltStoragePath, "Embedded database file path")
+	flag.IntVar(&cfg.CacheSize, "cache-size", cfg.CacheSize, "Storage cache size, 0 disables caching")
+	flag.DurationVar(&cfg.CacheTTL, "cache-ttl", cfg.CacheTTL, "Storage cache entry lifetime")
+	flag.StringVar(&cfg.ShortIDStrategy, "id-strategy", cfg.ShortIDStrategy, "Short ID strategy: random or permuted")
+	flag.StringVar(&cfg.ShortIDAlphabet, "id-alphabet", cfg.ShortIDAlphabet, "Short ID alphabet: base62, unambiguous or a custom character set")
+	flag.IntVar(&cfg.ShortIDLength, "id-length", cfg.ShortIDLength, "Short ID length, at most 10")
+	flag.StringVar(&cfg.ShortIDKey, "id-key", cfg.ShortIDKey, "Permutation key for the permuted short ID strategy")
+	flag.DurationVar(&cfg.DeletedRetention, "deleted-retention", cfg.DeletedRetention, "How long deleted URLs are kept before purging")
+	flag.DurationVar(&cfg.PurgeInterval, "purge-interval", cfg.PurgeInterval, "Deleted URL purge interval, 0 disables purging")
+	flag.IntVar(&cfg.PurgeBatchSize, "purge-batch-size", cfg.PurgeBatchSize, "Deleted URLs purged per batch")
+	flag.StringVar(&cfg.AdminToken, "admin-token", cfg.AdminToken, "Token for admin endpoints, empty disables them")
+	flag.IntVar(&cfg.UnlockAttempts, "unlock-attempts", cfg.UnlockAttempts, "Password attempts per client and link within the unlock window")
+	flag.DurationVar(&cfg.UnlockWindow, "unlock-window", cfg.UnlockWindow, "Window for limiting password attempts")
+	flag.IntVar(&cfg.ComingSoonStatus, "coming-soon-status", cfg.ComingSoonStatus, "HTTP status for links that are not active yet")
+	flag.StringVar(&cfg.ComingSoonPage, "coming-soon-page", cfg.ComingSoonPage, "HTML template for links that are not active yet, empty uses the built-in page")
+	flag.Int64Var(&cfg.FileCompactSize, "compact-size", cfg.FileCompactSize, "File storage journal size that triggers compaction")
+	flag.StringVar(&cfg.FileSync, "file-sync", cfg.FileSync, "File storage sync mode: always flushes every write to disk, none leaves it to the OS")
 	flag.BoolVar(&cfg.EnableHTTPS, "s", false, "Enable HTTPS")
 	flag.StringVar(&cfg.CertFile, "cert", cfg.CertFile, "Path to SSL certificate file")
 	flag.StringVar(&cfg.KeyFile, "key", cfg.KeyFile, "Path to SSL private key file")
@@ -57,9 +101,80 @@ func ApplyPriority(cfg *Config) {
 	if envFilePath := os.Getenv("FILE_STORAGE_PATH"); envFilePath != "" {
 		cfg.FileStoragePath = envFilePath
 	}
+	if envCompactSize := os.Getenv("FILE_STORAGE_COMPACT_SIZE"); envCompactSize != "" {
+		if size, err := strconv.ParseInt(envCompactSize, 10, 64); err == nil {
+			cfg.FileCompactSize = size
+		}
+	}
+	if envFileSync := os.Getenv("FILE_STORAGE_SYNC"); envFileSync != "" {
+		cfg.FileSync = envFileSync
+	}
 	if envDatabaseDSN := os.Getenv("DATABASE_DSN"); envDatabaseDSN != "" {
 		cfg.DatabaseDSN = envDatabaseDSN
 	}
+	if envBoltPath := os.Getenv("BOLT_STORAGE_PATH"); envBoltPath != "" {
+		cfg.BoltStoragePath = envBoltPath
+	}
+	if envCacheSize := os.Getenv("CACHE_SIZE"); envCacheSize != "" {
+		if size, err := strconv.Atoi(envCacheSize); err == nil {
+			cfg.CacheSize = size
+		}
+	}
+	if envCacheTTL := os.Getenv("CACHE_TTL"); envCacheTTL != "" {
+		if ttl, err := time.ParseDuration(envCacheTTL); err == nil {
+			cfg.CacheTTL = ttl
+		}
+	}
+	if envStrategy := os.Getenv("SHORT_ID_STRATEGY"); envStrategy != "" {
+		cfg.ShortIDStrategy = envStrategy
+	}
+	if envAlphabet := os.Getenv("SHORT_ID_ALPHABET"); envAlphabet != "" {
+		cfg.ShortIDAlphabet = envAlphabet
+	}
+	if envLength := os.Getenv("SHORT_ID_LENGTH"); envLength != "" {
+		if length, err := strconv.Atoi(envLength); err == nil {
+			cfg.ShortIDLength = length
+		}
+	}
+	if envKey := os.Getenv("SHORT_ID_KEY"); envKey != "" {
+		cfg.ShortIDKey = envKey
+	}
+	if envRetention := os.Getenv("DELETED_RETENTION"); envRetention != "" {
+		if retention, err := time.ParseDuration(envRetention); err == nil {
+			cfg.DeletedRetention = retention
+		}
+	}
+	if envPurgeInterval := os.Getenv("PURGE_INTERVAL"); envPurgeInterval != "" {
+		if interval, err := time.ParseDuration(envPurgeInterval); err == nil {
+			cfg.PurgeInterval = interval
+		}
+	}
+	if envPurgeBatch := os.Getenv("PURGE_BATCH_SIZE"); envPurgeBatch != "" {
+		if size, err := strconv.Atoi(envPurgeBatch); err == nil {
+			cfg.PurgeBatchSize = size
+		}
+	}
+	if envAdminToken := os.Getenv("ADMIN_TOKEN"); envAdminToken != "" {
+		cfg.AdminToken = envAdminToken
+	}
+	if envUnlockAttempts := os.Getenv("UNLOCK_ATTEMPTS"); envUnlockAttempts != "" {
+		if attempts, err := strconv.Atoi(envUnlockAttempts); err == nil {
+			cfg.UnlockAttempts = attempts
+		}
+	}
+	if envUnlockWindow := os.Getenv("UNLOCK_WINDOW"); envUnlockWindow != "" {
+		if window, err := time.ParseDuration(envUnlockWindow); err == nil {
+			cfg.UnlockWindow = window
+		}
+	}
+	if envComingSoonStatus := os.Getenv("COMING_SOON_STATUS"); envComingSoonStatus != "" {
+		if status, err := strconv.Atoi(envComingSoonStatus); err == nil {
+			cfg.ComingSoonStatus = status
+		}
+	}
+	if envComingSoonPage := os.Getenv("COMING_SOON_PAGE"); envComingSoonPage != "" {
+		cfg.ComingSoonPage = envComingSoonPage
+	}
 	if envEnableHTTPS := os.Getenv("ENABLE_HTTPS"); envEnableHTTPS != "" {
 		cfg.EnableHTTPS = envEnableHTTPS == "true"
 	}
@@ -6,17 +6,35 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // JsonConfig представляет структуру JSON конфигурации
 type JSONConfig struct {
-	ServerAddress   string `json:"server_address"`
-	BaseURL         string `json:"base_url"`
-	FileStoragePath string `json:"file_storage_path"`
-	DatabaseDSN     string `json:"database_dsn"`
-	EnableHTTPS     bool   `json:"enable_https"`
-	CertFile        string `json:"cert_file"`
-	KeyFile         string `json:"key_file"`
+	ServerAddress    string `json:"server_address"`
+	BaseURL          string `json:"base_url"`
+	FileStoragePath  string `json:"file_storage_path"`
+	FileCompactSize  int64  `json:"file_storage_compact_size"`
+	FileSync         string `json:"file_storage_sync"`
+	DatabaseDSN      string `json:"database_dsn"`
+	BoltStoragePath  string `json:"bolt_storage_path"`
+	CacheSize        int    `json:"cache_size"`
+	CacheTTL         string `json:"cache_ttl"`
+	ShortIDStrategy  string `json:"short_id_strategy"`
+	ShortIDAlphabet  string `json:"short_id_alphabet"`
+	ShortIDLength    int    `json:"short_id_length"`
+	ShortIDKey       string `json:"short_id_key"`
+	DeletedRetention string `json:"deleted_retention"`
+	PurgeInterval    string `json:"purge_interval"`
+	PurgeBatchSize   int    `json:"purge_batch_size"`
+	AdminToken       string `json:"admin_token"`
+	UnlockAttempts   int    `json:"unlock_attempts"`
+	UnlockWindow     string `json:"unlock_window"`
+	ComingSoonStatus int    `json:"coming_soon_status"`
+	ComingSoonPage   string `json:"coming_soon_page"`
+	EnableHTTPS      bool   `json:"enable_https"`
+	CertFile         string `json:"cert_file"`
+	KeyFile          string `json:"key_file"`
 }
 
 // LoadJSON загружает конфигурацию из JSON файла
@@ -71,9 +89,60 @@ func (cfg *Config) ApplyJSON(jsonCfg *JSONConfig) {
 	if jsonCfg.FileStoragePath != "" {
 		cfg.FileStoragePath = jsonCfg.FileStoragePath
 	}
+	if jsonCfg.FileCompactSize != 0 {
+		cfg.FileCompactSize = jsonCfg.FileCompactSize
+	}
+	if jsonCfg.FileSync != "" {
+		cfg.FileSync = jsonCfg.FileSync
+	}
 	if jsonCfg.DatabaseDSN != "" {
 		cfg.DatabaseDSN = jsonCfg.DatabaseDSN
 	}
+	if jsonCfg.BoltStoragePath != "" {
+		cfg.BoltStoragePath = jsonCfg.BoltStoragePath
+	}
+	if jsonCfg.CacheSize != 0 {
+		cfg.CacheSize = jsonCfg.CacheSize
+	}
+	if ttl, err := time.ParseDuration(jsonCfg.CacheTTL); err == nil {
+		cfg.CacheTTL = ttl
+	}
+	if jsonCfg.ShortIDStrategy != "" {
+		cfg.ShortIDStrategy = jsonCfg.ShortIDStrategy
+	}
+	if jsonCfg.ShortIDAlphabet != "" {
+		cfg.ShortIDAlphabet = jsonCfg.ShortIDAlphabet
+	}
+	if jsonCfg.ShortIDLength != 0 {
+		cfg.ShortIDLength = jsonCfg.ShortIDLength
+	}
+	if jsonCfg.ShortIDKey != "" {
+		cfg.ShortIDKey = jsonCfg.ShortIDKey
+	}
+	if retention, err := time.ParseDuration(jsonCfg.DeletedRetention); err == nil {
+		cfg.DeletedRetention = retention
+	}
+	if interval, err := time.ParseDuration(jsonCfg.PurgeInterval); err == nil {
+		cfg.PurgeInterval = interval
+	}
+	if jsonCfg.PurgeBatchSize != 0 {
+		cfg.PurgeBatchSize = jsonCfg.PurgeBatchSize
+	}
+	if jsonCfg.AdminToken != "" {
+		cfg.AdminToken = jsonCfg.AdminToken
+	}
+	if jsonCfg.UnlockAttempts != 0 {
+		cfg.UnlockAttempts = jsonCfg.UnlockAttempts
+	}
+	if window, err := time.ParseDuration(jsonCfg.UnlockWindow); err == nil {
+		cfg.UnlockWindow = window
+	}
+	if jsonCfg.ComingSoonStatus != 0 {
+		cfg.ComingSoonStatus = jsonCfg.ComingSoonStatus
+	}
+	if jsonCfg.ComingSoonPage != "" {
+		cfg.ComingSoonPage = jsonCfg.ComingSoonPage
+	}
 	if jsonCfg.EnableHTTPS {
 		cfg.EnableHTTPS = true
 	}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -106,6 +107,88 @@ func TestConfig_ApplyJSON(t *testing.T) {
 				BaseURL:       "http://default",
 			},
 		},
+		{
+			name: "Apply unlock limits",
+			base: &Config{
+				UnlockAttempts: 5,
+				UnlockWindow:   time.Minute,
+			},
+			json: &JSONConfig{
+				UnlockAttempts: 3,
+				UnlockWindow:   "10m",
+			},
+			expected: &Config{
+				UnlockAttempts: 3,
+				UnlockWindow:   10 * time.Minute,
+			},
+		},
+		{
+			name: "Apply coming soon response",
+			base: &Config{
+				ComingSoonStatus: 503,
+			},
+			json: &JSONConfig{
+				ComingSoonStatus: 404,
+				ComingSoonPage:   "coming_soon.html",
+			},
+			expected: &Config{
+				ComingSoonStatus: 404,
+				ComingSoonPage:   "coming_soon.html",
+			},
+		},
+		{
+			name: "Apply cache settings",
+			base: &Config{
+				CacheTTL: 5 * time.Minute,
+			},
+			json: &JSONConfig{
+				CacheSize: 1000,
+				CacheTTL:  "30s",
+			},
+			expected: &Config{
+				CacheSize: 1000,
+				CacheTTL:  30 * time.Second,
+			},
+		},
+		{
+			name: "Apply short ID settings",
+			base: &Config{
+				ShortIDStrategy: "random",
+				ShortIDAlphabet: "base62",
+				ShortIDLength:   8,
+			},
+			json: &JSONConfig{
+				ShortIDStrategy: "permuted",
+				ShortIDAlphabet: "unambiguous",
+				ShortIDLength:   6,
+				ShortIDKey:      "key",
+			},
+			expected: &Config{
+				ShortIDStrategy: "permuted",
+				ShortIDAlphabet: "unambiguous",
+				ShortIDLength:   6,
+				ShortIDKey:      "key",
+			},
+		},
+		{
+			name: "Apply purge settings",
+			base: &Config{
+				DeletedRetention: 720 * time.Hour,
+				PurgeInterval:    time.Hour,
+				PurgeBatchSize:   500,
+			},
+			json: &JSONConfig{
+				DeletedRetention: "168h",
+				PurgeInterval:    "0s",
+				PurgeBatchSize:   100,
+				AdminToken:       "secret",
+			},
+			expected: &Config{
+				DeletedRetention: 168 * time.Hour,
+				PurgeBatchSize:   100,
+				AdminToken:       "secret",
+			},
+		},
 		{
 			name: "Empty JSON config",
 			base: &Config{
@@ -0,0 +1,374 @@
+// Package dump переносит URL между хранилищами через переносимую выгрузку.
+//
+// Поддерживаются форматы NDJSON (одна запись JSON на строку) и CSV
+// с заголовком. Каждая запись содержит короткий и оригинальный URL,
+// владельца, признак удаления, время создания и время удаления.
+package dump
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Eorthus/shorturl/internal/models"
+)
+
+// Format формат выгрузки
+type Format string
+
+// Поддерживаемые форматы выгрузки
+const (
+	FormatNDJSON Format = "ndjson"
+	FormatCSV    Format = "csv"
+)
+
+// csvHeader колонки CSV-выгрузки
+var csvHeader = []string{"short_url", "original_url", "user_id", "is_deleted", "created_at", "deleted_at", "expires_at", "clicks_left", "password_hash", "redirect_code", "passthrough", "tags", "note", "routing", "title", "not_before"}
+
+// ParseFormat разбирает название формата
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case FormatNDJSON, "jsonl", "json":
+		return FormatNDJSON, nil
+	case FormatCSV:
+		return FormatCSV, nil
+	default:
+		return "", fmt.Errorf("unknown dump format %q", name)
+	}
+}
+
+// FormatFromPath определяет формат по расширению файла, по умолчанию NDJSON
+func FormatFromPath(path string) Format {
+	if strings.HasSuffix(strings.ToLower(path), ".csv") {
+		return FormatCSV
+	}
+	return FormatNDJSON
+}
+
+// record запись выгрузки в формате NDJSON
+type record struct {
+	ShortURL    string     `json:"short_url"`
+	OriginalURL string     `json:"original_url"`
+	UserID      string     `json:"user_id"`
+	IsDeleted   bool       `json:"is_deleted"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	ClicksLeft  *int       `json:"clicks_left,omitempty"`
+	// PasswordHash переносится как есть, пароль при выгрузке не раскрывается
+	PasswordHash string `json:"password_hash,omitempty"`
+	RedirectCode int    `json:"redirect_code,omitempty"`
+	Passthrough  bool   `json:"passthrough,omitempty"`
+	// Tags в CSV записываются через пробел
+	Tags []string `json:"tags,omitempty"`
+	Note string   `json:"note,omitempty"`
+	// Routing в CSV записываются в JSON
+	Routing   *models.Routing `json:"routing,omitempty"`
+	Title     string          `json:"title,omitempty"`
+	NotBefore *time.Time      `json:"not_before,omitempty"`
+}
+
+// Encoder записывает URL в выгрузку
+type Encoder interface {
+	// Encode записывает один URL
+	Encode(url models.URLData) error
+	// Flush дописывает буферизованные данные
+	Flush() error
+}
+
+// Decoder читает URL из выгрузки.
+// В конце выгрузки Decode возвращает io.EOF.
+type Decoder interface {
+	Decode() (models.URLData, error)
+}
+
+// NewEncoder создает кодировщик выгрузки в указанном формате
+func NewEncoder(w io.Writer, format Format) (Encoder, error) {
+	switch format {
+	case FormatNDJSON:
+		bw := bufio.NewWriter(w)
+		return &ndjsonEncoder{w: bw, enc: json.NewEncoder(bw)}, nil
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(csvHeader); err != nil {
+			return nil, err
+		}
+		return &csvEncoder{w: cw}, nil
+	default:
+		return nil, fmt.Errorf("unknown dump format %q", format)
+	}
+}
+
+// NewDecoder создает декодировщик выгрузки в указанном формате
+func NewDecoder(r io.Reader, format Format) (Decoder, error) {
+	switch format {
+	case FormatNDJSON:
+		return &ndjsonDecoder{dec: json.NewDecoder(r)}, nil
+	case FormatCSV:
+		return newCSVDecoder(r)
+	default:
+		return nil, fmt.Errorf("unknown dump format %q", format)
+	}
+}
+
+type ndjsonEncoder struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (e *ndjsonEncoder) Encode(url models.URLData) error {
+	return e.enc.Encode(record{
+		ShortURL:     url.ShortURL,
+		OriginalURL:  url.OriginalURL,
+		UserID:       url.UserID,
+		IsDeleted:    url.IsDeleted,
+		CreatedAt:    url.CreatedAt.UTC(),
+		DeletedAt:    utcTime(url.DeletedAt),
+		ExpiresAt:    utcTime(url.ExpiresAt),
+		ClicksLeft:   url.ClicksLeft,
+		PasswordHash: url.PasswordHash,
+		RedirectCode: url.RedirectCode,
+		Passthrough:  url.Passthrough,
+		Tags:         url.Tags,
+		Note:         url.Note,
+		Routing:      url.Routing,
+		Title:        url.Title,
+		NotBefore:    utcTime(url.NotBefore),
+	})
+}
+
+func (e *ndjsonEncoder) Flush() error {
+	return e.w.Flush()
+}
+
+type ndjsonDecoder struct {
+	dec *json.Decoder
+	n   int
+}
+
+func (d *ndjsonDecoder) Decode() (models.URLData, error) {
+	var rec record
+	if err := d.dec.Decode(&rec); err != nil {
+		if errors.Is(err, io.EOF) {
+			return models.URLData{}, io.EOF
+		}
+		return models.URLData{}, fmt.Errorf("record %d: %w", d.n+1, err)
+	}
+	d.n++
+
+	url := models.URLData{
+		ShortURL:     rec.ShortURL,
+		OriginalURL:  rec.OriginalURL,
+		UserID:       rec.UserID,
+		IsDeleted:    rec.IsDeleted,
+		CreatedAt:    rec.CreatedAt,
+		DeletedAt:    rec.DeletedAt,
+		ExpiresAt:    rec.ExpiresAt,
+		ClicksLeft:   rec.ClicksLeft,
+		PasswordHash: rec.PasswordHash,
+		RedirectCode: rec.RedirectCode,
+		Passthrough:  rec.Passthrough,
+		Tags:         rec.Tags,
+		Note:         rec.Note,
+		Routing:      rec.Routing,
+		Title:        rec.Title,
+		NotBefore:    rec.NotBefore,
+	}
+	return url, validate(url, d.n)
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (e *csvEncoder) Encode(url models.URLData) error {
+	redirectCode := ""
+	if url.RedirectCode != 0 {
+		redirectCode = strconv.Itoa(url.RedirectCode)
+	}
+	routing := ""
+	if url.Routing != nil {
+		data, err := json.Marshal(url.Routing)
+		if err != nil {
+			return err
+		}
+		routing = string(data)
+	}
+	return e.w.Write([]string{
+		url.ShortURL,
+		url.OriginalURL,
+		url.UserID,
+		strconv.FormatBool(url.IsDeleted),
+		url.CreatedAt.UTC().Format(time.RFC3339Nano),
+		formatTime(url.DeletedAt),
+		formatTime(url.ExpiresAt),
+		formatInt(url.ClicksLeft),
+		url.PasswordHash,
+		redirectCode,
+		strconv.FormatBool(url.Passthrough),
+		strings.Join(url.Tags, " "),
+		url.Note,
+		routing,
+		url.Title,
+		formatTime(url.NotBefore),
+	})
+}
+
+// formatInt форматирует необязательное число для CSV, nil дает пустую строку
+func formatInt(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
+
+// formatTime форматирует необязательное время для CSV, nil дает пустую строку
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// utcTime приводит необязательное время к UTC
+func utcTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	utc := t.UTC()
+	return &utc
+}
+
+func (e *csvEncoder) Flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+type csvDecoder struct {
+	r       *csv.Reader
+	columns map[string]int
+	n       int
+}
+
+// newCSVDecoder читает заголовок; колонки могут идти в любом порядке,
+// обязательны short_url и original_url
+func newCSVDecoder(r io.Reader) (*csvDecoder, error) {
+	cr := csv.NewReader(r)
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	for _, required := range csvHeader[:2] {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV header has no %q column", required)
+		}
+	}
+
+	return &csvDecoder{r: cr, columns: columns}, nil
+}
+
+func (d *csvDecoder) Decode() (models.URLData, error) {
+	row, err := d.r.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return models.URLData{}, io.EOF
+		}
+		return models.URLData{}, fmt.Errorf("record %d: %w", d.n+1, err)
+	}
+	d.n++
+
+	field := func(name string) string {
+		if i, ok := d.columns[name]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	url := models.URLData{
+		ShortURL:     field("short_url"),
+		OriginalURL:  field("original_url"),
+		UserID:       field("user_id"),
+		PasswordHash: field("password_hash"),
+	}
+	if value := field("is_deleted"); value != "" {
+		if url.IsDeleted, err = strconv.ParseBool(value); err != nil {
+			return models.URLData{}, fmt.Errorf("record %d: invalid is_deleted: %w", d.n, err)
+		}
+	}
+	if value := field("created_at"); value != "" {
+		if url.CreatedAt, err = time.Parse(time.RFC3339Nano, value); err != nil {
+			return models.URLData{}, fmt.Errorf("record %d: invalid created_at: %w", d.n, err)
+		}
+	}
+	optionalTime := func(name string) (*time.Time, error) {
+		value := field(name)
+		if value == "" {
+			return nil, nil
+		}
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, fmt.Errorf("record %d: invalid %s: %w", d.n, name, err)
+		}
+		return &t, nil
+	}
+	if url.DeletedAt, err = optionalTime("deleted_at"); err != nil {
+		return models.URLData{}, err
+	}
+	if url.ExpiresAt, err = optionalTime("expires_at"); err != nil {
+		return models.URLData{}, err
+	}
+	if url.NotBefore, err = optionalTime("not_before"); err != nil {
+		return models.URLData{}, err
+	}
+	if value := field("clicks_left"); value != "" {
+		clicksLeft, err := strconv.Atoi(value)
+		if err != nil || clicksLeft < 0 {
+			return models.URLData{}, fmt.Errorf("record %d: invalid clicks_left %q", d.n, value)
+		}
+		url.ClicksLeft = &clicksLeft
+	}
+	if value := field("redirect_code"); value != "" {
+		if url.RedirectCode, err = strconv.Atoi(value); err != nil {
+			return models.URLData{}, fmt.Errorf("record %d: invalid redirect_code: %w", d.n, err)
+		}
+	}
+	if value := field("passthrough"); value != "" {
+		if url.Passthrough, err = strconv.ParseBool(value); err != nil {
+			return models.URLData{}, fmt.Errorf("record %d: invalid passthrough: %w", d.n, err)
+		}
+	}
+	if tags := strings.Fields(field("tags")); len(tags) > 0 {
+		url.Tags = tags
+	}
+	url.Note = field("note")
+	url.Title = field("title")
+	if value := field("routing"); value != "" {
+		url.Routing = &models.Routing{}
+		if err := json.Unmarshal([]byte(value), url.Routing); err != nil {
+			return models.URLData{}, fmt.Errorf("record %d: invalid routing: %w", d.n, err)
+		}
+	}
+	return url, validate(url, d.n)
+}
+
+// validate проверяет обязательные поля записи
+func validate(url models.URLData, n int) error {
+	if url.ShortURL == "" || url.OriginalURL == "" {
+		return fmt.Errorf("record %d: short_url and original_url are required", n)
+	}
+	if url.RedirectCode != 0 && !models.ValidRedirectCode(url.RedirectCode) {
+		return fmt.Errorf("record %d: unsupported redirect_code %d", n, url.RedirectCode)
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package dump
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Eorthus/shorturl/internal/models"
+	"github.com/Eorthus/shorturl/internal/storage"
+)
+
+var (
+	testCreatedAt  = time.Date(2024, 5, 6, 7, 8, 9, 123000000, time.UTC)
+	testDeletedAt  = testCreatedAt.Add(time.Hour)
+	testNotBefore  = testCreatedAt.Add(2 * time.Hour)
+	testExpiresAt  = testCreatedAt.Add(24 * time.Hour)
+	testClicksLeft = 3
+)
+
+// newSourceStorage создает хранилище с URL двух пользователей, один из которых удален
+func newSourceStorage(t *testing.T) storage.Storage {
+	store, err := storage.NewMemoryStorage(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, store.SaveURLData(context.Background(), []models.URLData{
+		{ShortURL: "a1", OriginalURL: "https://a1.com", UserID: "alice", CreatedAt: testCreatedAt, NotBefore: &testNotBefore, ExpiresAt: &testExpiresAt},
+		{ShortURL: "b1", OriginalURL: "https://b1.com", UserID: "bob", CreatedAt: testCreatedAt.Add(time.Second), ClicksLeft: &testClicksLeft, PasswordHash: "hash",
+			RedirectCode: 302, Passthrough: true, Tags: []string{"promo", "spring"}, Note: "Заметка, с запятой", Title: "Акция",
+			Routing: &models.Routing{Variants: []models.RoutingVariant{{Name: "a", URL: "https://a.com", Weight: 1}}}},
+		{ShortURL: "a2", OriginalURL: "https://a2.com", UserID: "alice", IsDeleted: true, CreatedAt: testCreatedAt.Add(2 * time.Second), DeletedAt: &testDeletedAt},
+	}))
+	return store
+}
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+
+	for _, format := range []Format{FormatNDJSON, FormatCSV} {
+		t.Run(string(format), func(t *testing.T) {
+			var buf bytes.Buffer
+			count, err := Export(ctx, newSourceStorage(t), &buf, format)
+			require.NoError(t, err)
+			assert.Equal(t, 3, count)
+
+			target, err := storage.NewFileStorage(ctx, filepath.Join(t.TempDir(), "urls.json"))
+			require.NoError(t, err)
+			defer target.Close()
+
+			stats, err := Import(ctx, target, bytes.NewReader(buf.Bytes()), format, ImportOptions{BatchSize: 2})
+			require.NoError(t, err)
+			assert.Equal(t, ImportStats{Read: 3, Imported: 3}, stats)
+
+			urls, err := target.GetUserURLs(ctx, "alice")
+			require.NoError(t, err)
+			require.Len(t, urls, 2)
+			assert.Equal(t, "a1", urls[0].ShortURL)
+			assert.True(t, testCreatedAt.Equal(urls[0].CreatedAt), "Время создания должно сохраняться")
+			assert.Equal(t, "a2", urls[1].ShortURL)
+			assert.True(t, urls[1].IsDeleted, "Признак удаления должен сохраняться")
+			require.NotNil(t, urls[1].DeletedAt)
+			assert.True(t, testDeletedAt.Equal(*urls[1].DeletedAt), "Время удаления должно сохраняться")
+			assert.Nil(t, urls[0].DeletedAt)
+			require.NotNil(t, urls[0].ExpiresAt)
+			assert.True(t, testExpiresAt.Equal(*urls[0].ExpiresAt), "Срок действия должен сохраняться")
+			assert.Nil(t, urls[1].ExpiresAt)
+			require.NotNil(t, urls[0].NotBefore)
+			assert.True(t, testNotBefore.Equal(*urls[0].NotBefore), "Время активации должно сохраняться")
+
+			b1, _, err := target.GetURLData(ctx, "b1")
+			assert.NoError(t, err)
+			assert.False(t, b1.IsDeleted)
+			require.NotNil(t, b1.ClicksLeft, "Остаток переходов должен сохраняться")
+			assert.Equal(t, testClicksLeft, *b1.ClicksLeft)
+			assert.Equal(t, "hash", b1.PasswordHash, "Хеш пароля должен сохраняться")
+			assert.Equal(t, 302, b1.RedirectCode, "Код перенаправления должен сохраняться")
+			assert.True(t, b1.Passthrough)
+			assert.Equal(t, []string{"promo", "spring"}, b1.Tags, "Метки должны сохраняться")
+			assert.Equal(t, "Заметка, с запятой", b1.Note)
+			assert.Equal(t, "Акция", b1.Title, "Заголовок должен сохраняться")
+			assert.Empty(t, urls[0].Tags)
+			require.NotNil(t, b1.Routing, "Правила выбора адреса должны сохраняться")
+			assert.Equal(t, []models.RoutingVariant{{Name: "a", URL: "https://a.com", Weight: 1}}, b1.Routing.Variants)
+			assert.Nil(t, urls[0].Routing)
+		})
+	}
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+
+	var dump bytes.Buffer
+	_, err := Export(ctx, newSourceStorage(t), &dump, FormatNDJSON)
+	require.NoError(t, err)
+
+	t.Run("Пробный запуск ничего не сохраняет", func(t *testing.T) {
+		target, err := storage.NewMemoryStorage(ctx)
+		require.NoError(t, err)
+
+		stats, err := Import(ctx, target, bytes.NewReader(dump.Bytes()), FormatNDJSON, ImportOptions{DryRun: true})
+		require.NoError(t, err)
+		assert.Equal(t, ImportStats{Read: 3, Imported: 3}, stats)
+
+		longURL, _, err := target.GetURL(ctx, "a1")
+		assert.NoError(t, err)
+		assert.Empty(t, longURL)
+	})
+
+	t.Run("Продолжение прерванного импорта", func(t *testing.T) {
+		target, err := storage.NewMemoryStorage(ctx)
+		require.NoError(t, err)
+		require.NoError(t, target.SaveURLData(ctx, []models.URLData{
+			{ShortURL: "a1", OriginalURL: "https://a1.com", UserID: "alice", CreatedAt: testCreatedAt},
+		}))
+
+		_, err = Import(ctx, target, bytes.NewReader(dump.Bytes()), FormatNDJSON, ImportOptions{})
+		assert.ErrorIs(t, err, storage.ErrShortIDExists, "Без resume существующая запись является конфликтом")
+
+		stats, err := Import(ctx, target, bytes.NewReader(dump.Bytes()), FormatNDJSON, ImportOptions{Resume: true})
+		require.NoError(t, err)
+		assert.Equal(t, ImportStats{Read: 3, Imported: 2, Skipped: 1}, stats)
+	})
+
+	t.Run("Конфликт с другими данными", func(t *testing.T) {
+		target, err := storage.NewMemoryStorage(ctx)
+		require.NoError(t, err)
+		require.NoError(t, target.SaveURL(ctx, "other", "https://b1.com", "carol"))
+
+		stats, err := Import(ctx, target, bytes.NewReader(dump.Bytes()), FormatNDJSON, ImportOptions{Resume: true, BatchSize: 1})
+		assert.ErrorIs(t, err, storage.ErrURLExists)
+		assert.Equal(t, 1, stats.Imported, "Пакеты до конфликта остаются сохраненными")
+	})
+
+	t.Run("Дубликаты внутри выгрузки", func(t *testing.T) {
+		input := `{"short_url":"x1","original_url":"https://x.com"}
+{"short_url":"x2","original_url":"https://x.com"}
+`
+		for _, dryRun := range []bool{true, false} {
+			target, err := storage.NewMemoryStorage(ctx)
+			require.NoError(t, err)
+
+			_, err = Import(ctx, target, strings.NewReader(input), FormatNDJSON, ImportOptions{DryRun: dryRun})
+			assert.ErrorIs(t, err, storage.ErrURLExists)
+		}
+	})
+}
+
+func TestDecoder(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  Format
+		input   string
+		wantErr string
+	}{
+		{
+			name:   "CSV с другим порядком колонок",
+			format: FormatCSV,
+			input:  "original_url,short_url\nhttps://a.com,a\n",
+		},
+		{
+			name:    "CSV без обязательной колонки",
+			format:  FormatCSV,
+			input:   "short_url,user_id\na,alice\n",
+			wantErr: `CSV header has no "original_url" column`,
+		},
+		{
+			name:    "CSV с некорректной датой",
+			format:  FormatCSV,
+			input:   "short_url,original_url,created_at\na,https://a.com,yesterday\n",
+			wantErr: "record 1: invalid created_at",
+		},
+		{
+			name:    "CSV с некорректным сроком действия",
+			format:  FormatCSV,
+			input:   "short_url,original_url,expires_at\na,https://a.com,tomorrow\n",
+			wantErr: "record 1: invalid expires_at",
+		},
+		{
+			name:    "NDJSON без оригинального URL",
+			format:  FormatNDJSON,
+			input:   `{"short_url":"a"}` + "\n",
+			wantErr: "record 1: short_url and original_url are required",
+		},
+		{
+			name:    "Поврежденный NDJSON",
+			format:  FormatNDJSON,
+			input:   `{"short_url":"a","original_url":"https://a.com"}` + "\n{broken\n",
+			wantErr: "record 2:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec, err := NewDecoder(strings.NewReader(tt.input), tt.format)
+			for err == nil {
+				_, err = dec.Decode()
+			}
+			if tt.wantErr == "" {
+				assert.ErrorIs(t, err, io.EOF)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("CSV")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatCSV, format)
+
+	format, err = ParseFormat("jsonl")
+	assert.NoError(t, err)
+	assert.Equal(t, FormatNDJSON, format)
+
+	_, err = ParseFormat("xml")
+	assert.Error(t, err)
+
+	assert.Equal(t, FormatCSV, FormatFromPath("backup.CSV"))
+	assert.Equal(t, FormatNDJSON, FormatFromPath("backup.ndjson"))
+}
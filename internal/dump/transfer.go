@@ -0,0 +1,156 @@
+package dump
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Eorthus/shorturl/internal/models"
+	"github.com/Eorthus/shorturl/internal/storage"
+)
+
+// DefaultBatchSize количество записей, сохраняемых за одну операцию импорта
+const DefaultBatchSize = 500
+
+// ImportOptions настраивает импорт
+type ImportOptions struct {
+	// BatchSize количество записей в одной атомарной операции сохранения
+	BatchSize int
+	// Resume пропускает записи, которые уже есть в хранилище в том же виде,
+	// что позволяет повторить прерванный импорт
+	Resume bool
+	// DryRun проверяет выгрузку и конфликты, ничего не сохраняя
+	DryRun bool
+}
+
+// ImportStats итоги импорта
+type ImportStats struct {
+	Read     int
+	Imported int
+	Skipped  int
+}
+
+// Export записывает все URL хранилища в w и возвращает их количество
+func Export(ctx context.Context, store storage.Storage, w io.Writer, format Format) (int, error) {
+	enc, err := NewEncoder(w, format)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	err = store.IterateURLs(ctx, func(url models.URLData) error {
+		count++
+		return enc.Encode(url)
+	})
+	if err != nil {
+		return count, fmt.Errorf("failed to export URLs: %w", err)
+	}
+
+	return count, enc.Flush()
+}
+
+// Import загружает URL из r пакетами по opts.BatchSize.
+//
+// Каждый пакет сохраняется атомарно, поэтому после сбоя импорт можно
+// повторить с Resume: уже загруженные записи будут пропущены.
+// Запись, конфликтующая с другими данными хранилища, прерывает импорт.
+func Import(ctx context.Context, store storage.Storage, r io.Reader, format Format, opts ImportOptions) (ImportStats, error) {
+	var stats ImportStats
+
+	dec, err := NewDecoder(r, format)
+	if err != nil {
+		return stats, err
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+
+	// Дубликаты внутри выгрузки при сохранении ловит хранилище,
+	// при пробном запуске их приходится отслеживать самим
+	var seenShort, seenLong map[string]bool
+	if opts.DryRun {
+		seenShort = make(map[string]bool)
+		seenLong = make(map[string]bool)
+	}
+
+	batch := make([]models.URLData, 0, opts.BatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if !opts.DryRun {
+			if err := store.SaveURLData(ctx, batch); err != nil {
+				return fmt.Errorf("failed to save records %d-%d: %w", stats.Read-len(batch)+1, stats.Read, err)
+			}
+		}
+		stats.Imported += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		url, err := dec.Decode()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return stats, err
+		}
+		stats.Read++
+
+		exists, err := checkExisting(ctx, store, url, opts.Resume)
+		if err != nil {
+			return stats, fmt.Errorf("record %d: %w", stats.Read, err)
+		}
+		if exists {
+			stats.Skipped++
+			continue
+		}
+
+		if opts.DryRun {
+			if seenLong[url.OriginalURL] {
+				return stats, fmt.Errorf("record %d: duplicate in dump: %w", stats.Read, storage.ErrURLExists)
+			}
+			if seenShort[url.ShortURL] {
+				return stats, fmt.Errorf("record %d: duplicate in dump: %w", stats.Read, storage.ErrShortIDExists)
+			}
+			seenShort[url.ShortURL] = true
+			seenLong[url.OriginalURL] = true
+		}
+
+		batch = append(batch, url)
+		if len(batch) == opts.BatchSize {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+	}
+
+	return stats, flush()
+}
+
+// checkExisting проверяет, есть ли запись в хранилище.
+// Совпадающая запись при resume пропускается, любая другая встреча
+// с занятым коротким или длинным URL считается конфликтом.
+func checkExisting(ctx context.Context, store storage.Storage, url models.URLData, resume bool) (bool, error) {
+	longURL, _, err := store.GetURL(ctx, url.ShortURL)
+	if err != nil {
+		return false, err
+	}
+	if longURL != "" {
+		if resume && longURL == url.OriginalURL {
+			return true, nil
+		}
+		return false, fmt.Errorf("short URL %q: %w", url.ShortURL, storage.ErrShortIDExists)
+	}
+
+	shortID, err := store.GetShortIDByLongURL(ctx, url.OriginalURL)
+	if err != nil {
+		return false, err
+	}
+	if shortID != "" {
+		return false, fmt.Errorf("original URL %q: %w", url.OriginalURL, storage.ErrURLExists)
+	}
+	return false, nil
+}
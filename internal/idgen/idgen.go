@@ -0,0 +1,160 @@
+// Package idgen генерирует короткие идентификаторы URL.
+//
+// Поддерживаются стратегии:
+//   - random: случайные идентификаторы из заданного алфавита
+//   - permuted: счетчик в памяти, перемешанный обратимой перестановкой, поэтому
+//     идентификаторы не выдают порядок создания и не повторяются в пределах
+//     процесса. Счетчик не сохраняется между запусками, см. PermutedGenerator.
+//
+// Алфавит задается набором символов или именем предустановки:
+// base62 либо unambiguous (без похожих символов 0/O/o, 1/l/I).
+package idgen
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// IDGenerator выдает новые короткие идентификаторы
+type IDGenerator interface {
+	// NewID возвращает новый короткий идентификатор
+	NewID() (string, error)
+}
+
+// Стратегии генерации
+const (
+	StrategyRandom   = "random"
+	StrategyPermuted = "permuted"
+)
+
+// Предустановленные алфавиты
+const (
+	// AlphabetBase62 цифры и латинские буквы обоих регистров
+	AlphabetBase62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	// AlphabetUnambiguous base62 без символов, которые легко спутать при чтении
+	AlphabetUnambiguous = "23456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnpqrstuvwxyz"
+)
+
+// Ограничения длины идентификатора
+const (
+	// DefaultLength длина идентификатора по умолчанию
+	DefaultLength = 8
+	// MaxLength оставляет сгенерированные идентификаторы короче пользовательских псевдонимов
+	MaxLength = 10
+)
+
+// urlSafe символы, допустимые в коротком идентификаторе без экранирования.
+// Точка исключена: идентификаторы из точек совпадают с сегментами . и .. пути.
+const urlSafe = AlphabetBase62 + "-_~"
+
+// reserved первые сегменты путей сервиса, которые не выдаются генераторами
+// и не принимаются как пользовательские псевдонимы
+var reserved = map[string]bool{
+	"api":   true,
+	"ping":  true,
+	"debug": true,
+	"admin": true,
+}
+
+// Reserved сообщает, совпадает ли идентификатор с маршрутом сервиса без учета регистра
+func Reserved(id string) bool {
+	return reserved[strings.ToLower(id)]
+}
+
+// Options параметры генератора
+type Options struct {
+	// Strategy стратегия: random (по умолчанию) или permuted
+	Strategy string
+	// Alphabet набор символов или имя предустановки, по умолчанию base62
+	Alphabet string
+	// Length длина идентификатора, по умолчанию DefaultLength
+	Length int
+	// Key ключ перестановки стратегии permuted
+	Key string
+}
+
+// New создает генератор по параметрам
+func New(opts Options) (IDGenerator, error) {
+	alphabet, err := ResolveAlphabet(opts.Alphabet)
+	if err != nil {
+		return nil, err
+	}
+	length := opts.Length
+	if length == 0 {
+		length = DefaultLength
+	}
+
+	switch strings.ToLower(opts.Strategy) {
+	case "", StrategyRandom:
+		return NewRandom(alphabet, length)
+	case StrategyPermuted:
+		return NewPermuted(alphabet, length, opts.Key)
+	default:
+		return nil, fmt.Errorf("unknown short ID strategy %q", opts.Strategy)
+	}
+}
+
+// ResolveAlphabet возвращает алфавит по имени предустановки
+// или проверяет переданный набор символов
+func ResolveAlphabet(alphabet string) (string, error) {
+	switch strings.ToLower(alphabet) {
+	case "", "base62":
+		return AlphabetBase62, nil
+	case "unambiguous":
+		return AlphabetUnambiguous, nil
+	}
+	return alphabet, validateAlphabet(alphabet)
+}
+
+// validateAlphabet проверяет, что символы уникальны и допустимы в URL
+func validateAlphabet(alphabet string) error {
+	if len(alphabet) < 2 {
+		return errors.New("alphabet must contain at least 2 characters")
+	}
+	seen := make(map[rune]bool, len(alphabet))
+	for _, c := range alphabet {
+		if !strings.ContainsRune(urlSafe, c) {
+			return fmt.Errorf("alphabet character %q is not URL-safe", c)
+		}
+		if seen[c] {
+			return fmt.Errorf("alphabet character %q is repeated", c)
+		}
+		seen[c] = true
+	}
+	return nil
+}
+
+// validateLength проверяет длину идентификатора
+func validateLength(length int) error {
+	if length < 1 || length > MaxLength {
+		return fmt.Errorf("short ID length must be between 1 and %d, got %d", MaxLength, length)
+	}
+	return nil
+}
+
+// spaceSize возвращает количество идентификаторов len(alphabet)^length
+// или ошибку, если оно не помещается в uint64
+func spaceSize(alphabet string, length int) (uint64, error) {
+	size := uint64(1)
+	for range length {
+		hi, lo := bits.Mul64(size, uint64(len(alphabet)))
+		if hi != 0 {
+			return 0, errors.New("short ID space is too large")
+		}
+		size = lo
+	}
+	return size, nil
+}
+
+// encode записывает n в системе счисления алфавита ровно length символами
+func encode(n uint64, alphabet string, length int) string {
+	base := uint64(len(alphabet))
+	buf := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		buf[i] = alphabet[n%base]
+		n /= base
+	}
+	return string(buf)
+}
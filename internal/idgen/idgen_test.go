@@ -0,0 +1,134 @@
+package idgen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		length  int
+		wantErr string
+	}{
+		{name: "По умолчанию", opts: Options{}, length: DefaultLength},
+		{name: "Перестановка счетчика", opts: Options{Strategy: "permuted", Length: 6, Key: "k"}, length: 6},
+		{name: "Однозначный алфавит", opts: Options{Alphabet: "unambiguous", Length: 10}, length: 10},
+		{name: "Свой алфавит", opts: Options{Alphabet: "abc", Length: 4}, length: 4},
+		{name: "Неизвестная стратегия", opts: Options{Strategy: "uuid"}, wantErr: "unknown short ID strategy"},
+		{name: "Прежнее имя стратегии", opts: Options{Strategy: "sequential"}, wantErr: "unknown short ID strategy"},
+		{name: "Длина больше колонки", opts: Options{Length: 11}, wantErr: "between 1 and 10"},
+		{name: "Повтор символа", opts: Options{Alphabet: "abca"}, wantErr: "repeated"},
+		{name: "Небезопасный символ", opts: Options{Alphabet: "ab/"}, wantErr: "not URL-safe"},
+		{name: "Точка в алфавите", opts: Options{Alphabet: "ab."}, wantErr: "not URL-safe"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gen, err := New(tt.opts)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			id, err := gen.NewID()
+			require.NoError(t, err)
+			assert.Len(t, id, tt.length)
+		})
+	}
+}
+
+func TestRandomGenerator(t *testing.T) {
+	gen, err := NewRandom(AlphabetUnambiguous, 8)
+	require.NoError(t, err)
+
+	seen := make(map[string]bool)
+	for range 1000 {
+		id, err := gen.NewID()
+		require.NoError(t, err)
+		require.Len(t, id, 8)
+		for _, c := range id {
+			assert.True(t, strings.ContainsRune(AlphabetUnambiguous, c), "Символ %q вне алфавита", c)
+		}
+		assert.False(t, seen[id], "Идентификаторы не должны повторяться")
+		seen[id] = true
+	}
+
+	for _, c := range "0Oo1lI" {
+		assert.NotContains(t, AlphabetUnambiguous, string(c))
+	}
+}
+
+func TestReserved(t *testing.T) {
+	assert.True(t, Reserved("api"))
+	assert.True(t, Reserved("Admin"))
+	assert.False(t, Reserved("apis"))
+
+	// В алфавите "aip" длины 3 ровно один идентификатор совпадает с маршрутом
+	random, err := NewRandom("aip", 3)
+	require.NoError(t, err)
+	for range 500 {
+		id, err := random.NewID()
+		require.NoError(t, err)
+		assert.NotEqual(t, "api", id)
+	}
+
+	permuted, err := NewPermuted("aip", 3, "key")
+	require.NoError(t, err)
+	seen := make(map[string]bool, 26)
+	for range 26 {
+		id, err := permuted.NewID()
+		require.NoError(t, err)
+		assert.NotEqual(t, "api", id)
+		assert.False(t, seen[id], "Идентификатор %s повторился", id)
+		seen[id] = true
+	}
+	assert.Len(t, seen, 26, "Выдаются все идентификаторы, кроме зарезервированного")
+}
+
+func TestPermutedGenerator(t *testing.T) {
+	t.Run("Перестановка всего пространства", func(t *testing.T) {
+		// 3^5 = 243 идентификатора: полный обход счетчика дает каждый ровно один раз
+		gen, err := NewPermuted("abc", 5, "key")
+		require.NoError(t, err)
+
+		seen := make(map[string]bool, 243)
+		for range 243 {
+			id, err := gen.NewID()
+			require.NoError(t, err)
+			assert.False(t, seen[id], "Идентификатор %s повторился", id)
+			seen[id] = true
+		}
+		assert.Len(t, seen, 243)
+	})
+
+	t.Run("Соседние номера не идут подряд", func(t *testing.T) {
+		gen, err := NewPermuted(AlphabetBase62, 8, "key")
+		require.NoError(t, err)
+		gen.counter.Store(1000)
+
+		first, err := gen.NewID()
+		require.NoError(t, err)
+		second, err := gen.NewID()
+		require.NoError(t, err)
+		assert.NotEqual(t, first[:6], second[:6])
+	})
+
+	t.Run("Ключ определяет перестановку", func(t *testing.T) {
+		a, err := NewPermuted(AlphabetBase62, 8, "one")
+		require.NoError(t, err)
+		b, err := NewPermuted(AlphabetBase62, 8, "one")
+		require.NoError(t, err)
+		c, err := NewPermuted(AlphabetBase62, 8, "two")
+		require.NoError(t, err)
+
+		assert.Equal(t, a.permute(42), b.permute(42))
+		assert.NotEqual(t, a.permute(42), c.permute(42))
+	})
+}
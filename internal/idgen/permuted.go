@@ -0,0 +1,115 @@
+package idgen
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"sync/atomic"
+)
+
+// feistelRounds количество раундов сети Фейстеля
+const feistelRounds = 4
+
+// PermutedGenerator выдает номера счетчика, перемешанные обратимой перестановкой.
+//
+// Перестановка — сеть Фейстеля над пространством len(alphabet)^length
+// с доводкой циклом, поэтому разные номера всегда дают разные идентификаторы.
+//
+// Счетчик хранится только в памяти и при создании генератора начинается
+// со случайного значения. Идентификаторы не повторяются в пределах одного
+// процесса, пока не исчерпано пространство, но после перезапуска или в
+// нескольких экземплярах сервиса новый отрезок счетчика может пересечься
+// с уже выданными, как у случайной стратегии. Такие коллизии обнаруживает
+// хранилище, и сервис повторяет попытку со свежим идентификатором.
+type PermutedGenerator struct {
+	alphabet string
+	length   int
+	space    uint64
+	halfBits uint
+	keys     [feistelRounds]uint64
+	counter  atomic.Uint64
+}
+
+// NewPermuted создает генератор на основе счетчика.
+// Одинаковый key дает одинаковую перестановку.
+func NewPermuted(alphabet string, length int, key string) (*PermutedGenerator, error) {
+	if err := validateAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+	if err := validateLength(length); err != nil {
+		return nil, err
+	}
+	space, err := spaceSize(alphabet, length)
+	if err != nil {
+		return nil, err
+	}
+	// Пространство сети Фейстеля — ближайшая сверху четная степень двойки
+	halfBits := uint(bits.Len64(space-1)+1) / 2
+	if halfBits*2 > 62 {
+		return nil, fmt.Errorf("short ID space is too large for permuted strategy")
+	}
+
+	g := &PermutedGenerator{
+		alphabet: alphabet,
+		length:   length,
+		space:    space,
+		halfBits: halfBits,
+	}
+	for i := range g.keys {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%d:%s", i, key)))
+		g.keys[i] = binary.BigEndian.Uint64(sum[:8])
+	}
+
+	var seed [8]byte
+	if _, err := rand.Read(seed[:]); err != nil {
+		return nil, fmt.Errorf("failed to seed counter: %w", err)
+	}
+	g.counter.Store(binary.BigEndian.Uint64(seed[:]) % space)
+
+	return g, nil
+}
+
+// NewID возвращает идентификатор для следующего номера счетчика
+func (g *PermutedGenerator) NewID() (string, error) {
+	for {
+		n := (g.counter.Add(1) - 1) % g.space
+		// Номер маршрута сервиса пропускается, остальные по-прежнему не повторяются
+		if id := encode(g.permute(n), g.alphabet, g.length); !Reserved(id) {
+			return id, nil
+		}
+	}
+}
+
+// permute отображает номер из [0, space) в [0, space) взаимно однозначно.
+// Значения за пределами пространства проходят через сеть повторно.
+func (g *PermutedGenerator) permute(n uint64) uint64 {
+	for {
+		n = g.feistel(n)
+		if n < g.space {
+			return n
+		}
+	}
+}
+
+// feistel одна перестановка над 2*halfBits битами
+func (g *PermutedGenerator) feistel(n uint64) uint64 {
+	mask := uint64(1)<<g.halfBits - 1
+	left, right := n>>g.halfBits, n&mask
+	for _, key := range g.keys {
+		left, right = right, left^(roundFunc(right, key)&mask)
+	}
+	return left<<g.halfBits | right
+}
+
+// roundFunc раундовая функция сети Фейстеля
+func roundFunc(x, key uint64) uint64 {
+	x ^= key
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
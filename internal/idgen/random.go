@@ -0,0 +1,60 @@
+package idgen
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// RandomGenerator выдает случайные идентификаторы фиксированной длины
+type RandomGenerator struct {
+	alphabet string
+	length   int
+	// limit наибольшее кратное длине алфавита значение байта, отсекает смещение распределения
+	limit int
+}
+
+// NewRandom создает генератор случайных идентификаторов
+func NewRandom(alphabet string, length int) (*RandomGenerator, error) {
+	if err := validateAlphabet(alphabet); err != nil {
+		return nil, err
+	}
+	if err := validateLength(length); err != nil {
+		return nil, err
+	}
+	return &RandomGenerator{
+		alphabet: alphabet,
+		length:   length,
+		limit:    256 - 256%len(alphabet),
+	}, nil
+}
+
+// NewID возвращает случайный идентификатор, не совпадающий с маршрутами сервиса
+func (g *RandomGenerator) NewID() (string, error) {
+	for {
+		id, err := g.random()
+		if err != nil || !Reserved(id) {
+			return id, err
+		}
+	}
+}
+
+// random возвращает случайную строку из алфавита длины length
+func (g *RandomGenerator) random() (string, error) {
+	id := make([]byte, 0, g.length)
+	buf := make([]byte, g.length*2)
+	for len(id) < g.length {
+		if _, err := rand.Read(buf); err != nil {
+			return "", fmt.Errorf("failed to read random bytes: %w", err)
+		}
+		for _, b := range buf {
+			if int(b) >= g.limit {
+				continue
+			}
+			id = append(id, g.alphabet[int(b)%len(g.alphabet)])
+			if len(id) == g.length {
+				break
+			}
+		}
+	}
+	return string(id), nil
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/Eorthus/shorturl/internal/models"
 	"github.com/stretchr/testify/assert"
@@ -26,6 +27,11 @@ func (m *MockStorage) GetURL(ctx context.Context, shortID string) (string, bool,
 	return args.String(0), args.Bool(1), args.Error(2)
 }
 
+func (m *MockStorage) GetURLData(ctx context.Context, shortID string) (models.URLData, bool, error) {
+	args := m.Called(ctx, shortID)
+	return args.Get(0).(models.URLData), args.Bool(1), args.Error(2)
+}
+
 func (m *MockStorage) Ping(ctx context.Context) error {
 	args := m.Called(ctx)
 	return args.Error(0)
@@ -46,11 +52,71 @@ func (m *MockStorage) GetUserURLs(ctx context.Context, userID string) ([]models.
 	return args.Get(0).([]models.URLData), args.Error(1)
 }
 
+func (m *MockStorage) GetUserURLsPage(ctx context.Context, userID string, query models.URLQuery) (models.URLPage, error) {
+	args := m.Called(ctx, userID, query)
+	return args.Get(0).(models.URLPage), args.Error(1)
+}
+
 func (m *MockStorage) MarkURLsAsDeleted(ctx context.Context, shortIDs []string, userID string) error {
 	args := m.Called(ctx, shortIDs, userID)
 	return args.Error(0)
 }
 
+func (m *MockStorage) RestoreURLs(ctx context.Context, shortIDs []string, userID string) ([]string, error) {
+	args := m.Called(ctx, shortIDs, userID)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockStorage) ConsumeClick(ctx context.Context, shortID string) (bool, error) {
+	args := m.Called(ctx, shortID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockStorage) UpdateURL(ctx context.Context, shortID, userID, longURL string) (string, error) {
+	args := m.Called(ctx, shortID, userID, longURL)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockStorage) GetURLHistory(ctx context.Context, shortID, userID string) ([]models.URLVersion, error) {
+	args := m.Called(ctx, shortID, userID)
+	return args.Get(0).([]models.URLVersion), args.Error(1)
+}
+
+func (m *MockStorage) SetURLLabels(ctx context.Context, shortID, userID string, labels models.URLLabels) error {
+	args := m.Called(ctx, shortID, userID, labels)
+	return args.Error(0)
+}
+
+func (m *MockStorage) GetUserTags(ctx context.Context, userID string) ([]models.TagCount, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]models.TagCount), args.Error(1)
+}
+
+func (m *MockStorage) SetURLRouting(ctx context.Context, shortID, userID string, routing *models.Routing) error {
+	args := m.Called(ctx, shortID, userID, routing)
+	return args.Error(0)
+}
+
+func (m *MockStorage) IterateURLs(ctx context.Context, fn func(url models.URLData) error) error {
+	args := m.Called(ctx, fn)
+	return args.Error(0)
+}
+
+func (m *MockStorage) SaveURLData(ctx context.Context, urls []models.URLData) error {
+	args := m.Called(ctx, urls)
+	return args.Error(0)
+}
+
+func (m *MockStorage) PurgeDeletedURLs(ctx context.Context, before time.Time, limit int) ([]models.URLData, error) {
+	args := m.Called(ctx, before, limit)
+	return args.Get(0).([]models.URLData), args.Error(1)
+}
+
+func (m *MockStorage) ReleaseURL(ctx context.Context, shortID string, now time.Time) (models.URLData, error) {
+	args := m.Called(ctx, shortID, now)
+	return args.Get(0).(models.URLData), args.Error(1)
+}
+
 func TestDBContextMiddleware(t *testing.T) {
 	mockStore := new(MockStorage)
 	middleware := DBContextMiddleware(mockStore)
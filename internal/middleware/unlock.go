@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unlockCookiePrefix префикс имени cookie разблокировки, за ним следует
+// короткий идентификатор ссылки в base64url
+const unlockCookiePrefix = "link_unlock_"
+
+// UnlockTTL время, в течение которого ссылка после ввода пароля открывается без него
+const UnlockTTL = 12 * time.Hour
+
+// SetUnlockCookie запоминает ввод пароля защищенной ссылки в подписанной cookie.
+// У каждой ссылки своя cookie с путем "/", чтобы ее получали все адреса ссылки:
+// /{id}, /{id}+ и /{id}/... Подпись учитывает хеш пароля,
+// поэтому смена пароля отменяет выданные cookie.
+func SetUnlockCookie(w http.ResponseWriter, shortID, passwordHash string, now time.Time) {
+	expires := now.Add(UnlockTTL)
+	value := strconv.FormatInt(expires.Unix(), 10)
+	http.SetCookie(w, &http.Cookie{
+		Name:     unlockCookieName(shortID),
+		Value:    value + ":" + GenerateSignature(unlockPayload(shortID, passwordHash, value)),
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		// Lax, чтобы cookie отправлялась при переходе по ссылке с другого сайта
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// IsUnlocked проверяет, что запрос содержит действующую cookie разблокировки ссылки
+func IsUnlocked(r *http.Request, shortID, passwordHash string, now time.Time) bool {
+	name := unlockCookieName(shortID)
+	for _, cookie := range r.Cookies() {
+		if cookie.Name != name {
+			continue
+		}
+		expires, signature, ok := strings.Cut(cookie.Value, ":")
+		if !ok {
+			continue
+		}
+		unix, err := strconv.ParseInt(expires, 10, 64)
+		if err != nil || now.Unix() >= unix {
+			continue
+		}
+		expected := GenerateSignature(unlockPayload(shortID, passwordHash, expires))
+		if hmac.Equal([]byte(signature), []byte(expected)) {
+			return true
+		}
+	}
+	return false
+}
+
+// unlockCookieName имя cookie разблокировки ссылки. Идентификатор кодируется,
+// потому что импортированные идентификаторы могут содержать недопустимые в имени символы
+func unlockCookieName(shortID string) string {
+	return unlockCookiePrefix + base64.RawURLEncoding.EncodeToString([]byte(shortID))
+}
+
+// unlockPayload подписываемые данные cookie разблокировки
+func unlockPayload(shortID, passwordHash, expires string) string {
+	return "unlock:" + shortID + ":" + expires + ":" + passwordHash
+}
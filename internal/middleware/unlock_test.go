@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnlockCookie(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	rr := httptest.NewRecorder()
+	SetUnlockCookie(rr, "abc123", "hash", now)
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "/", cookies[0].Path, "Cookie получают все адреса ссылки, в том числе /abc123+")
+	assert.Equal(t, unlockCookieName("abc123"), cookies[0].Name)
+	assert.True(t, cookies[0].HttpOnly)
+
+	request := func(value string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/abc123", nil)
+		req.AddCookie(&http.Cookie{Name: unlockCookieName("abc123"), Value: value})
+		return req
+	}
+	value := cookies[0].Value
+
+	assert.True(t, IsUnlocked(request(value), "abc123", "hash", now))
+	assert.False(t, IsUnlocked(request(value), "other", "hash", now), "Cookie действует только для своей ссылки")
+	assert.False(t, IsUnlocked(request(value), "abc12", "hash", now))
+	assert.False(t, IsUnlocked(request(value), "abc123", "new-hash", now), "Смена пароля отменяет cookie")
+	assert.False(t, IsUnlocked(request(value), "abc123", "hash", now.Add(UnlockTTL)), "Истекшая cookie не действует")
+	assert.False(t, IsUnlocked(request("9999999999:forged"), "abc123", "hash", now))
+	assert.False(t, IsUnlocked(httptest.NewRequest(http.MethodGet, "/abc123", nil), "abc123", "hash", now))
+}
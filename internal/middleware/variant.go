@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+)
+
+const variantCookieName = "link_variant"
+
+// VariantTTL время, в течение которого посетитель попадает в тот же вариант ссылки
+const VariantTTL = 30 * 24 * time.Hour
+
+// SetVariantCookie запоминает вариант A/B-распределения в cookie, видимой только
+// по пути ссылки. Cookie не подписывается: посетитель может выбрать лишь
+// один из вариантов самой ссылки.
+func SetVariantCookie(w http.ResponseWriter, shortID, variant string, now time.Time) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     variantCookieName,
+		Value:    variant,
+		Path:     "/" + shortID,
+		Expires:  now.Add(VariantTTL),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// GetVariant возвращает вариант из cookie запроса, пустую строку, если cookie нет
+func GetVariant(r *http.Request) string {
+	cookie, err := r.Cookie(variantCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
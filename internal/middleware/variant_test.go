@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVariantCookie(t *testing.T) {
+	now := time.Now()
+	rr := httptest.NewRecorder()
+	SetVariantCookie(rr, "abc", "b", now)
+
+	cookies := rr.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "/abc", cookies[0].Path, "Cookie видна только по пути ссылки")
+	assert.True(t, cookies[0].HttpOnly)
+
+	req := httptest.NewRequest("GET", "/abc", nil)
+	assert.Empty(t, GetVariant(req))
+	req.AddCookie(cookies[0])
+	assert.Equal(t, "b", GetVariant(req))
+}
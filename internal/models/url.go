@@ -7,12 +7,179 @@
 //   - ShortenResponse: ответ на запрос создания одного URL
 package models
 
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
 // URLData представляет собой пару из короткого и оригинального URL.
+//
+// Служебные поля заполняются при обходе хранилища и не попадают в ответы API.
+// Время активации и срок действия отдаются в списке URL пользователя.
 type URLData struct {
 	// ShortURL - сокращенный URL
 	ShortURL string `json:"short_url"`
 	// OriginalURL - исходный URL
 	OriginalURL string `json:"original_url"`
+	// UserID - владелец URL
+	UserID string `json:"-"`
+	// IsDeleted - признак удаления
+	IsDeleted bool `json:"-"`
+	// CreatedAt - время создания
+	CreatedAt time.Time `json:"-"`
+	// DeletedAt - время удаления, nil для неудаленных URL
+	DeletedAt *time.Time `json:"-"`
+	// NotBefore - время, с которого ссылка начинает работать, nil — сразу после создания
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	// ExpiresAt - время окончания действия ссылки, nil для бессрочных URL
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// ClicksLeft - оставшееся число переходов, nil для ссылок без ограничения
+	ClicksLeft *int `json:"clicks_left,omitempty"`
+	// PasswordHash - соленый хеш пароля, пустой для незащищенных ссылок
+	PasswordHash string `json:"-"`
+	// RedirectCode - код перенаправления (301, 302, 307 или 308), 0 — по умолчанию
+	RedirectCode int `json:"redirect_code,omitempty"`
+	// Passthrough - переносить параметры запроса и продолжение пути в оригинальный URL
+	Passthrough bool `json:"passthrough,omitempty"`
+	// Title - необязательный заголовок ссылки для страницы предпросмотра
+	Title string `json:"title,omitempty"`
+	// Tags - необязательные метки ссылки
+	Tags []string `json:"tags,omitempty"`
+	// Note - необязательная заметка к ссылке
+	Note string `json:"note,omitempty"`
+	// Routing - правила выбора адреса назначения, nil — всегда OriginalURL
+	Routing *Routing `json:"routing,omitempty"`
+}
+
+// ValidRedirectCode проверяет, что код перенаправления поддерживается ссылками.
+func ValidRedirectCode(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// RedirectStatus возвращает код перенаправления ссылки, по умолчанию 307.
+func (u URLData) RedirectStatus() int {
+	if u.RedirectCode == 0 {
+		return http.StatusTemporaryRedirect
+	}
+	return u.RedirectCode
+}
+
+// Protected проверяет, защищена ли ссылка паролем.
+func (u URLData) Protected() bool {
+	return u.PasswordHash != ""
+}
+
+// Plain проверяет, что у ссылки заданы только короткий и оригинальный URL
+// и владелец, поэтому ее можно сохранить без дополнительных параметров.
+func (u URLData) Plain() bool {
+	return !u.IsDeleted && u.CreatedAt.IsZero() && u.DeletedAt == nil &&
+		u.NotBefore == nil && u.ExpiresAt == nil && u.ClicksLeft == nil && !u.Protected() &&
+		u.RedirectCode == 0 && !u.Passthrough &&
+		u.Title == "" && len(u.Tags) == 0 && u.Note == "" && u.Routing == nil
+}
+
+// Restricted проверяет, что переход по ссылке зависит от ограничений:
+// числа переходов, срока действия, времени активации или пароля.
+func (u URLData) Restricted() bool {
+	return u.ClicksLeft != nil || u.ExpiresAt != nil || u.NotBefore != nil || u.Protected()
+}
+
+// Pending проверяет, что к моменту now ссылка еще не начала работать.
+func (u URLData) Pending(now time.Time) bool {
+	return u.NotBefore != nil && now.Before(*u.NotBefore)
+}
+
+// Expired проверяет, истек ли срок действия ссылки к моменту now.
+func (u URLData) Expired(now time.Time) bool {
+	return u.ExpiresAt != nil && !now.Before(*u.ExpiresAt)
+}
+
+// Defunct проверяет, что к моменту now ссылка больше не может сработать:
+// срок ее действия истек или переходы исчерпаны. Такая ссылка не удерживает
+// оригинальный URL.
+func (u URLData) Defunct(now time.Time) bool {
+	return u.Expired(now) || (u.ClicksLeft != nil && *u.ClicksLeft <= 0)
+}
+
+// Routing правила выбора адреса назначения ссылки.
+//
+// Правила проверяются по порядку, первое совпавшее задает адрес. Если ни одно
+// не совпало, адрес выбирается из вариантов по весам; без вариантов используется
+// оригинальный URL ссылки.
+type Routing struct {
+	// Rules - правила по платформе, языку и источнику перехода
+	Rules []RoutingRule `json:"rules,omitempty"`
+	// Variants - варианты для распределения трафика по весам
+	Variants []RoutingVariant `json:"variants,omitempty"`
+}
+
+// RoutingRule правило выбора адреса. Правило совпадает, если выполнены все заданные условия.
+type RoutingRule struct {
+	// Platform - платформа из User-Agent: ios, android, windows, macos, linux, mobile или desktop
+	Platform string `json:"platform,omitempty"`
+	// Language - предпочитаемый язык из Accept-Language, например "ru" или "pt-br"
+	Language string `json:"language,omitempty"`
+	// Referrer - хост из Referer вместе с поддоменами
+	Referrer string `json:"referrer,omitempty"`
+	// URL - адрес назначения
+	URL string `json:"url"`
+}
+
+// RoutingVariant вариант A/B-распределения.
+type RoutingVariant struct {
+	// Name - имя варианта, запоминается в cookie посетителя
+	Name string `json:"name"`
+	// URL - адрес назначения
+	URL string `json:"url"`
+	// Weight - относительный вес варианта
+	Weight int `json:"weight"`
+}
+
+// URLVersion версия адреса назначения короткой ссылки.
+type URLVersion struct {
+	// Version - номер версии, начиная с 1
+	Version int `json:"version"`
+	// OriginalURL - адрес назначения версии
+	OriginalURL string `json:"original_url"`
+	// ActiveFrom - время, с которого действовала версия
+	ActiveFrom time.Time `json:"active_from"`
+	// ActiveUntil - время замены версии, nil для текущей
+	ActiveUntil *time.Time `json:"active_until,omitempty"`
+}
+
+// Duration длительность, которая в JSON задается строкой вида "24h"
+// или целым числом секунд.
+type Duration time.Duration
+
+// UnmarshalJSON разбирает длительность из строки или числа секунд.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var seconds int64
+	if err := json.Unmarshal(data, &seconds); err == nil {
+		*d = Duration(time.Duration(seconds) * time.Second)
+		return nil
+	}
+
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return errors.New("duration must be a string or a number of seconds")
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON записывает длительность строкой.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
 }
 
 // BatchRequest представляет собой запрос на создание сокращенного URL в пакетном режиме.
@@ -21,6 +188,30 @@ type BatchRequest struct {
 	CorrelationID string `json:"correlation_id"`
 	// OriginalURL - исходный URL для сокращения
 	OriginalURL string `json:"original_url"`
+	// Alias - необязательный пользовательский короткий идентификатор
+	Alias string `json:"alias,omitempty"`
+	// NotBefore - необязательное время, с которого ссылка начинает работать
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	// ExpiresAt - необязательное время окончания действия ссылки
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// TTL - необязательный срок действия ссылки от момента создания
+	TTL Duration `json:"ttl,omitempty"`
+	// MaxClicks - необязательное число переходов, после которого ссылка перестает работать
+	MaxClicks int `json:"max_clicks,omitempty"`
+	// Password - необязательный пароль для перехода по ссылке
+	Password string `json:"password,omitempty"`
+	// RedirectCode - необязательный код перенаправления: 301, 302, 307 или 308
+	RedirectCode int `json:"redirect_code,omitempty"`
+	// Passthrough - переносить параметры запроса и продолжение пути в оригинальный URL
+	Passthrough bool `json:"passthrough,omitempty"`
+	// Title - необязательный заголовок ссылки для страницы предпросмотра
+	Title string `json:"title,omitempty"`
+	// Tags - необязательные метки ссылки
+	Tags []string `json:"tags,omitempty"`
+	// Note - необязательная заметка к ссылке
+	Note string `json:"note,omitempty"`
+	// Routing - необязательные правила выбора адреса назначения
+	Routing *Routing `json:"routing,omitempty"`
 }
 
 // BatchResponse представляет собой ответ на создание сокращенного URL в пакетном режиме.
@@ -41,4 +232,185 @@ type ShortenResponse struct {
 type ShortenRequest struct {
 	// URL содержит длинный URL для преобразования
 	URL string `json:"url" validate:"required,url"`
+	// Alias - необязательный пользовательский короткий идентификатор
+	Alias string `json:"alias,omitempty"`
+	// NotBefore - необязательное время, с которого ссылка начинает работать
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	// ExpiresAt - необязательное время окончания действия ссылки
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// TTL - необязательный срок действия ссылки от момента создания
+	TTL Duration `json:"ttl,omitempty"`
+	// MaxClicks - необязательное число переходов, после которого ссылка перестает работать
+	MaxClicks int `json:"max_clicks,omitempty"`
+	// Password - необязательный пароль для перехода по ссылке
+	Password string `json:"password,omitempty"`
+	// RedirectCode - необязательный код перенаправления: 301, 302, 307 или 308
+	RedirectCode int `json:"redirect_code,omitempty"`
+	// Passthrough - переносить параметры запроса и продолжение пути в оригинальный URL
+	Passthrough bool `json:"passthrough,omitempty"`
+	// Title - необязательный заголовок ссылки для страницы предпросмотра
+	Title string `json:"title,omitempty"`
+	// Tags - необязательные метки ссылки
+	Tags []string `json:"tags,omitempty"`
+	// Note - необязательная заметка к ссылке
+	Note string `json:"note,omitempty"`
+	// Routing - необязательные правила выбора адреса назначения
+	Routing *Routing `json:"routing,omitempty"`
+}
+
+// UpdateURLRequest представляет собой запрос изменения адреса назначения короткого URL.
+//
+// Отсутствующие поля не меняются, пустой список tags удаляет все метки.
+type UpdateURLRequest struct {
+	// URL - новый адрес назначения
+	URL string `json:"url,omitempty"`
+	// Title - новый заголовок, пустая строка удаляет заголовок
+	Title *string `json:"title,omitempty"`
+	// Tags - новый список меток
+	Tags *[]string `json:"tags,omitempty"`
+	// Note - новая заметка, пустая строка удаляет заметку
+	Note *string `json:"note,omitempty"`
+}
+
+// SortOrder порядок сортировки URL по времени создания.
+type SortOrder string
+
+const (
+	// SortCreatedAsc - от старых к новым
+	SortCreatedAsc SortOrder = "created_at"
+	// SortCreatedDesc - от новых к старым
+	SortCreatedDesc SortOrder = "-created_at"
+)
+
+// URLQuery описывает выборку страницы URL пользователя.
+type URLQuery struct {
+	// Limit - максимальное количество URL на странице
+	Limit int
+	// Cursor - непрозрачный курсор предыдущей страницы, пустой для первой
+	Cursor string
+	// Sort - порядок сортировки, по умолчанию SortCreatedAsc
+	Sort SortOrder
+	// Deleted - фильтр по признаку удаления, nil возвращает все URL
+	Deleted *bool
+	// Search - подстрока оригинального URL без учета регистра
+	Search string
+	// Tag - метка, которая должна быть у URL
+	Tag string
+}
+
+// URLLabels описательные поля ссылки, которые меняет владелец.
+type URLLabels struct {
+	// Title - заголовок ссылки
+	Title string
+	// Tags - метки ссылки
+	Tags []string
+	// Note - заметка к ссылке
+	Note string
+}
+
+// LinkPreview представляет собой данные страницы предпросмотра ссылки.
+type LinkPreview struct {
+	// ShortURL - сокращенный URL
+	ShortURL string `json:"short_url"`
+	// OriginalURL - адрес назначения, пустой для защищенной паролем
+	// или еще не начавшей работать ссылки
+	OriginalURL string `json:"original_url,omitempty"`
+	// Title - заголовок, заданный владельцем
+	Title string `json:"title,omitempty"`
+	// CreatedAt - время создания
+	CreatedAt time.Time `json:"created_at"`
+	// NotBefore - время, с которого ссылка начинает работать, если оно еще не наступило
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	// Protected - ссылка защищена паролем
+	Protected bool `json:"protected,omitempty"`
+}
+
+// ImportStatus итог импорта одной ссылки.
+type ImportStatus string
+
+// Итоги импорта ссылки
+const (
+	// ImportCreated - ссылка создана
+	ImportCreated ImportStatus = "created"
+	// ImportExists - адрес уже был сокращен, возвращается существующая ссылка
+	ImportExists ImportStatus = "exists"
+	// ImportFailed - строка отклонена, причина в Error
+	ImportFailed ImportStatus = "failed"
+)
+
+// ImportResult представляет собой результат импорта одной строки.
+type ImportResult struct {
+	// Row - номер строки в файле, заголовок — строка 1
+	Row int `json:"row"`
+	// OriginalURL - исходный URL строки
+	OriginalURL string `json:"original_url"`
+	// ShortURL - созданный или существующий короткий URL
+	ShortURL string `json:"short_url,omitempty"`
+	// Status - итог импорта строки
+	Status ImportStatus `json:"status"`
+	// Error - причина отказа для ImportFailed
+	Error string `json:"error,omitempty"`
+}
+
+// ImportReport представляет собой отчет об импорте ссылок.
+type ImportReport struct {
+	// Created - число созданных ссылок
+	Created int `json:"created"`
+	// Existing - число уже сокращенных адресов
+	Existing int `json:"existing"`
+	// Failed - число отклоненных строк
+	Failed int `json:"failed"`
+	// Results - результаты по строкам в порядке файла
+	Results []ImportResult `json:"results"`
+}
+
+// ExportedURL представляет собой запись выгрузки URL пользователя.
+// Хеш пароля не выгружается, вместо него передается признак Protected.
+type ExportedURL struct {
+	// ShortURL - сокращенный URL
+	ShortURL string `json:"short_url"`
+	// OriginalURL - исходный URL
+	OriginalURL string `json:"original_url"`
+	// CreatedAt - время создания
+	CreatedAt time.Time `json:"created_at"`
+	// IsDeleted - признак удаления
+	IsDeleted bool `json:"is_deleted"`
+	// DeletedAt - время удаления
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// NotBefore - время, с которого ссылка начинает работать
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	// ExpiresAt - время окончания действия ссылки
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	// ClicksLeft - оставшееся число переходов
+	ClicksLeft *int `json:"clicks_left,omitempty"`
+	// Protected - ссылка защищена паролем
+	Protected bool `json:"protected,omitempty"`
+	// RedirectCode - код перенаправления
+	RedirectCode int `json:"redirect_code,omitempty"`
+	// Passthrough - переносить параметры запроса и продолжение пути
+	Passthrough bool `json:"passthrough,omitempty"`
+	// Title - заголовок ссылки
+	Title string `json:"title,omitempty"`
+	// Tags - метки ссылки
+	Tags []string `json:"tags,omitempty"`
+	// Note - заметка к ссылке
+	Note string `json:"note,omitempty"`
+	// Routing - правила выбора адреса назначения
+	Routing *Routing `json:"routing,omitempty"`
+}
+
+// TagCount число неудаленных URL пользователя с меткой.
+type TagCount struct {
+	// Tag - метка
+	Tag string `json:"tag"`
+	// Count - число URL с меткой
+	Count int `json:"count"`
+}
+
+// URLPage представляет собой страницу URL пользователя.
+type URLPage struct {
+	// URLs - URL на странице
+	URLs []URLData
+	// NextCursor - курсор следующей страницы, пустой на последней странице
+	NextCursor string
 }
@@ -0,0 +1,50 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestURLData_Plain(t *testing.T) {
+	base := URLData{ShortURL: "abc", OriginalURL: "https://example.com", UserID: "user1"}
+	assert.True(t, base.Plain())
+
+	// Любое новое поле, кроме перечисленных, должно делать ссылку не простой
+	plainFields := map[string]bool{"ShortURL": true, "OriginalURL": true, "UserID": true}
+	typ := reflect.TypeOf(base)
+	for i := range typ.NumField() {
+		field := typ.Field(i)
+		if plainFields[field.Name] {
+			continue
+		}
+		t.Run(field.Name, func(t *testing.T) {
+			url := base
+			reflect.ValueOf(&url).Elem().Field(i).Set(nonZero(t, field.Type))
+			assert.False(t, url.Plain(), "Поле %s должно учитываться в Plain", field.Name)
+		})
+	}
+}
+
+// nonZero возвращает ненулевое значение типа поля URLData
+func nonZero(t *testing.T, typ reflect.Type) reflect.Value {
+	if typ == reflect.TypeOf(time.Time{}) {
+		return reflect.ValueOf(time.Unix(1, 0))
+	}
+	switch typ.Kind() {
+	case reflect.String:
+		return reflect.ValueOf("x").Convert(typ)
+	case reflect.Bool:
+		return reflect.ValueOf(true).Convert(typ)
+	case reflect.Int:
+		return reflect.ValueOf(1).Convert(typ)
+	case reflect.Pointer:
+		return reflect.New(typ.Elem())
+	case reflect.Slice:
+		return reflect.MakeSlice(typ, 1, 1)
+	}
+	t.Fatalf("Нет ненулевого значения для типа %s", typ)
+	return reflect.Value{}
+}
@@ -0,0 +1,220 @@
+package qr
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// Пример HELLO WORLD версии 1-M
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	assert.Equal(t, []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}, reedSolomonRemainder(data, reedSolomonDivisor(10)))
+}
+
+func TestServiceInformation(t *testing.T) {
+	formats := map[Level]string{L: "111011111000100", M: "101010000010010", Q: "011010101011111", H: "001011010001001"}
+	for level, expected := range formats {
+		c := &Code{Version: 1, Size: 21, Level: level, modules: make([]bool, 21*21), isFunction: make([]bool, 21*21)}
+		c.drawFormatBits(0)
+		assert.Equal(t, expected, readFormat(c), "Уровень %d", level)
+	}
+
+	c := &Code{Version: 7, Size: 45, modules: make([]bool, 45*45), isFunction: make([]bool, 45*45)}
+	c.drawVersion()
+	var version strings.Builder
+	for i := 17; i >= 0; i-- {
+		version.WriteString(map[bool]string{false: "0", true: "1"}[c.Black(c.Size-11+i%3, i/3)])
+	}
+	assert.Equal(t, "000111110010010100", version.String())
+
+	assert.Empty(t, alignmentPatternPositions(1))
+	assert.Equal(t, []int{6, 18}, alignmentPatternPositions(2))
+	assert.Equal(t, []int{6, 22, 38}, alignmentPatternPositions(7))
+	assert.Equal(t, []int{6, 34, 60, 86, 112, 138}, alignmentPatternPositions(32))
+	assert.Equal(t, []int{6, 30, 58, 86, 114, 142, 170}, alignmentPatternPositions(40))
+}
+
+func TestEncode(t *testing.T) {
+	t.Run("Емкость версий", func(t *testing.T) {
+		tests := []struct {
+			version  int
+			level    Level
+			capacity int
+		}{
+			{1, L, 17}, {1, M, 14}, {1, Q, 11}, {1, H, 7},
+			{2, M, 26}, {5, Q, 60}, {7, H, 64}, {10, M, 213}, {40, L, 2953}, {40, H, 1273},
+		}
+		for _, tt := range tests {
+			c, err := Encode(strings.Repeat("a", tt.capacity), tt.level)
+			require.NoError(t, err)
+			assert.Equal(t, tt.version, c.Version, "Емкость %d", tt.capacity)
+			if tt.version < 40 {
+				c, err = Encode(strings.Repeat("a", tt.capacity+1), tt.level)
+				require.NoError(t, err)
+				assert.Equal(t, tt.version+1, c.Version)
+			}
+		}
+
+		_, err := Encode(strings.Repeat("a", 2954), L)
+		assert.ErrorIs(t, err, ErrTooLong)
+	})
+
+	t.Run("Декодирование", func(t *testing.T) {
+		texts := []string{
+			"http://localhost:8080/abc",
+			"https://sho.rt/" + strings.Repeat("Короткая ссылка ", 20),
+			strings.Repeat("x", 1000),
+		}
+		for _, text := range texts {
+			for _, level := range []Level{L, M, Q, H} {
+				c, err := Encode(text, level)
+				require.NoError(t, err)
+				assert.Equal(t, c.Version*4+17, c.Size)
+				assert.Equal(t, text, decode(t, c), "Версия %d, уровень %d", c.Version, level)
+			}
+		}
+	})
+}
+
+func TestRender(t *testing.T) {
+	c, err := Encode("http://localhost:8080/abc", M)
+	require.NoError(t, err)
+	modules := c.Size + 2*DefaultMargin
+
+	data, err := c.PNG(modules*4, DefaultMargin)
+	require.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, modules*4, img.Bounds().Dx())
+	r, _, _, _ := img.At(0, 0).RGBA()
+	assert.NotZero(t, r, "Свободное поле светлое")
+	r, _, _, _ = img.At(DefaultMargin*4, DefaultMargin*4).RGBA()
+	assert.Zero(t, r, "Угол поискового узора темный")
+
+	assert.Equal(t, c.Size, c.Image(1, 0).Bounds().Dx(), "Не меньше пикселя на модуль")
+
+	var svg bytes.Buffer
+	require.NoError(t, c.WriteSVG(&svg, 300, 2))
+	assert.Contains(t, svg.String(), `width="300" height="300"`)
+	assert.Contains(t, svg.String(), `viewBox="0 0 29 29"`)
+	assert.Contains(t, svg.String(), "M2,2h1v1h-1z")
+}
+
+// readFormat читает первую копию информации о формате, начиная со старшего бита
+func readFormat(c *Code) string {
+	var bits [15]bool
+	for i := 0; i <= 5; i++ {
+		bits[i] = c.Black(8, i)
+	}
+	bits[6], bits[7], bits[8] = c.Black(8, 7), c.Black(8, 8), c.Black(7, 8)
+	for i := 9; i < 15; i++ {
+		bits[i] = c.Black(14-i, 8)
+	}
+	var s strings.Builder
+	for i := 14; i >= 0; i-- {
+		s.WriteString(map[bool]string{false: "0", true: "1"}[bits[i]])
+	}
+	return s.String()
+}
+
+// decode читает данные кода так, как это делает сканер: снимает маску,
+// собирает блоки, проверяет коды коррекции и разбирает сегмент байтового режима
+func decode(t *testing.T, c *Code) string {
+	t.Helper()
+
+	var format int
+	for _, b := range readFormat(c) {
+		format = format<<1 | int(b-'0')
+	}
+	format ^= 0x5412
+	require.Equal(t, c.Level.formatBits(), format>>13, "Уровень в информации о формате")
+	mask := format >> 10 & 7
+	require.Equal(t, c.Mask, mask)
+
+	plain := &Code{Version: c.Version, Size: c.Size, Level: c.Level,
+		modules: make([]bool, len(c.modules)), isFunction: make([]bool, len(c.modules))}
+	plain.drawFunctionPatterns()
+	copy(plain.modules, c.modules)
+	plain.applyMask(mask)
+
+	var bits []bool
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := range c.Size {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for _, x := range []int{right, right - 1} {
+				if !plain.isFunction[y*c.Size+x] {
+					bits = append(bits, plain.modules[y*c.Size+x])
+				}
+			}
+		}
+	}
+	codewords := make([]byte, rawDataModules(c.Version)/8)
+	for i := range codewords {
+		for _, b := range bits[i*8 : i*8+8] {
+			codewords[i] <<= 1
+			if b {
+				codewords[i] |= 1
+			}
+		}
+	}
+
+	numBlocks := eccBlocks[c.Level][c.Version]
+	eccLen := eccCodewordsPerBlock[c.Level][c.Version]
+	numShort := numBlocks - len(codewords)%numBlocks
+	shortData := len(codewords)/numBlocks - eccLen
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i <= shortData; i++ {
+		for j := range blocks {
+			if i < shortData || j >= numShort {
+				blocks[j] = append(blocks[j], codewords[k])
+				k++
+			}
+		}
+	}
+	for range eccLen {
+		for j := range blocks {
+			blocks[j] = append(blocks[j], codewords[k])
+			k++
+		}
+	}
+
+	var data []byte
+	for _, block := range blocks {
+		dataLen := len(block) - eccLen
+		require.Equal(t, block[dataLen:], reedSolomonRemainder(block[:dataLen], reedSolomonDivisor(eccLen)))
+		data = append(data, block[:dataLen]...)
+	}
+
+	require.Equal(t, byte(0b0100), data[0]>>4, "Байтовый режим")
+	var bb bitBuffer
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+	read := func(from, n int) int {
+		v := 0
+		for _, b := range bb[from : from+n] {
+			v = v<<1 | int(b)
+		}
+		return v
+	}
+	countBits := charCountBits(c.Version)
+	n := read(4, countBits)
+	text := make([]byte, n)
+	for i := range text {
+		text[i] = byte(read(4+countBits+8*i, 8))
+	}
+	return string(text)
+}
@@ -0,0 +1,64 @@
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+)
+
+// DefaultMargin ширина свободного поля в модулях, рекомендованная стандартом
+const DefaultMargin = 4
+
+// Image отрисовывает код в черно-белое изображение size×size пикселей
+// со свободным полем margin модулей. Если size меньше числа модулей,
+// изображение увеличивается до одного пикселя на модуль.
+func (c *Code) Image(size, margin int) image.Image {
+	modules := c.Size + 2*margin
+	size = max(size, modules)
+
+	img := image.NewPaletted(image.Rect(0, 0, size, size), color.Palette{color.White, color.Black})
+	for py := range size {
+		y := py*modules/size - margin
+		for px := range size {
+			if c.Black(px*modules/size-margin, y) {
+				img.SetColorIndex(px, py, 1)
+			}
+		}
+	}
+	return img
+}
+
+// PNG кодирует код в PNG, параметры как у Image
+func (c *Code) PNG(size, margin int) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := png.Encoder{CompressionLevel: png.BestCompression}
+	if err := encoder.Encode(&buf, c.Image(size, margin)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteSVG записывает код в SVG шириной и высотой size пикселей
+// со свободным полем margin модулей. Темные модули объединены в один путь.
+func (c *Code) WriteSVG(w io.Writer, size, margin int) error {
+	modules := c.Size + 2*margin
+	var path bytes.Buffer
+	for y := range c.Size {
+		for x := range c.Size {
+			if c.Black(x, y) {
+				fmt.Fprintf(&path, "M%d,%dh1v1h-1z", x+margin, y+margin)
+			}
+		}
+	}
+
+	_, err := fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">
+<rect width="100%%" height="100%%" fill="#fff"/>
+<path fill="#000" d="%s"/>
+</svg>
+`, size, size, modules, modules, path.String())
+	return err
+}
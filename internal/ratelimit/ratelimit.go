@@ -0,0 +1,67 @@
+// Package ratelimit ограничивает частоту попыток по ключу.
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter разрешает не больше limit попыток на ключ за окно window.
+//
+// Окно фиксированное: оно начинается с первой попытки и сбрасывается
+// по истечении. Записи с истекшими окнами удаляются не чаще раза за окно.
+type Limiter struct {
+	mutex   sync.Mutex
+	limit   int
+	window  time.Duration
+	entries map[string]*entry
+	sweepAt time.Time
+	now     func() time.Time
+}
+
+type entry struct {
+	start time.Time
+	count int
+}
+
+// New создает ограничитель; limit меньше единицы заменяется единицей
+func New(limit int, window time.Duration) *Limiter {
+	return &Limiter{
+		limit:   max(limit, 1),
+		window:  window,
+		entries: make(map[string]*entry),
+		now:     time.Now,
+	}
+}
+
+// Allow засчитывает попытку и сообщает, укладывается ли она в лимит
+func (l *Limiter) Allow(key string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	if now.After(l.sweepAt) {
+		for k, e := range l.entries {
+			if now.Sub(e.start) >= l.window {
+				delete(l.entries, k)
+			}
+		}
+		l.sweepAt = now.Add(l.window)
+	}
+
+	e, ok := l.entries[key]
+	if !ok || now.Sub(e.start) >= l.window {
+		l.entries[key] = &entry{start: now, count: 1}
+		return true
+	}
+	if e.count >= l.limit {
+		return false
+	}
+	e.count++
+	return true
+}
+
+// Window возвращает длительность окна ограничения
+func (l *Limiter) Window() time.Duration {
+	return l.window
+}
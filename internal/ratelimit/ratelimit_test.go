@@ -0,0 +1,26 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := New(2, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	assert.True(t, limiter.Allow("a"))
+	assert.True(t, limiter.Allow("a"))
+	assert.False(t, limiter.Allow("a"), "Третья попытка в окне превышает лимит")
+	assert.True(t, limiter.Allow("b"), "Ключи ограничиваются независимо")
+
+	now = now.Add(time.Minute)
+	assert.True(t, limiter.Allow("a"), "После окна лимит восстанавливается")
+
+	now = now.Add(2 * time.Minute)
+	limiter.Allow("c")
+	assert.Len(t, limiter.entries, 1, "Истекшие окна должны удаляться")
+}
@@ -0,0 +1,195 @@
+// Package routing выбирает адрес назначения ссылки по правилам и весам вариантов.
+//
+// Выбор зависит только от ссылки и параметров перехода, поэтому один и тот же
+// переход всегда ведет на один и тот же адрес.
+package routing
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Eorthus/shorturl/internal/models"
+)
+
+// Платформы, которые определяются по User-Agent
+const (
+	PlatformIOS     = "ios"
+	PlatformAndroid = "android"
+	PlatformWindows = "windows"
+	PlatformMacOS   = "macos"
+	PlatformLinux   = "linux"
+	// PlatformMobile объединяет ios и android
+	PlatformMobile = "mobile"
+	// PlatformDesktop объединяет windows, macos и linux
+	PlatformDesktop = "desktop"
+)
+
+// Visit параметры перехода по ссылке
+type Visit struct {
+	// UserAgent - заголовок User-Agent
+	UserAgent string
+	// AcceptLanguage - заголовок Accept-Language
+	AcceptLanguage string
+	// Referer - заголовок Referer
+	Referer string
+	// Variant - вариант, выбранный при прошлом переходе, из cookie посетителя
+	Variant string
+	// VisitorID - ключ посетителя для распределения по весам
+	VisitorID string
+}
+
+// Result выбранный адрес назначения
+type Result struct {
+	// URL - адрес назначения
+	URL string
+	// Variant - имя выбранного варианта, пустое, если адрес задан правилом
+	// или оригинальным URL
+	Variant string
+}
+
+// Evaluate выбирает адрес назначения ссылки для перехода.
+//
+// Первое совпавшее правило задает адрес. Иначе вариант из cookie сохраняется,
+// если он все еще есть у ссылки, а новый посетитель попадает в вариант по хешу
+// короткого идентификатора и VisitorID с учетом весов.
+func Evaluate(link models.URLData, visit Visit) Result {
+	if link.Routing == nil {
+		return Result{URL: link.OriginalURL}
+	}
+
+	for _, rule := range link.Routing.Rules {
+		if matches(rule, visit) {
+			return Result{URL: rule.URL}
+		}
+	}
+
+	variants := link.Routing.Variants
+	total := 0
+	for _, variant := range variants {
+		if variant.Name == visit.Variant {
+			return Result{URL: variant.URL, Variant: variant.Name}
+		}
+		total += variant.Weight
+	}
+	if total <= 0 {
+		return Result{URL: link.OriginalURL}
+	}
+
+	point := int(bucket(link.ShortURL+":"+visit.VisitorID) % uint32(total))
+	for _, variant := range variants {
+		if point < variant.Weight {
+			return Result{URL: variant.URL, Variant: variant.Name}
+		}
+		point -= variant.Weight
+	}
+	return Result{URL: link.OriginalURL}
+}
+
+// matches проверяет все заданные условия правила
+func matches(rule models.RoutingRule, visit Visit) bool {
+	if rule.Platform != "" && !matchesPlatform(rule.Platform, Platform(visit.UserAgent)) {
+		return false
+	}
+	if rule.Language != "" && !matchesLanguage(rule.Language, PreferredLanguage(visit.AcceptLanguage)) {
+		return false
+	}
+	if rule.Referrer != "" && !matchesHost(rule.Referrer, refererHost(visit.Referer)) {
+		return false
+	}
+	return true
+}
+
+// bucket хеширует ключ посетителя (FNV-1a)
+func bucket(key string) uint32 {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return hash
+}
+
+// Platform определяет платформу по User-Agent, пустая строка — платформа неизвестна
+func Platform(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	// iOS и Android указывают Mac OS X и Linux, поэтому проверяются первыми
+	switch {
+	case strings.Contains(ua, "iphone"), strings.Contains(ua, "ipad"), strings.Contains(ua, "ipod"):
+		return PlatformIOS
+	case strings.Contains(ua, "android"):
+		return PlatformAndroid
+	case strings.Contains(ua, "windows"):
+		return PlatformWindows
+	case strings.Contains(ua, "macintosh"), strings.Contains(ua, "mac os x"):
+		return PlatformMacOS
+	case strings.Contains(ua, "linux"), strings.Contains(ua, "x11"):
+		return PlatformLinux
+	}
+	return ""
+}
+
+// ValidPlatform проверяет, что платформу можно указать в правиле
+func ValidPlatform(platform string) bool {
+	switch platform {
+	case PlatformIOS, PlatformAndroid, PlatformWindows, PlatformMacOS, PlatformLinux, PlatformMobile, PlatformDesktop:
+		return true
+	}
+	return false
+}
+
+// matchesPlatform сравнивает платформу правила с платформой посетителя
+func matchesPlatform(rule, platform string) bool {
+	switch rule {
+	case PlatformMobile:
+		return platform == PlatformIOS || platform == PlatformAndroid
+	case PlatformDesktop:
+		return platform == PlatformWindows || platform == PlatformMacOS || platform == PlatformLinux
+	}
+	return rule == platform
+}
+
+// PreferredLanguage возвращает язык Accept-Language с наибольшим весом в нижнем регистре.
+// При равных весах выбирается указанный раньше.
+func PreferredLanguage(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if q > bestQ {
+			best, bestQ = tag, q
+		}
+	}
+	return best
+}
+
+// matchesLanguage проверяет, что язык совпадает с языком правила или уточняет его:
+// правило "pt" совпадает с "pt-br"
+func matchesLanguage(rule, language string) bool {
+	return language == rule || strings.HasPrefix(language, rule+"-")
+}
+
+// refererHost возвращает хост из Referer без порта
+func refererHost(referer string) string {
+	u, err := url.Parse(referer)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// matchesHost проверяет совпадение хоста с хостом правила или его поддоменом
+func matchesHost(rule, host string) bool {
+	return host != "" && (host == rule || strings.HasSuffix(host, "."+rule))
+}
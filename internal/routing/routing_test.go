@@ -0,0 +1,94 @@
+package routing
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/Eorthus/shorturl/internal/models"
+)
+
+const (
+	iPhoneUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148"
+	androidUA = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/120.0 Mobile Safari/537.36"
+	macUA     = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 Version/17.0 Safari/605.1.15"
+	windowsUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36"
+)
+
+func TestPlatform(t *testing.T) {
+	tests := map[string]string{
+		iPhoneUA:                          PlatformIOS,
+		androidUA:                         PlatformAndroid,
+		macUA:                             PlatformMacOS,
+		windowsUA:                         PlatformWindows,
+		"Mozilla/5.0 (X11; Linux x86_64)": PlatformLinux,
+		"curl/8.4.0":                      "",
+	}
+	for userAgent, expected := range tests {
+		assert.Equal(t, expected, Platform(userAgent), userAgent)
+	}
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	assert.Equal(t, "ru-ru", PreferredLanguage("ru-RU,ru;q=0.9,en;q=0.8"))
+	assert.Equal(t, "en", PreferredLanguage("de;q=0.5, en;q=0.9"))
+	assert.Equal(t, "fr", PreferredLanguage("fr, de"), "При равных весах побеждает первый")
+	assert.Equal(t, "de", PreferredLanguage("*, en;q=0, de;q=0.1"))
+	assert.Empty(t, PreferredLanguage(""))
+}
+
+func TestEvaluate(t *testing.T) {
+	link := models.URLData{
+		ShortURL:    "promo",
+		OriginalURL: "https://example.com",
+		Routing: &models.Routing{
+			Rules: []models.RoutingRule{
+				{Platform: PlatformIOS, URL: "https://apps.apple.com/app"},
+				{Platform: PlatformMobile, Language: "ru", URL: "https://m.example.ru"},
+				{Referrer: "news.example.org", URL: "https://example.com/news"},
+			},
+			Variants: []models.RoutingVariant{
+				{Name: "a", URL: "https://example.com/a", Weight: 3},
+				{Name: "b", URL: "https://example.com/b", Weight: 1},
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		visit    Visit
+		expected Result
+	}{
+		{"Платформа", Visit{UserAgent: iPhoneUA, AcceptLanguage: "ru"}, Result{URL: "https://apps.apple.com/app"}},
+		{"Все условия правила", Visit{UserAgent: androidUA, AcceptLanguage: "ru-RU,en;q=0.5"}, Result{URL: "https://m.example.ru"}},
+		{"Поддомен источника", Visit{UserAgent: androidUA, AcceptLanguage: "en", Referer: "https://m.news.example.org/a?b=c"},
+			Result{URL: "https://example.com/news"}},
+		{"Похожий, но другой хост", Visit{Referer: "https://fakenews.example.org/", Variant: "b"}, Result{URL: "https://example.com/b", Variant: "b"}},
+		{"Вариант из cookie", Visit{UserAgent: windowsUA, Variant: "b"}, Result{URL: "https://example.com/b", Variant: "b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, Evaluate(link, tt.visit))
+		})
+	}
+
+	t.Run("Детерминированный выбор по весам", func(t *testing.T) {
+		counts := map[string]int{}
+		for i := range 4000 {
+			visit := Visit{UserAgent: windowsUA, Variant: "removed", VisitorID: fmt.Sprintf("visitor-%d", i)}
+			result := Evaluate(link, visit)
+			assert.Equal(t, result, Evaluate(link, visit), "Тот же посетитель получает тот же вариант")
+			counts[result.Variant]++
+		}
+		assert.InDelta(t, 3000, counts["a"], 200)
+		assert.InDelta(t, 1000, counts["b"], 200)
+	})
+
+	t.Run("Без правил и вариантов", func(t *testing.T) {
+		assert.Equal(t, Result{URL: "https://example.com"}, Evaluate(models.URLData{OriginalURL: "https://example.com"}, Visit{}))
+		onlyRules := link
+		onlyRules.Routing = &models.Routing{Rules: link.Routing.Rules[:1]}
+		assert.Equal(t, Result{URL: "https://example.com"}, Evaluate(onlyRules, Visit{UserAgent: windowsUA}))
+	})
+}
@@ -0,0 +1,33 @@
+package service
+
+import (
+	"github.com/Eorthus/shorturl/internal/apperrors"
+	"github.com/Eorthus/shorturl/internal/idgen"
+)
+
+// Ограничения длины пользовательского псевдонима
+const (
+	MinAliasLength = 3
+	// MaxAliasLength соответствует колонке short_id VARCHAR(64)
+	MaxAliasLength = 64
+)
+
+// ValidateAlias проверяет пользовательский псевдоним: латинские буквы, цифры,
+// дефис и подчеркивание, длина от MinAliasLength до MaxAliasLength
+// и несовпадение с маршрутами сервиса без учета регистра.
+func ValidateAlias(alias string) error {
+	if len(alias) < MinAliasLength || len(alias) > MaxAliasLength {
+		return apperrors.ErrInvalidAlias
+	}
+	for _, c := range alias {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return apperrors.ErrInvalidAlias
+		}
+	}
+	if idgen.Reserved(alias) {
+		return apperrors.ErrInvalidAlias
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/Eorthus/shorturl/internal/models"
+)
+
+// Destination возвращает адрес перенаправления для ссылки.
+//
+// Для ссылок в режиме passthrough продолжение пути после короткого идентификатора
+// (в экранированном виде) дописывается к пути оригинального URL, а параметры запроса
+// добавляются к его параметрам. Параметры, уже заданные в оригинальном URL, не переопределяются.
+// Для остальных ссылок возвращается оригинальный URL без изменений.
+func Destination(link models.URLData, suffix, rawQuery string) string {
+	if !link.Passthrough || (suffix == "" && rawQuery == "") {
+		return link.OriginalURL
+	}
+	dest, err := url.Parse(link.OriginalURL)
+	if err != nil {
+		return link.OriginalURL
+	}
+
+	if suffix != "" {
+		joined := cleanSuffix(suffix)
+		rawPath := strings.TrimSuffix(dest.EscapedPath(), "/") + joined
+		if unescaped, err := url.PathUnescape(rawPath); err == nil {
+			dest.Path, dest.RawPath = unescaped, rawPath
+		}
+	}
+
+	if rawQuery != "" {
+		incoming, _ := url.ParseQuery(rawQuery)
+		existing := dest.Query()
+		extra := url.Values{}
+		for key, values := range incoming {
+			if _, ok := existing[key]; !ok {
+				extra[key] = values
+			}
+		}
+		if encoded := extra.Encode(); encoded != "" {
+			if dest.RawQuery != "" {
+				dest.RawQuery += "&"
+			}
+			dest.RawQuery += encoded
+		}
+	}
+
+	return dest.String()
+}
+
+// cleanSuffix убирает из экранированного продолжения пути сегменты . и ..,
+// в том числе записанные как %2e, чтобы продолжение не вышло за пределы пути
+// оригинального URL. Возвращает путь, начинающийся с "/", остальные сегменты
+// остаются в исходном экранированном виде.
+func cleanSuffix(suffix string) string {
+	var segments []string
+	for _, segment := range strings.Split(suffix, "/") {
+		decoded, err := url.PathUnescape(segment)
+		if err != nil {
+			decoded = segment
+		}
+		switch decoded {
+		case "", ".":
+		case "..":
+			if len(segments) > 0 {
+				segments = segments[:len(segments)-1]
+			}
+		default:
+			segments = append(segments, segment)
+		}
+	}
+
+	joined := "/" + strings.Join(segments, "/")
+	if strings.HasSuffix(suffix, "/") && joined != "/" {
+		joined += "/"
+	}
+	return joined
+}
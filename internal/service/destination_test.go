@@ -0,0 +1,91 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/Eorthus/shorturl/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDestination(t *testing.T) {
+	tests := []struct {
+		name        string
+		originalURL string
+		passthrough bool
+		suffix      string
+		rawQuery    string
+		expected    string
+	}{
+		{
+			name:        "Без passthrough параметры отбрасываются",
+			originalURL: "https://example.com/landing",
+			suffix:      "extra",
+			rawQuery:    "utm_source=ads",
+			expected:    "https://example.com/landing",
+		},
+		{
+			name:        "Параметры запроса",
+			originalURL: "https://example.com/landing?ref=1",
+			passthrough: true,
+			rawQuery:    "utm_source=ads&utm_medium=cpc",
+			expected:    "https://example.com/landing?ref=1&utm_medium=cpc&utm_source=ads",
+		},
+		{
+			name:        "Параметры оригинального URL не переопределяются",
+			originalURL: "https://example.com/?utm_source=site",
+			passthrough: true,
+			rawQuery:    "utm_source=ads",
+			expected:    "https://example.com/?utm_source=site",
+		},
+		{
+			name:        "Продолжение пути",
+			originalURL: "https://example.com/docs/",
+			passthrough: true,
+			suffix:      "guide/intro",
+			expected:    "https://example.com/docs/guide/intro",
+		},
+		{
+			name:        "Продолжение пути с параметрами и фрагментом",
+			originalURL: "https://example.com/docs#top",
+			passthrough: true,
+			suffix:      "a%20b/",
+			rawQuery:    "q=1",
+			expected:    "https://example.com/docs/a%20b/?q=1#top",
+		},
+		{
+			name:        "Путь не выходит за пределы оригинального",
+			originalURL: "https://example.com/docs",
+			passthrough: true,
+			suffix:      "../../admin",
+			expected:    "https://example.com/docs/admin",
+		},
+		{
+			name:        "Экранированные точки не выходят за пределы пути",
+			originalURL: "https://ex.com/docs/v1",
+			passthrough: true,
+			suffix:      "%2e%2e/%2E./.%2e/secret",
+			expected:    "https://ex.com/docs/v1/secret",
+		},
+		{
+			name:        "Экранированная точка в конце пути",
+			originalURL: "https://ex.com/docs",
+			passthrough: true,
+			suffix:      "guide/%2e%2e",
+			expected:    "https://ex.com/docs/",
+		},
+		{
+			name:        "Точки внутри имени сохраняются",
+			originalURL: "https://ex.com/docs",
+			passthrough: true,
+			suffix:      "v1.2/%2e%2efile",
+			expected:    "https://ex.com/docs/v1.2/%2e%2efile",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link := models.URLData{OriginalURL: tt.originalURL, Passthrough: tt.passthrough}
+			assert.Equal(t, tt.expected, Destination(link, tt.suffix, tt.rawQuery))
+		})
+	}
+}
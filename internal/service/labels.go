@@ -0,0 +1,80 @@
+package service
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Eorthus/shorturl/internal/apperrors"
+)
+
+// Ограничения заголовка, меток и заметки ссылки
+const (
+	// MaxTags максимальное число меток у ссылки
+	MaxTags = 20
+	// MaxTagLength максимальная длина метки в символах
+	MaxTagLength = 32
+	// MaxTitleLength максимальная длина заголовка в символах
+	MaxTitleLength = 200
+	// MaxNoteLength максимальная длина заметки в символах
+	MaxNoteLength = 1000
+)
+
+// NormalizeTags приводит метки к нижнему регистру и убирает повторы, сохраняя порядок.
+// Метка состоит из букв, цифр, дефиса и подчеркивания, длиной до MaxTagLength символов,
+// у ссылки не больше MaxTags меток.
+func NormalizeTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	normalized := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if err := validateTag(tag); err != nil {
+			return nil, err
+		}
+		if !slices.Contains(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > MaxTags {
+		return nil, apperrors.ErrInvalidTags
+	}
+	return normalized, nil
+}
+
+// validateTag проверяет длину и символы метки
+func validateTag(tag string) error {
+	if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength {
+		return apperrors.ErrInvalidTags
+	}
+	for _, c := range tag {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '-' && c != '_' {
+			return apperrors.ErrInvalidTags
+		}
+	}
+	return nil
+}
+
+// ValidateTitle проверяет длину заголовка и отсутствие управляющих символов
+func ValidateTitle(title string) error {
+	if !utf8.ValidString(title) || utf8.RuneCountInString(title) > MaxTitleLength {
+		return apperrors.ErrInvalidTitle
+	}
+	for _, c := range title {
+		if unicode.IsControl(c) {
+			return apperrors.ErrInvalidTitle
+		}
+	}
+	return nil
+}
+
+// ValidateNote проверяет длину заметки
+func ValidateNote(note string) error {
+	if !utf8.ValidString(note) || utf8.RuneCountInString(note) > MaxNoteLength {
+		return apperrors.ErrInvalidNote
+	}
+	return nil
+}
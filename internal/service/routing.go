@@ -0,0 +1,91 @@
+package service
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/Eorthus/shorturl/internal/apperrors"
+	"github.com/Eorthus/shorturl/internal/models"
+	"github.com/Eorthus/shorturl/internal/routing"
+	"github.com/Eorthus/shorturl/internal/utils"
+)
+
+// Ограничения правил выбора адреса назначения
+const (
+	// MaxRoutingRules максимальное число правил у ссылки
+	MaxRoutingRules = 20
+	// MaxRoutingVariants максимальное число вариантов у ссылки
+	MaxRoutingVariants = 10
+	// MaxVariantWeight максимальный вес варианта
+	MaxVariantWeight = 1000
+	// MaxVariantNameLength максимальная длина имени варианта
+	MaxVariantNameLength = 32
+)
+
+// NormalizeRouting проверяет правила выбора адреса назначения и приводит платформу,
+// язык и хост источника к нижнему регистру. Пустые правила заменяются на nil.
+//
+// Правило должно задавать хотя бы одно условие, вариант — имя из латинских букв,
+// цифр, "-" и "_" и вес от 1 до MaxVariantWeight; имена вариантов не повторяются.
+func NormalizeRouting(rules *models.Routing) (*models.Routing, error) {
+	if rules == nil || (len(rules.Rules) == 0 && len(rules.Variants) == 0) {
+		return nil, nil
+	}
+	if len(rules.Rules) > MaxRoutingRules || len(rules.Variants) > MaxRoutingVariants {
+		return nil, apperrors.ErrInvalidRouting
+	}
+
+	normalized := &models.Routing{}
+	for _, rule := range rules.Rules {
+		rule.Platform = strings.ToLower(strings.TrimSpace(rule.Platform))
+		rule.Language = strings.ToLower(strings.TrimSpace(rule.Language))
+		rule.Referrer = strings.ToLower(strings.TrimSpace(rule.Referrer))
+		if rule.Platform == "" && rule.Language == "" && rule.Referrer == "" {
+			return nil, apperrors.ErrInvalidRouting
+		}
+		if rule.Platform != "" && !routing.ValidPlatform(rule.Platform) {
+			return nil, apperrors.ErrInvalidRouting
+		}
+		if rule.Referrer != "" && !validHost(rule.Referrer) {
+			return nil, apperrors.ErrInvalidRouting
+		}
+		if utils.IsValidURL(rule.URL) != nil {
+			return nil, apperrors.ErrInvalidRouting
+		}
+		normalized.Rules = append(normalized.Rules, rule)
+	}
+
+	names := make(map[string]bool, len(rules.Variants))
+	for _, variant := range rules.Variants {
+		if !validVariantName(variant.Name) || names[variant.Name] {
+			return nil, apperrors.ErrInvalidRouting
+		}
+		if variant.Weight < 1 || variant.Weight > MaxVariantWeight || utils.IsValidURL(variant.URL) != nil {
+			return nil, apperrors.ErrInvalidRouting
+		}
+		names[variant.Name] = true
+		normalized.Variants = append(normalized.Variants, variant)
+	}
+	return normalized, nil
+}
+
+// validVariantName проверяет имя варианта, которое попадает в cookie
+func validVariantName(name string) bool {
+	if name == "" || len(name) > MaxVariantNameLength {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// validHost проверяет, что значение является хостом без схемы, порта и пути
+func validHost(host string) bool {
+	u, err := url.Parse("http://" + host)
+	return err == nil && u.Host == host && u.Port() == "" && u.User == nil
+}
@@ -3,84 +3,646 @@ package service
 
 import (
 	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/Eorthus/shorturl/internal/apperrors"
+	"github.com/Eorthus/shorturl/internal/idgen"
 	"github.com/Eorthus/shorturl/internal/models"
 	"github.com/Eorthus/shorturl/internal/storage"
 	"github.com/Eorthus/shorturl/internal/utils"
 )
 
+// Ограничения размера страницы URL пользователя
+const (
+	// DefaultPageLimit размер страницы, если лимит не задан
+	DefaultPageLimit = 100
+	// MaxPageLimit максимальный размер страницы
+	MaxPageLimit = 1000
+)
+
+// DefaultMaxIDAttempts количество попыток выделить свободный короткий идентификатор
+const DefaultMaxIDAttempts = 5
+
+// shortIDStats счетчики выделения идентификаторов в /api/admin/vars:
+// allocated — выданные, collisions — попадания в занятые, exhausted — исчерпанные попытки.
+// Доля коллизий показывает, пора ли увеличивать длину идентификатора.
+var shortIDStats = expvar.NewMap("short_ids")
+
 // URLService предоставляет методы для работы с URL.
 type URLService struct {
-	store storage.Storage
+	store         storage.Storage
+	ids           idgen.IDGenerator
+	maxIDAttempts int
+	now           func() time.Time
+}
+
+// Option настраивает URLService.
+type Option func(*URLService)
+
+// WithIDGenerator задает генератор коротких идентификаторов.
+func WithIDGenerator(ids idgen.IDGenerator) Option {
+	return func(s *URLService) {
+		s.ids = ids
+	}
+}
+
+// WithClock задает источник текущего времени для сроков действия
+// и активации ссылок, по умолчанию time.Now.
+func WithClock(now func() time.Time) Option {
+	return func(s *URLService) {
+		s.now = now
+	}
+}
+
+// WithMaxIDAttempts задает количество попыток при коллизии коротких идентификаторов.
+func WithMaxIDAttempts(attempts int) Option {
+	return func(s *URLService) {
+		s.maxIDAttempts = attempts
+	}
 }
 
 // NewURLService создает новый экземпляр URLService.
-func NewURLService(store storage.Storage) *URLService {
-	return &URLService{store: store}
+// По умолчанию идентификаторы генерируются случайно из алфавита base62.
+func NewURLService(store storage.Storage, opts ...Option) *URLService {
+	s := &URLService{store: store, maxIDAttempts: DefaultMaxIDAttempts, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.ids == nil {
+		s.ids, _ = idgen.NewRandom(idgen.AlphabetBase62, idgen.DefaultLength)
+	}
+	s.maxIDAttempts = max(s.maxIDAttempts, 1)
+	return s
+}
+
+// Now возвращает текущее время по часам сервиса, см. WithClock
+func (s *URLService) Now() time.Time {
+	return s.now()
+}
+
+// LinkOptions дополнительные параметры создаваемой ссылки.
+type LinkOptions struct {
+	// Alias пользовательский короткий идентификатор вместо сгенерированного
+	Alias string
+	// NotBefore время, с которого ссылка начинает работать
+	NotBefore *time.Time
+	// ExpiresAt время окончания действия ссылки
+	ExpiresAt *time.Time
+	// TTL срок действия ссылки от момента создания, задается вместо ExpiresAt
+	TTL time.Duration
+	// MaxClicks число переходов, после которого ссылка перестает работать, 0 — без ограничения
+	MaxClicks int
+	// Password пароль для перехода по ссылке, хранится только его хеш
+	Password string
+	// RedirectCode код перенаправления: 301, 302, 307 или 308, 0 — 307
+	RedirectCode int
+	// Passthrough переносит параметры запроса и продолжение пути, см. Destination
+	Passthrough bool
+	// Title заголовок ссылки для страницы предпросмотра, см. ValidateTitle
+	Title string
+	// Tags метки ссылки, см. NormalizeTags
+	Tags []string
+	// Note заметка к ссылке
+	Note string
+	// Routing правила выбора адреса назначения, см. NormalizeRouting
+	Routing *models.Routing
+}
+
+// expiry вычисляет время окончания действия ссылки, nil для бессрочной
+func (s *URLService) expiry(opts LinkOptions) (*time.Time, error) {
+	now := s.now()
+	switch {
+	case opts.ExpiresAt != nil && opts.TTL != 0, opts.TTL < 0:
+		return nil, apperrors.ErrInvalidExpiry
+	case opts.TTL > 0:
+		expiresAt := now.Add(opts.TTL).UTC()
+		return &expiresAt, nil
+	case opts.ExpiresAt != nil:
+		if !opts.ExpiresAt.After(now) {
+			return nil, apperrors.ErrInvalidExpiry
+		}
+		expiresAt := opts.ExpiresAt.UTC()
+		return &expiresAt, nil
+	}
+	return nil, nil
 }
 
 // ShortenURL создает короткий URL из длинного.
 func (s *URLService) ShortenURL(ctx context.Context, longURL, userID string) (string, error) {
+	return s.CreateLink(ctx, longURL, userID, LinkOptions{})
+}
+
+// CreateLink создает короткий URL с дополнительными параметрами.
+// Если длинный URL уже сокращен, возвращает его идентификатор и ErrURLExists,
+// занятый псевдоним дает ErrAliasTaken.
+func (s *URLService) CreateLink(ctx context.Context, longURL, userID string, opts LinkOptions) (string, error) {
+	url, err := s.prepareLink(longURL, userID, opts)
+	if err != nil {
+		return "", err
+	}
+	return s.storeLink(ctx, url)
+}
+
+// prepareLink проверяет параметры и собирает ссылку; ShortURL равен псевдониму или пуст
+func (s *URLService) prepareLink(longURL, userID string, opts LinkOptions) (models.URLData, error) {
 	if err := utils.IsValidURL(longURL); err != nil {
-		return "", apperrors.ErrInvalidURLFormat
+		return models.URLData{}, apperrors.ErrInvalidURLFormat
+	}
+	if opts.Alias != "" {
+		if err := ValidateAlias(opts.Alias); err != nil {
+			return models.URLData{}, err
+		}
+	}
+	expiresAt, err := s.expiry(opts)
+	if err != nil {
+		return models.URLData{}, err
+	}
+	var notBefore *time.Time
+	if opts.NotBefore != nil {
+		if expiresAt != nil && !opts.NotBefore.Before(*expiresAt) {
+			return models.URLData{}, apperrors.ErrInvalidNotBefore
+		}
+		activation := opts.NotBefore.UTC()
+		notBefore = &activation
+	}
+	if opts.MaxClicks < 0 {
+		return models.URLData{}, apperrors.ErrInvalidMaxClicks
+	}
+	if opts.RedirectCode != 0 && !models.ValidRedirectCode(opts.RedirectCode) {
+		return models.URLData{}, apperrors.ErrInvalidRedirectCode
+	}
+	tags, err := NormalizeTags(opts.Tags)
+	if err != nil {
+		return models.URLData{}, err
+	}
+	if err := ValidateTitle(opts.Title); err != nil {
+		return models.URLData{}, err
+	}
+	if err := ValidateNote(opts.Note); err != nil {
+		return models.URLData{}, err
+	}
+	rules, err := NormalizeRouting(opts.Routing)
+	if err != nil {
+		return models.URLData{}, err
+	}
+
+	url := models.URLData{
+		ShortURL:     opts.Alias,
+		OriginalURL:  longURL,
+		UserID:       userID,
+		NotBefore:    notBefore,
+		ExpiresAt:    expiresAt,
+		RedirectCode: opts.RedirectCode,
+		Passthrough:  opts.Passthrough,
+		Title:        opts.Title,
+		Tags:         tags,
+		Note:         opts.Note,
+		Routing:      rules,
+	}
+	if opts.MaxClicks > 0 {
+		url.ClicksLeft = &opts.MaxClicks
+	}
+	if opts.Password != "" {
+		if url.PasswordHash, err = utils.HashPassword(opts.Password); err != nil {
+			return models.URLData{}, err
+		}
 	}
+	return url, nil
+}
 
-	shortID, err := s.store.GetShortIDByLongURL(ctx, longURL)
+// storeLink сохраняет подготовленную ссылку под псевдонимом или новым идентификатором.
+// Уже сокращенный адрес дает его идентификатор и ErrURLExists, занятый псевдоним — ErrAliasTaken.
+// Ссылка, которая больше не может сработать, удаляется и не занимает адрес.
+func (s *URLService) storeLink(ctx context.Context, url models.URLData) (string, error) {
+	shortID, err := s.store.GetShortIDByLongURL(ctx, url.OriginalURL)
 	if err == nil && shortID != "" {
-		return shortID, apperrors.ErrURLExists
+		_, err = s.store.ReleaseURL(ctx, shortID, s.now())
+		if errors.Is(err, storage.ErrURLExists) {
+			return shortID, apperrors.ErrURLExists
+		}
+		if err != nil && !errors.Is(err, storage.ErrURLNotFound) {
+			return "", err
+		}
 	}
 
-	shortID = utils.GenerateShortID()
-	err = s.store.SaveURL(ctx, shortID, longURL, userID)
+	if url.ShortURL != "" {
+		shortID, err = url.ShortURL, s.save(ctx, url)
+		if errors.Is(err, storage.ErrShortIDExists) {
+			return "", apperrors.ErrAliasTaken
+		}
+	} else {
+		shortID, err = s.saveWithNewID(ctx, url)
+	}
 	if err != nil {
+		// URL мог быть сохранен параллельным запросом между проверкой и вставкой
+		if errors.Is(err, storage.ErrURLExists) {
+			if existing, getErr := s.store.GetShortIDByLongURL(ctx, url.OriginalURL); getErr == nil && existing != "" {
+				return existing, apperrors.ErrURLExists
+			}
+		}
 		return "", err
 	}
 
 	return shortID, nil
 }
 
-// GetOriginalURL возвращает оригинальный URL по короткому идентификатору.
-func (s *URLService) GetOriginalURL(ctx context.Context, shortID string) (string, bool, error) {
-	longURL, isDeleted, err := s.store.GetURL(ctx, shortID)
+// save сохраняет ссылку; ссылки без дополнительных параметров сохраняются через SaveURL
+func (s *URLService) save(ctx context.Context, url models.URLData) error {
+	if url.Plain() {
+		return s.store.SaveURL(ctx, url.ShortURL, url.OriginalURL, url.UserID)
+	}
+	return s.store.SaveURLData(ctx, []models.URLData{url})
+}
+
+// saveWithNewID сохраняет URL под новым идентификатором,
+// при коллизии повторяя попытку со свежим идентификатором
+func (s *URLService) saveWithNewID(ctx context.Context, url models.URLData) (string, error) {
+	for attempt := 1; ; attempt++ {
+		shortID, err := s.ids.NewID()
+		if err != nil {
+			return "", err
+		}
+
+		url.ShortURL = shortID
+		err = s.save(ctx, url)
+		if err == nil {
+			shortIDStats.Add("allocated", 1)
+			return shortID, nil
+		}
+		if !errors.Is(err, storage.ErrShortIDExists) {
+			return "", err
+		}
 
-	if longURL == "" {
-		return "", false, apperrors.ErrNoSuchURL
+		shortIDStats.Add("collisions", 1)
+		if attempt == s.maxIDAttempts {
+			shortIDStats.Add("exhausted", 1)
+			return "", fmt.Errorf("failed to allocate short ID after %d attempts: %w", attempt, err)
+		}
+	}
+}
+
+// GetOriginalURL возвращает оригинальный URL по короткому идентификатору
+// и засчитывает переход. Второе значение true означает, что ссылка удалена,
+// ее срок действия истек или переходы исчерпаны. Пароль ссылки не проверяется.
+func (s *URLService) GetOriginalURL(ctx context.Context, shortID string) (string, bool, error) {
+	url, gone, err := s.GetLink(ctx, shortID)
+	if err != nil || gone {
+		return url.OriginalURL, gone, err
 	}
 
+	ok, err := s.Visit(ctx, url)
 	if err != nil {
 		return "", false, err
 	}
+	return url.OriginalURL, !ok, nil
+}
+
+// GetLink возвращает ссылку по короткому идентификатору, не засчитывая переход.
+// Второе значение true означает, что ссылка удалена или ее срок действия истек.
+func (s *URLService) GetLink(ctx context.Context, shortID string) (models.URLData, bool, error) {
+	url, found, err := s.store.GetURLData(ctx, shortID)
+	if err != nil {
+		return models.URLData{}, false, err
+	}
+	if !found {
+		return models.URLData{}, false, apperrors.ErrNoSuchURL
+	}
+	return url, url.IsDeleted || url.Expired(s.now()), nil
+}
 
-	return longURL, isDeleted, nil
+// Visit засчитывает переход по ссылке, полученной из GetLink.
+// Возвращает false, если переходы по ссылке исчерпаны.
+func (s *URLService) Visit(ctx context.Context, url models.URLData) (bool, error) {
+	if url.ClicksLeft == nil {
+		return true, nil
+	}
+	// Решение принимает хранилище: закэшированный остаток мог устареть
+	return s.store.ConsumeClick(ctx, url.ShortURL)
+}
+
+// CheckPassword проверяет пароль защищенной ссылки
+func (s *URLService) CheckPassword(url models.URLData, password string) bool {
+	return !url.Protected() || utils.CheckPassword(url.PasswordHash, password)
+}
+
+// UpdateLink меняет адрес назначения, заголовок, метки и заметку ссылки пользователя
+// и возвращает обновленную ссылку. Незаданные поля update не меняются,
+// прежний адрес остается в истории. Пустой запрос дает ErrEmptyURL,
+// чужая или удаленная ссылка — ErrNoSuchURL, уже сокращенный адрес — ErrURLExists.
+func (s *URLService) UpdateLink(ctx context.Context, shortID, userID string, update models.UpdateURLRequest) (models.URLData, error) {
+	if update.URL == "" && update.Title == nil && update.Tags == nil && update.Note == nil {
+		return models.URLData{}, apperrors.ErrEmptyURL
+	}
+	if update.URL != "" {
+		if err := utils.IsValidURL(update.URL); err != nil {
+			return models.URLData{}, apperrors.ErrInvalidURLFormat
+		}
+	}
+	if update.Title != nil {
+		if err := ValidateTitle(*update.Title); err != nil {
+			return models.URLData{}, err
+		}
+	}
+	var tags []string
+	if update.Tags != nil {
+		var err error
+		if tags, err = NormalizeTags(*update.Tags); err != nil {
+			return models.URLData{}, err
+		}
+	}
+	if update.Note != nil {
+		if err := ValidateNote(*update.Note); err != nil {
+			return models.URLData{}, err
+		}
+	}
+
+	if update.URL != "" {
+		_, err := s.store.UpdateURL(ctx, shortID, userID, update.URL)
+		switch {
+		case errors.Is(err, storage.ErrURLNotFound):
+			return models.URLData{}, apperrors.ErrNoSuchURL
+		case errors.Is(err, storage.ErrURLExists):
+			return models.URLData{}, apperrors.ErrURLExists
+		case err != nil:
+			return models.URLData{}, err
+		}
+	}
+
+	url, found, err := s.store.GetURLData(ctx, shortID)
+	if err != nil {
+		return models.URLData{}, err
+	}
+	if !found || url.UserID != userID || url.IsDeleted {
+		return models.URLData{}, apperrors.ErrNoSuchURL
+	}
+	if update.Title == nil && update.Tags == nil && update.Note == nil {
+		return url, nil
+	}
+
+	if update.Title != nil {
+		url.Title = *update.Title
+	}
+	if update.Tags != nil {
+		url.Tags = tags
+	}
+	if update.Note != nil {
+		url.Note = *update.Note
+	}
+	err = s.store.SetURLLabels(ctx, shortID, userID, models.URLLabels{Title: url.Title, Tags: url.Tags, Note: url.Note})
+	if errors.Is(err, storage.ErrURLNotFound) {
+		return models.URLData{}, apperrors.ErrNoSuchURL
+	}
+	return url, err
+}
+
+// SetLinkRouting заменяет правила выбора адреса назначения ссылки пользователя,
+// nil или пустые правила удаляют их. Чужая или удаленная ссылка дает ErrNoSuchURL.
+func (s *URLService) SetLinkRouting(ctx context.Context, shortID, userID string, rules *models.Routing) error {
+	rules, err := NormalizeRouting(rules)
+	if err != nil {
+		return err
+	}
+	err = s.store.SetURLRouting(ctx, shortID, userID, rules)
+	if errors.Is(err, storage.ErrURLNotFound) {
+		return apperrors.ErrNoSuchURL
+	}
+	return err
+}
+
+// GetUserTags возвращает метки неудаленных ссылок пользователя с числом ссылок.
+func (s *URLService) GetUserTags(ctx context.Context, userID string) ([]models.TagCount, error) {
+	return s.store.GetUserTags(ctx, userID)
+}
+
+// GetLinkHistory возвращает версии адреса назначения ссылки пользователя.
+// Чужая ссылка дает ErrNoSuchURL.
+func (s *URLService) GetLinkHistory(ctx context.Context, shortID, userID string) ([]models.URLVersion, error) {
+	versions, err := s.store.GetURLHistory(ctx, shortID, userID)
+	if errors.Is(err, storage.ErrURLNotFound) {
+		return nil, apperrors.ErrNoSuchURL
+	}
+	return versions, err
+}
+
+// batchLinkOptions параметры ссылки из запроса пакетного сокращения
+func batchLinkOptions(req models.BatchRequest) LinkOptions {
+	return LinkOptions{
+		Alias:        req.Alias,
+		NotBefore:    req.NotBefore,
+		ExpiresAt:    req.ExpiresAt,
+		TTL:          time.Duration(req.TTL),
+		MaxClicks:    req.MaxClicks,
+		Password:     req.Password,
+		RedirectCode: req.RedirectCode,
+		Passthrough:  req.Passthrough,
+		Title:        req.Title,
+		Tags:         req.Tags,
+		Note:         req.Note,
+		Routing:      req.Routing,
+	}
+}
+
+// batchLink ссылка пакета и итог ее создания
+type batchLink struct {
+	url models.URLData
+	// aliased - ShortURL задан пользователем, а не сгенерирован
+	aliased bool
+	err     error
+}
+
+// prepareBatch проверяет запросы пакета; ошибка запроса записывается в его batchLink
+func (s *URLService) prepareBatch(requests []models.BatchRequest, userID string) []batchLink {
+	links := make([]batchLink, len(requests))
+	for i, req := range requests {
+		url, err := s.prepareLink(req.OriginalURL, userID, batchLinkOptions(req))
+		links[i] = batchLink{url: url, aliased: req.Alias != "", err: err}
+	}
+	return links
+}
+
+// saveBatch сохраняет проверенные ссылки пакета одним вызовом SaveURLData.
+// Если хранилище отклоняет пакет из-за занятого адреса или идентификатора,
+// а также для повторов адреса или псевдонима внутри пакета, ссылки сохраняются
+// по одной, как в CreateLink, и конфликт записывается в batchLink.
+// Ошибка возвращается, только если недоступно хранилище или отменен контекст.
+func (s *URLService) saveBatch(ctx context.Context, links []batchLink) error {
+	var batch, deferred []int
+	longURLs := make(map[string]bool)
+	aliases := make(map[string]bool)
+	for i := range links {
+		link := &links[i]
+		if link.err != nil {
+			continue
+		}
+		if longURLs[link.url.OriginalURL] || (link.aliased && aliases[link.url.ShortURL]) {
+			deferred = append(deferred, i)
+			continue
+		}
+		longURLs[link.url.OriginalURL] = true
+		if link.aliased {
+			aliases[link.url.ShortURL] = true
+		} else {
+			shortID, err := s.ids.NewID()
+			if err != nil {
+				return err
+			}
+			link.url.ShortURL = shortID
+		}
+		batch = append(batch, i)
+	}
+
+	if len(batch) > 0 {
+		urls := make([]models.URLData, len(batch))
+		for j, i := range batch {
+			urls[j] = links[i].url
+		}
+		err := s.store.SaveURLData(ctx, urls)
+		switch {
+		case err == nil:
+			for _, i := range batch {
+				if !links[i].aliased {
+					shortIDStats.Add("allocated", 1)
+				}
+			}
+		case errors.Is(err, storage.ErrURLExists), errors.Is(err, storage.ErrShortIDExists):
+			// Пакет сохраняется атомарно, поэтому ни одна ссылка не создана
+			for _, i := range batch {
+				if !links[i].aliased {
+					links[i].url.ShortURL = ""
+				}
+			}
+			deferred = append(batch, deferred...)
+			slices.Sort(deferred)
+		default:
+			return err
+		}
+	}
+
+	for _, i := range deferred {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		link := &links[i]
+		shortID, err := s.storeLink(ctx, link.url)
+		var appErr apperrors.AppError
+		if err != nil && !errors.As(err, &appErr) {
+			return err
+		}
+		link.url.ShortURL, link.err = shortID, err
+	}
+	return nil
 }
 
 // SaveURLBatch сохраняет множество URL в пакетном режиме.
+// Недопустимый запрос отклоняет пакет до сохранения. Пакет сохраняется одним
+// вызовом хранилища; при конфликте адреса или псевдонима возвращается первая
+// ошибка, остальные ссылки пакета при этом могут быть созданы.
 func (s *URLService) SaveURLBatch(ctx context.Context, requests []models.BatchRequest, userID string) ([]models.BatchResponse, error) {
+	links := s.prepareBatch(requests, userID)
+	for _, link := range links {
+		if link.err != nil {
+			return nil, link.err
+		}
+	}
+	if err := s.saveBatch(ctx, links); err != nil {
+		return nil, err
+	}
+
 	responses := make([]models.BatchResponse, len(requests))
-	for i, req := range requests {
-		shortID, err := s.ShortenURL(ctx, req.OriginalURL, userID)
-		if err != nil {
-			return nil, err
+	for i, link := range links {
+		if link.err != nil {
+			return nil, link.err
 		}
 		responses[i] = models.BatchResponse{
-			CorrelationID: req.CorrelationID,
-			ShortURL:      shortID,
+			CorrelationID: requests[i].CorrelationID,
+			ShortURL:      link.url.ShortURL,
 		}
 	}
 	return responses, nil
 }
 
+// ImportURLBatch сохраняет множество URL тем же путем, что и SaveURLBatch, но
+// недопустимые запросы и конфликты не прерывают пакет: для каждого запроса
+// возвращается свой результат с пустым Row. Уже сокращенный адрес дает
+// ImportExists с существующим идентификатором.
+// Ошибка возвращается, только если недоступно хранилище или отменен контекст.
+func (s *URLService) ImportURLBatch(ctx context.Context, requests []models.BatchRequest, userID string) ([]models.ImportResult, error) {
+	links := s.prepareBatch(requests, userID)
+	if err := s.saveBatch(ctx, links); err != nil {
+		return nil, err
+	}
+
+	results := make([]models.ImportResult, len(requests))
+	for i, link := range links {
+		result := models.ImportResult{OriginalURL: requests[i].OriginalURL}
+		var appErr apperrors.AppError
+		switch {
+		case link.err == nil:
+			result.Status = models.ImportCreated
+			result.ShortURL = link.url.ShortURL
+		case errors.Is(link.err, apperrors.ErrURLExists):
+			result.Status = models.ImportExists
+			result.ShortURL = link.url.ShortURL
+		case errors.As(link.err, &appErr):
+			result.Status = models.ImportFailed
+			result.Error = appErr.Message
+		default:
+			return nil, link.err
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
 // GetUserURLs возвращает все URL пользователя.
 func (s *URLService) GetUserURLs(ctx context.Context, userID string) ([]models.URLData, error) {
 	return s.store.GetUserURLs(ctx, userID)
 }
 
+// GetUserURLsPage возвращает страницу URL пользователя.
+// Нулевой лимит заменяется на DefaultPageLimit, слишком большой ограничивается MaxPageLimit.
+func (s *URLService) GetUserURLsPage(ctx context.Context, userID string, query models.URLQuery) (models.URLPage, error) {
+	if query.Limit < 0 {
+		return models.URLPage{}, apperrors.ErrInvalidQuery
+	}
+	if query.Limit == 0 {
+		query.Limit = DefaultPageLimit
+	}
+	query.Limit = min(query.Limit, MaxPageLimit)
+
+	query.Tag = strings.ToLower(strings.TrimSpace(query.Tag))
+
+	switch query.Sort {
+	case "":
+		query.Sort = models.SortCreatedAsc
+	case models.SortCreatedAsc, models.SortCreatedDesc:
+	default:
+		return models.URLPage{}, apperrors.ErrInvalidQuery
+	}
+
+	page, err := s.store.GetUserURLsPage(ctx, userID, query)
+	if errors.Is(err, storage.ErrInvalidCursor) {
+		return models.URLPage{}, apperrors.ErrInvalidQuery
+	}
+	return page, err
+}
+
 // DeleteUserURLs помечает URL пользователя как удаленные.
 func (s *URLService) DeleteUserURLs(ctx context.Context, shortIDs []string, userID string) error {
 	return s.store.MarkURLsAsDeleted(ctx, shortIDs, userID)
 }
 
+// RestoreUserURLs снимает пометку удаления с URL пользователя и возвращает
+// идентификаторы восстановленных URL. Окончательно удаленные URL не восстанавливаются.
+func (s *URLService) RestoreUserURLs(ctx context.Context, shortIDs []string, userID string) ([]string, error) {
+	return s.store.RestoreURLs(ctx, shortIDs, userID)
+}
+
 // Ping проверяет доступность хранилища.
 func (s *URLService) Ping(ctx context.Context) error {
 	return s.store.Ping(ctx)
@@ -2,12 +2,17 @@ package service
 
 import (
 	"context"
+	"expvar"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/Eorthus/shorturl/internal/apperrors"
 	"github.com/Eorthus/shorturl/internal/models"
 	"github.com/Eorthus/shorturl/internal/storage"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestShortenURL(t *testing.T) {
@@ -130,6 +135,107 @@ func TestDeleteUserURLs(t *testing.T) {
 	assert.True(t, isDeleted)
 }
 
+func TestImportURLBatch(t *testing.T) {
+	ctx := context.Background()
+	store, _ := storage.NewMemoryStorage(ctx)
+	service := NewURLService(store)
+
+	existing, err := service.ShortenURL(ctx, "https://existing.example.com", "user123")
+	require.NoError(t, err)
+
+	results, err := service.ImportURLBatch(ctx, []models.BatchRequest{
+		{OriginalURL: "https://one.example.com", Alias: "one", Tags: []string{"Import"}},
+		{OriginalURL: "not a url"},
+		{OriginalURL: "https://existing.example.com"},
+		{OriginalURL: "https://two.example.com", Alias: "one"},
+		{OriginalURL: "https://three.example.com"},
+	}, "user123")
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+
+	assert.Equal(t, models.ImportResult{OriginalURL: "https://one.example.com", ShortURL: "one", Status: models.ImportCreated}, results[0])
+	assert.Equal(t, models.ImportFailed, results[1].Status)
+	assert.Equal(t, "Invalid URL format", results[1].Error)
+	assert.Equal(t, models.ImportExists, results[2].Status)
+	assert.Equal(t, existing, results[2].ShortURL)
+	assert.Equal(t, models.ImportFailed, results[3].Status)
+	assert.Equal(t, "Alias already taken", results[3].Error)
+	assert.Equal(t, models.ImportCreated, results[4].Status, "Ошибка строки не прерывает импорт")
+	assert.NotEmpty(t, results[4].ShortURL)
+
+	url, found, err := store.GetURLData(ctx, "one")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, []string{"import"}, url.Tags)
+	assert.Equal(t, "user123", url.UserID)
+}
+
+// countingStorage считает вызовы SaveURLData
+type countingStorage struct {
+	storage.Storage
+	saves int
+}
+
+func (cs *countingStorage) SaveURLData(ctx context.Context, urls []models.URLData) error {
+	cs.saves++
+	return cs.Storage.SaveURLData(ctx, urls)
+}
+
+func TestImportURLBatchSavesOnce(t *testing.T) {
+	ctx := context.Background()
+	memory, _ := storage.NewMemoryStorage(ctx)
+	store := &countingStorage{Storage: memory}
+	service := NewURLService(store)
+
+	requests := make([]models.BatchRequest, 50)
+	for i := range requests {
+		requests[i] = models.BatchRequest{OriginalURL: fmt.Sprintf("https://bulk%d.example.com", i)}
+	}
+	results, err := service.ImportURLBatch(ctx, requests, "user123")
+	require.NoError(t, err)
+	assert.Equal(t, 1, store.saves, "Пакет сохраняется одним вызовом хранилища")
+	for _, result := range results {
+		assert.Equal(t, models.ImportCreated, result.Status)
+	}
+
+	t.Run("Повторы внутри пакета", func(t *testing.T) {
+		results, err := service.ImportURLBatch(ctx, []models.BatchRequest{
+			{OriginalURL: "https://dup.example.com", Alias: "dup"},
+			{OriginalURL: "https://dup.example.com"},
+			{OriginalURL: "https://other.example.com", Alias: "dup"},
+		}, "user123")
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		assert.Equal(t, models.ImportCreated, results[0].Status)
+		assert.Equal(t, models.ImportExists, results[1].Status)
+		assert.Equal(t, "dup", results[1].ShortURL)
+		assert.Equal(t, models.ImportFailed, results[2].Status)
+		assert.Equal(t, "Alias already taken", results[2].Error)
+	})
+}
+
+func TestRestoreUserURLs(t *testing.T) {
+	ctx := context.Background()
+	store, _ := storage.NewMemoryStorage(ctx)
+	service := NewURLService(store)
+
+	shortID, _ := service.ShortenURL(ctx, "https://example.com", "user123")
+	require.NoError(t, service.DeleteUserURLs(ctx, []string{shortID}, "user123"))
+
+	restored, err := service.RestoreUserURLs(ctx, []string{shortID}, "other")
+	assert.NoError(t, err)
+	assert.Empty(t, restored, "Чужой URL не восстанавливается")
+
+	restored, err = service.RestoreUserURLs(ctx, []string{shortID}, "user123")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{shortID}, restored)
+
+	longURL, isDeleted, err := service.GetOriginalURL(ctx, shortID)
+	assert.NoError(t, err)
+	assert.False(t, isDeleted)
+	assert.Equal(t, "https://example.com", longURL)
+}
+
 func TestPing(t *testing.T) {
 	ctx := context.Background()
 	store, _ := storage.NewMemoryStorage(ctx)
@@ -138,3 +244,490 @@ func TestPing(t *testing.T) {
 	err := service.Ping(ctx)
 	assert.NoError(t, err)
 }
+
+func TestGetUserURLsPage(t *testing.T) {
+	ctx := context.Background()
+	store, _ := storage.NewMemoryStorage(ctx)
+	service := NewURLService(store)
+
+	for i := 0; i < 3; i++ {
+		_, err := service.ShortenURL(ctx, fmt.Sprintf("https://page%d.example.com", i), "user1")
+		assert.NoError(t, err)
+	}
+
+	t.Run("Лимит по умолчанию", func(t *testing.T) {
+		page, err := service.GetUserURLsPage(ctx, "user1", models.URLQuery{})
+		assert.NoError(t, err)
+		assert.Len(t, page.URLs, 3)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("Следующая страница", func(t *testing.T) {
+		page, err := service.GetUserURLsPage(ctx, "user1", models.URLQuery{Limit: 2})
+		assert.NoError(t, err)
+		assert.Len(t, page.URLs, 2)
+		assert.NotEmpty(t, page.NextCursor)
+
+		page, err = service.GetUserURLsPage(ctx, "user1", models.URLQuery{Limit: 2, Cursor: page.NextCursor})
+		assert.NoError(t, err)
+		assert.Len(t, page.URLs, 1)
+	})
+
+	t.Run("Некорректные параметры", func(t *testing.T) {
+		_, err := service.GetUserURLsPage(ctx, "user1", models.URLQuery{Limit: -1})
+		assert.Equal(t, apperrors.ErrInvalidQuery, err)
+
+		_, err = service.GetUserURLsPage(ctx, "user1", models.URLQuery{Sort: "name"})
+		assert.Equal(t, apperrors.ErrInvalidQuery, err)
+
+		_, err = service.GetUserURLsPage(ctx, "user1", models.URLQuery{Cursor: "broken"})
+		assert.Equal(t, apperrors.ErrInvalidQuery, err)
+	})
+}
+
+// fixedIDs выдает идентификаторы по порядку
+type fixedIDs struct {
+	ids []string
+}
+
+func (g *fixedIDs) NewID() (string, error) {
+	id := g.ids[0]
+	g.ids = g.ids[1:]
+	return id, nil
+}
+
+func TestWithIDGenerator(t *testing.T) {
+	ctx := context.Background()
+	store, _ := storage.NewMemoryStorage(ctx)
+	service := NewURLService(store, WithIDGenerator(&fixedIDs{ids: []string{"first", "second"}}))
+
+	shortID, err := service.ShortenURL(ctx, "https://first.example.com", "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, "first", shortID)
+
+	shortID, err = service.ShortenURL(ctx, "https://second.example.com", "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, "second", shortID)
+}
+
+func TestShortenURL_Collisions(t *testing.T) {
+	ctx := context.Background()
+	counter := func(name string) int64 {
+		if v, ok := shortIDStats.Get(name).(*expvar.Int); ok {
+			return v.Value()
+		}
+		return 0
+	}
+
+	t.Run("Повтор со свежим идентификатором", func(t *testing.T) {
+		store, _ := storage.NewMemoryStorage(ctx)
+		require.NoError(t, store.SaveURL(ctx, "taken", "https://taken.example.com", "user1"))
+		collisions := counter("collisions")
+
+		service := NewURLService(store, WithIDGenerator(&fixedIDs{ids: []string{"taken", "taken", "free"}}))
+		shortID, err := service.ShortenURL(ctx, "https://new.example.com", "user1")
+		require.NoError(t, err)
+		assert.Equal(t, "free", shortID)
+		assert.Equal(t, int64(2), counter("collisions")-collisions)
+
+		longURL, _, err := store.GetURL(ctx, "taken")
+		require.NoError(t, err)
+		assert.Equal(t, "https://taken.example.com", longURL, "Занятый URL не должен перезаписываться")
+	})
+
+	t.Run("Попытки исчерпаны", func(t *testing.T) {
+		store, _ := storage.NewMemoryStorage(ctx)
+		require.NoError(t, store.SaveURL(ctx, "taken", "https://taken.example.com", "user1"))
+		exhausted := counter("exhausted")
+
+		service := NewURLService(store,
+			WithIDGenerator(&fixedIDs{ids: []string{"taken", "taken", "free"}}),
+			WithMaxIDAttempts(2),
+		)
+		_, err := service.ShortenURL(ctx, "https://new.example.com", "user1")
+		assert.ErrorIs(t, err, storage.ErrShortIDExists)
+		assert.Equal(t, int64(1), counter("exhausted")-exhausted)
+	})
+}
+
+func TestCreateLink_Expiry(t *testing.T) {
+	ctx := context.Background()
+	store, _ := storage.NewMemoryStorage(ctx)
+	service := NewURLService(store)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+
+	t.Run("TTL", func(t *testing.T) {
+		shortID, err := service.CreateLink(ctx, "https://ttl.example.com", "user1", LinkOptions{TTL: time.Hour})
+		require.NoError(t, err)
+
+		url, found, err := store.GetURLData(ctx, shortID)
+		require.NoError(t, err)
+		require.True(t, found)
+		require.NotNil(t, url.ExpiresAt)
+		assert.Equal(t, now.Add(time.Hour), *url.ExpiresAt)
+
+		_, gone, err := service.GetOriginalURL(ctx, shortID)
+		require.NoError(t, err)
+		assert.False(t, gone)
+
+		service.now = func() time.Time { return now.Add(time.Hour) }
+		defer func() { service.now = func() time.Time { return now } }()
+		longURL, gone, err := service.GetOriginalURL(ctx, shortID)
+		require.NoError(t, err)
+		assert.True(t, gone, "Истекшая ссылка недоступна так же, как удаленная")
+		assert.Equal(t, "https://ttl.example.com", longURL)
+	})
+
+	t.Run("Повторное сокращение после истечения", func(t *testing.T) {
+		expired, err := service.CreateLink(ctx, "https://again.example.com", "user1", LinkOptions{TTL: time.Hour})
+		require.NoError(t, err)
+
+		shortID, err := service.CreateLink(ctx, "https://again.example.com", "user2", LinkOptions{})
+		assert.Equal(t, apperrors.ErrURLExists, err, "Работающая ссылка занимает адрес")
+		assert.Equal(t, expired, shortID)
+
+		service.now = func() time.Time { return now.Add(time.Hour) }
+		defer func() { service.now = func() time.Time { return now } }()
+		shortID, err = service.CreateLink(ctx, "https://again.example.com", "user2", LinkOptions{})
+		require.NoError(t, err, "Истекшая ссылка не занимает адрес")
+		assert.NotEqual(t, expired, shortID)
+
+		_, found, err := store.GetURLData(ctx, expired)
+		require.NoError(t, err)
+		assert.False(t, found, "Истекшая ссылка удаляется")
+	})
+
+	t.Run("Абсолютный срок", func(t *testing.T) {
+		expiresAt := now.Add(24 * time.Hour)
+		shortID, err := service.CreateLink(ctx, "https://until.example.com", "user1", LinkOptions{ExpiresAt: &expiresAt})
+		require.NoError(t, err)
+
+		url, _, err := store.GetURLData(ctx, shortID)
+		require.NoError(t, err)
+		require.NotNil(t, url.ExpiresAt)
+		assert.True(t, expiresAt.Equal(*url.ExpiresAt))
+	})
+
+	past := now.Add(-time.Second)
+	for name, opts := range map[string]LinkOptions{
+		"Срок в прошлом":        {ExpiresAt: &now},
+		"Отрицательный TTL":     {TTL: -time.Hour},
+		"TTL и абсолютный срок": {ExpiresAt: &past, TTL: time.Hour},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := service.CreateLink(ctx, "https://invalid.example.com", "user1", opts)
+			assert.Equal(t, apperrors.ErrInvalidExpiry, err)
+		})
+	}
+
+	t.Run("Пакет", func(t *testing.T) {
+		responses, err := service.SaveURLBatch(ctx, []models.BatchRequest{
+			{CorrelationID: "1", OriginalURL: "https://batch-ttl.example.com", TTL: models.Duration(time.Minute)},
+			{CorrelationID: "2", OriginalURL: "https://batch-plain.example.com"},
+		}, "user1")
+		require.NoError(t, err)
+
+		url, _, err := store.GetURLData(ctx, responses[0].ShortURL)
+		require.NoError(t, err)
+		require.NotNil(t, url.ExpiresAt)
+		assert.Equal(t, now.Add(time.Minute), *url.ExpiresAt)
+
+		url, _, err = store.GetURLData(ctx, responses[1].ShortURL)
+		require.NoError(t, err)
+		assert.Nil(t, url.ExpiresAt)
+	})
+}
+
+func TestCreateLink_NotBefore(t *testing.T) {
+	ctx := context.Background()
+	store, _ := storage.NewMemoryStorage(ctx)
+	service := NewURLService(store)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	service.now = func() time.Time { return now }
+
+	launch := time.Date(2024, 3, 2, 15, 0, 0, 0, time.FixedZone("MSK", 3*60*60))
+	shortID, err := service.CreateLink(ctx, "https://launch.example.com", "user1", LinkOptions{NotBefore: &launch, TTL: 48 * time.Hour})
+	require.NoError(t, err)
+
+	url, gone, err := service.GetLink(ctx, shortID)
+	require.NoError(t, err)
+	assert.False(t, gone, "Еще не начавшая работать ссылка не считается недоступной навсегда")
+	require.NotNil(t, url.NotBefore)
+	assert.Equal(t, launch.UTC(), *url.NotBefore)
+	assert.True(t, url.Pending(now))
+	assert.True(t, url.Pending(launch.Add(-time.Nanosecond)))
+	assert.False(t, url.Pending(launch), "Ссылка работает с указанного момента")
+
+	expiresAt := launch.Add(-time.Minute)
+	_, err = service.CreateLink(ctx, "https://invalid.example.com", "user1", LinkOptions{NotBefore: &launch, ExpiresAt: &expiresAt})
+	assert.Equal(t, apperrors.ErrInvalidNotBefore, err, "Ссылка должна успеть поработать")
+}
+
+func TestCreateLink_MaxClicks(t *testing.T) {
+	ctx := context.Background()
+	memory, _ := storage.NewMemoryStorage(ctx)
+	// Кэш не должен позволять переходы сверх лимита
+	service := NewURLService(storage.NewCachedStorage(memory, 10, time.Minute))
+
+	shortID, err := service.CreateLink(ctx, "https://once.example.com", "user1", LinkOptions{MaxClicks: 2})
+	require.NoError(t, err)
+
+	for i := range 2 {
+		longURL, gone, err := service.GetOriginalURL(ctx, shortID)
+		require.NoError(t, err)
+		assert.False(t, gone, "Переход %d должен быть разрешен", i+1)
+		assert.Equal(t, "https://once.example.com", longURL)
+	}
+	_, gone, err := service.GetOriginalURL(ctx, shortID)
+	require.NoError(t, err)
+	assert.True(t, gone, "После исчерпания переходов ссылка недоступна")
+
+	renewed, err := service.CreateLink(ctx, "https://once.example.com", "user2", LinkOptions{})
+	require.NoError(t, err, "Ссылка с исчерпанными переходами не занимает адрес")
+	assert.NotEqual(t, shortID, renewed)
+	longURL, gone, err := service.GetOriginalURL(ctx, renewed)
+	require.NoError(t, err)
+	assert.False(t, gone)
+	assert.Equal(t, "https://once.example.com", longURL)
+	_, gone, err = service.GetOriginalURL(ctx, shortID)
+	assert.ErrorIs(t, err, apperrors.ErrNoSuchURL, "Исчерпанная ссылка удаляется")
+	assert.False(t, gone)
+
+	_, err = service.CreateLink(ctx, "https://negative.example.com", "user1", LinkOptions{MaxClicks: -1})
+	assert.Equal(t, apperrors.ErrInvalidMaxClicks, err)
+}
+
+func TestCreateLink_Password(t *testing.T) {
+	ctx := context.Background()
+	store, _ := storage.NewMemoryStorage(ctx)
+	service := NewURLService(store)
+
+	shortID, err := service.CreateLink(ctx, "https://secret.example.com", "user1", LinkOptions{Password: "secret", MaxClicks: 1})
+	require.NoError(t, err)
+
+	url, gone, err := service.GetLink(ctx, shortID)
+	require.NoError(t, err)
+	assert.False(t, gone)
+	assert.True(t, url.Protected())
+	assert.NotEqual(t, "secret", url.PasswordHash, "Пароль хранится только в виде хеша")
+	assert.True(t, service.CheckPassword(url, "secret"))
+	assert.False(t, service.CheckPassword(url, "wrong"))
+
+	// GetLink не засчитывает переход, поэтому показ формы не тратит лимит
+	ok, err := service.Visit(ctx, url)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = service.Visit(ctx, url)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	plainID, err := service.ShortenURL(ctx, "https://plain.example.com", "user1")
+	require.NoError(t, err)
+	plain, _, err := service.GetLink(ctx, plainID)
+	require.NoError(t, err)
+	assert.False(t, plain.Protected())
+	assert.True(t, service.CheckPassword(plain, ""))
+}
+
+func TestUpdateLink(t *testing.T) {
+	ctx := context.Background()
+	memory, _ := storage.NewMemoryStorage(ctx)
+	// Кэш не должен отдавать прежний адрес и прежнюю дедупликацию
+	service := NewURLService(storage.NewCachedStorage(memory, 10, time.Minute))
+
+	shortID, err := service.ShortenURL(ctx, "https://v1.example.com", "user1")
+	require.NoError(t, err)
+	_, _, err = service.GetOriginalURL(ctx, shortID)
+	require.NoError(t, err)
+	_, err = service.ShortenURL(ctx, "https://v1.example.com", "user1")
+	require.Equal(t, apperrors.ErrURLExists, err)
+
+	update := func(userID string, request models.UpdateURLRequest) error {
+		_, err := service.UpdateLink(ctx, shortID, userID, request)
+		return err
+	}
+	url, err := service.UpdateLink(ctx, shortID, "user1", models.UpdateURLRequest{URL: "https://v2.example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://v2.example.com", url.OriginalURL)
+	assert.Equal(t, apperrors.ErrNoSuchURL, update("user2", models.UpdateURLRequest{URL: "https://v3.example.com"}))
+	assert.Equal(t, apperrors.ErrInvalidURLFormat, update("user1", models.UpdateURLRequest{URL: "v3"}))
+	assert.Equal(t, apperrors.ErrEmptyURL, update("user1", models.UpdateURLRequest{}))
+
+	longURL, _, err := service.GetOriginalURL(ctx, shortID)
+	require.NoError(t, err)
+	assert.Equal(t, "https://v2.example.com", longURL)
+
+	_, err = service.ShortenURL(ctx, "https://v1.example.com", "user1")
+	assert.NoError(t, err, "Прежний адрес освобождается")
+	_, err = service.ShortenURL(ctx, "https://v2.example.com", "user1")
+	assert.Equal(t, apperrors.ErrURLExists, err)
+
+	versions, err := service.GetLinkHistory(ctx, shortID, "user1")
+	require.NoError(t, err)
+	assert.Len(t, versions, 2)
+	_, err = service.GetLinkHistory(ctx, shortID, "user2")
+	assert.Equal(t, apperrors.ErrNoSuchURL, err)
+}
+
+func TestLinkLabels(t *testing.T) {
+	ctx := context.Background()
+	memory, _ := storage.NewMemoryStorage(ctx)
+	service := NewURLService(storage.NewCachedStorage(memory, 10, time.Minute))
+
+	t.Run("Некорректные метки и заметка", func(t *testing.T) {
+		for _, tags := range [][]string{{""}, {"two words"}, {"a/b"}, {strings.Repeat("x", MaxTagLength+1)}} {
+			_, err := service.CreateLink(ctx, "https://labels.example.com", "user1", LinkOptions{Tags: tags})
+			assert.Equal(t, apperrors.ErrInvalidTags, err, "Метки %q", tags)
+		}
+		tooMany := make([]string, MaxTags+1)
+		for i := range tooMany {
+			tooMany[i] = fmt.Sprintf("tag%d", i)
+		}
+		_, err := service.CreateLink(ctx, "https://labels.example.com", "user1", LinkOptions{Tags: tooMany})
+		assert.Equal(t, apperrors.ErrInvalidTags, err)
+		_, err = service.CreateLink(ctx, "https://labels.example.com", "user1", LinkOptions{Note: strings.Repeat("я", MaxNoteLength+1)})
+		assert.Equal(t, apperrors.ErrInvalidNote, err)
+		for _, title := range []string{strings.Repeat("я", MaxTitleLength+1), "две\nстроки"} {
+			_, err = service.CreateLink(ctx, "https://labels.example.com", "user1", LinkOptions{Title: title})
+			assert.Equal(t, apperrors.ErrInvalidTitle, err)
+		}
+	})
+
+	shortID, err := service.CreateLink(ctx, "https://labels.example.com", "user1", LinkOptions{
+		Tags: []string{" Promo", "promo", "Весна_2024"},
+		Note: "Рассылка",
+	})
+	require.NoError(t, err)
+
+	t.Run("Метки приводятся к нижнему регистру без повторов", func(t *testing.T) {
+		url, _, err := service.GetLink(ctx, shortID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"promo", "весна_2024"}, url.Tags)
+		assert.Equal(t, "Рассылка", url.Note)
+	})
+
+	t.Run("Изменение только меток сохраняет заметку", func(t *testing.T) {
+		tags := []string{"News"}
+		url, err := service.UpdateLink(ctx, shortID, "user1", models.UpdateURLRequest{Tags: &tags})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"news"}, url.Tags)
+		assert.Equal(t, "Рассылка", url.Note)
+		assert.Equal(t, "https://labels.example.com", url.OriginalURL)
+
+		cached, _, err := service.GetLink(ctx, shortID)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"news"}, cached.Tags, "Кэш не должен отдавать прежние метки")
+	})
+
+	t.Run("Изменение заголовка", func(t *testing.T) {
+		title := "Весенняя рассылка"
+		url, err := service.UpdateLink(ctx, shortID, "user1", models.UpdateURLRequest{Title: &title})
+		require.NoError(t, err)
+		assert.Equal(t, title, url.Title)
+		assert.Equal(t, "Рассылка", url.Note)
+
+		title = ""
+		url, err = service.UpdateLink(ctx, shortID, "user1", models.UpdateURLRequest{Title: &title})
+		require.NoError(t, err)
+		assert.Empty(t, url.Title, "Пустая строка удаляет заголовок")
+	})
+
+	t.Run("Пустая заметка удаляет заметку", func(t *testing.T) {
+		note := ""
+		url, err := service.UpdateLink(ctx, shortID, "user1", models.UpdateURLRequest{Note: &note})
+		require.NoError(t, err)
+		assert.Empty(t, url.Note)
+		assert.Equal(t, []string{"news"}, url.Tags)
+	})
+
+	t.Run("Чужая ссылка", func(t *testing.T) {
+		tags := []string{"stolen"}
+		_, err := service.UpdateLink(ctx, shortID, "user2", models.UpdateURLRequest{Tags: &tags})
+		assert.Equal(t, apperrors.ErrNoSuchURL, err)
+	})
+
+	t.Run("Фильтр по метке без учета регистра", func(t *testing.T) {
+		_, err := service.CreateLink(ctx, "https://other.example.com", "user1", LinkOptions{Tags: []string{"promo"}})
+		require.NoError(t, err)
+
+		page, err := service.GetUserURLsPage(ctx, "user1", models.URLQuery{Tag: "NEWS"})
+		require.NoError(t, err)
+		require.Len(t, page.URLs, 1)
+		assert.Equal(t, shortID, page.URLs[0].ShortURL)
+
+		tags, err := service.GetUserTags(ctx, "user1")
+		require.NoError(t, err)
+		assert.Equal(t, []models.TagCount{{Tag: "news", Count: 1}, {Tag: "promo", Count: 1}}, tags)
+	})
+}
+
+func TestSetLinkRouting(t *testing.T) {
+	ctx := context.Background()
+	store, _ := storage.NewMemoryStorage(ctx)
+	service := NewURLService(store)
+
+	shortID, err := service.ShortenURL(ctx, "https://routing.example.com", "user1")
+	require.NoError(t, err)
+
+	invalid := []*models.Routing{
+		{Rules: []models.RoutingRule{{URL: "https://example.com"}}},
+		{Rules: []models.RoutingRule{{Platform: "symbian", URL: "https://example.com"}}},
+		{Rules: []models.RoutingRule{{Referrer: "https://news.example.com/", URL: "https://example.com"}}},
+		{Rules: []models.RoutingRule{{Language: "ru", URL: "ftp://example.com"}}},
+		{Variants: []models.RoutingVariant{{Name: "a b", URL: "https://example.com", Weight: 1}}},
+		{Variants: []models.RoutingVariant{{Name: "a", URL: "https://example.com", Weight: MaxVariantWeight + 1}}},
+	}
+	for _, rules := range invalid {
+		assert.Equal(t, apperrors.ErrInvalidRouting, service.SetLinkRouting(ctx, shortID, "user1", rules), "Правила %+v", *rules)
+	}
+
+	err = service.SetLinkRouting(ctx, shortID, "user1", &models.Routing{
+		Rules: []models.RoutingRule{{Platform: " iOS ", Referrer: "News.Example.com", URL: "https://apps.example.com"}},
+	})
+	require.NoError(t, err)
+	url, _, err := service.GetLink(ctx, shortID)
+	require.NoError(t, err)
+	require.NotNil(t, url.Routing)
+	assert.Equal(t, []models.RoutingRule{{Platform: "ios", Referrer: "news.example.com", URL: "https://apps.example.com"}}, url.Routing.Rules)
+
+	assert.Equal(t, apperrors.ErrNoSuchURL, service.SetLinkRouting(ctx, shortID, "user2", nil))
+	require.NoError(t, service.SetLinkRouting(ctx, shortID, "user1", &models.Routing{}))
+	url, _, err = service.GetLink(ctx, shortID)
+	require.NoError(t, err)
+	assert.Nil(t, url.Routing, "Пустые правила удаляются")
+}
+
+func TestCreateLink_Alias(t *testing.T) {
+	ctx := context.Background()
+	store, _ := storage.NewMemoryStorage(ctx)
+	service := NewURLService(store)
+
+	t.Run("Свободный псевдоним", func(t *testing.T) {
+		shortID, err := service.CreateLink(ctx, "https://alias.example.com", "user1", LinkOptions{Alias: "my-link_1"})
+		require.NoError(t, err)
+		assert.Equal(t, "my-link_1", shortID)
+
+		longURL, _, err := service.GetOriginalURL(ctx, "my-link_1")
+		require.NoError(t, err)
+		assert.Equal(t, "https://alias.example.com", longURL)
+	})
+
+	t.Run("Занятый псевдоним", func(t *testing.T) {
+		_, err := service.CreateLink(ctx, "https://other.example.com", "user2", LinkOptions{Alias: "my-link_1"})
+		assert.Equal(t, apperrors.ErrAliasTaken, err)
+	})
+
+	t.Run("URL уже сокращен", func(t *testing.T) {
+		shortID, err := service.CreateLink(ctx, "https://alias.example.com", "user1", LinkOptions{Alias: "another"})
+		assert.Equal(t, apperrors.ErrURLExists, err)
+		assert.Equal(t, "my-link_1", shortID)
+	})
+
+	for _, alias := range []string{"ab", strings.Repeat("a", MaxAliasLength+1), "with space", "путь", "a/b", "API", "ping"} {
+		t.Run("Недопустимый псевдоним "+alias, func(t *testing.T) {
+			_, err := service.CreateLink(ctx, "https://invalid.example.com", "user1", LinkOptions{Alias: alias})
+			assert.Equal(t, apperrors.ErrInvalidAlias, err)
+		})
+	}
+}
@@ -0,0 +1,665 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Eorthus/shorturl/internal/models"
+)
+
+// Бакеты встроенного хранилища
+var (
+	// urlsBucket хранит записи URL по короткому идентификатору
+	urlsBucket = []byte("urls")
+	// longURLsBucket индексирует короткие идентификаторы по длинному URL
+	longURLsBucket = []byte("long_urls")
+	// usersBucket содержит вложенный бакет на каждого пользователя
+	// с короткими идентификаторами по ключу userIndexKey
+	usersBucket = []byte("users")
+)
+
+// BoltStorage реализует хранение URL во встроенной базе bbolt.
+//
+// Все данные лежат в одном файле, каждая операция выполняется
+// в транзакции, поэтому пакетное сохранение атомарно.
+type BoltStorage struct {
+	db *bolt.DB
+}
+
+// NewBoltStorage открывает или создает файл встроенной базы данных
+func NewBoltStorage(ctx context.Context, path string) (*BoltStorage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt database: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{urlsBucket, longURLsBucket, usersBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return migrateUserIndex(tx)
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create buckets: %w", err)
+	}
+
+	return &BoltStorage{db: db}, nil
+}
+
+// Close закрывает файл базы данных
+func (bs *BoltStorage) Close() error {
+	return bs.db.Close()
+}
+
+// SaveURL сохраняет новый URL
+func (bs *BoltStorage) SaveURL(ctx context.Context, shortID, longURL, userID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		if err := checkBoltConflict(tx, shortID, longURL); err != nil {
+			return err
+		}
+		return putBoltRecord(tx, newURLRecord(shortID, longURL, userID), true)
+	})
+}
+
+// GetURL возвращает оригинальный URL по короткому идентификатору
+func (bs *BoltStorage) GetURL(ctx context.Context, shortID string) (string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+
+	var record urlRecord
+	var found bool
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		var err error
+		record, found, err = getBoltRecord(tx, shortID)
+		return err
+	})
+	if err != nil || !found {
+		return "", false, err
+	}
+
+	return record.OriginalURL, record.IsDeleted, nil
+}
+
+// GetURLData возвращает данные URL по короткому идентификатору
+func (bs *BoltStorage) GetURLData(ctx context.Context, shortID string) (models.URLData, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return models.URLData{}, false, err
+	}
+
+	var record urlRecord
+	var found bool
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		var err error
+		record, found, err = getBoltRecord(tx, shortID)
+		return err
+	})
+	if err != nil || !found {
+		return models.URLData{}, false, err
+	}
+
+	return record.urlData(), true, nil
+}
+
+// Ping проверяет доступность базы данных
+func (bs *BoltStorage) Ping(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return bs.db.View(func(tx *bolt.Tx) error {
+		return nil
+	})
+}
+
+// SaveURLBatch сохраняем массив URL в одной транзакции
+func (bs *BoltStorage) SaveURLBatch(ctx context.Context, urls map[string]string, userID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		seen := make(map[string]bool, len(urls))
+		for shortID, longURL := range urls {
+			if seen[longURL] {
+				return ErrURLExists
+			}
+			seen[longURL] = true
+
+			if err := checkBoltConflict(tx, shortID, longURL); err != nil {
+				return err
+			}
+			if err := putBoltRecord(tx, newURLRecord(shortID, longURL, userID), true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetShortIDByLongURL вытягивает short_id URL по идентификатору
+func (bs *BoltStorage) GetShortIDByLongURL(ctx context.Context, longURL string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	var shortID string
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		shortID = string(tx.Bucket(longURLsBucket).Get([]byte(longURL)))
+		return nil
+	})
+	return shortID, err
+}
+
+// GetUserURLs отдает массив URL пользователя в порядке создания,
+// при равном времени — по короткому идентификатору, как в базе данных
+func (bs *BoltStorage) GetUserURLs(ctx context.Context, userID string) ([]models.URLData, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	urls := make([]models.URLData, 0)
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		userBucket := tx.Bucket(usersBucket).Bucket([]byte(userID))
+		if userBucket == nil {
+			return nil
+		}
+		return userBucket.ForEach(func(_, shortID []byte) error {
+			record, found, err := getBoltRecord(tx, string(shortID))
+			if err != nil {
+				return err
+			}
+			if found {
+				urls = append(urls, record.urlData())
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return urls, nil
+}
+
+// GetUserURLsPage отдает страницу URL пользователя
+func (bs *BoltStorage) GetUserURLsPage(ctx context.Context, userID string, query models.URLQuery) (models.URLPage, error) {
+	urls, err := bs.GetUserURLs(ctx, userID)
+	if err != nil {
+		return models.URLPage{}, err
+	}
+	return paginate(urls, query)
+}
+
+// MarkURLsAsDeleted помечает записи пользователя как удаленные
+func (bs *BoltStorage) MarkURLsAsDeleted(ctx context.Context, shortIDs []string, userID string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		for _, shortID := range shortIDs {
+			record, found, err := getBoltRecord(tx, shortID)
+			if err != nil {
+				return err
+			}
+			if !found || record.UserID != userID || record.IsDeleted {
+				continue
+			}
+			record.markDeleted(now)
+			if err := putBoltRecord(tx, record, false); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// RestoreURLs снимает пометку удаления с записей пользователя в одной транзакции
+func (bs *BoltStorage) RestoreURLs(ctx context.Context, shortIDs []string, userID string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var restored []string
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		restored = nil
+		for _, shortID := range shortIDs {
+			record, found, err := getBoltRecord(tx, shortID)
+			if err != nil {
+				return err
+			}
+			if !found || record.UserID != userID || !record.IsDeleted {
+				continue
+			}
+			record.restore()
+			if err := putBoltRecord(tx, record, false); err != nil {
+				return err
+			}
+			restored = append(restored, shortID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// ConsumeClick списывает переход в транзакции записи
+func (bs *BoltStorage) ConsumeClick(ctx context.Context, shortID string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	var consumed bool
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		record, found, err := getBoltRecord(tx, shortID)
+		if err != nil || !found || !record.consumeClick() {
+			return err
+		}
+		consumed = true
+		return putBoltRecord(tx, record, false)
+	})
+	return consumed && err == nil, err
+}
+
+// UpdateURL меняет адрес назначения и индекс длинных URL в одной транзакции
+func (bs *BoltStorage) UpdateURL(ctx context.Context, shortID, userID, longURL string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	var previous string
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		record, found, err := getBoltRecord(tx, shortID)
+		if err != nil {
+			return err
+		}
+		if !found || record.UserID != userID || record.IsDeleted {
+			return ErrURLNotFound
+		}
+		previous = record.OriginalURL
+		if longURL == previous {
+			return nil
+		}
+		longURLs := tx.Bucket(longURLsBucket)
+		if longURLs.Get([]byte(longURL)) != nil {
+			return ErrURLExists
+		}
+		if string(longURLs.Get([]byte(previous))) == shortID {
+			if err := longURLs.Delete([]byte(previous)); err != nil {
+				return err
+			}
+		}
+
+		record.changeDestination(longURL, time.Now().UTC())
+		return putBoltRecord(tx, record, false)
+	})
+	if err != nil {
+		return "", err
+	}
+	return previous, nil
+}
+
+// GetURLHistory возвращает версии адреса назначения URL пользователя
+func (bs *BoltStorage) GetURLHistory(ctx context.Context, shortID, userID string) ([]models.URLVersion, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var versions []models.URLVersion
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		record, found, err := getBoltRecord(tx, shortID)
+		if err != nil {
+			return err
+		}
+		if !found || record.UserID != userID {
+			return ErrURLNotFound
+		}
+		versions = urlHistory(record.CreatedAt, record.OriginalURL, record.History)
+		return nil
+	})
+	return versions, err
+}
+
+// SetURLLabels заменяет заголовок, метки и заметку в транзакции записи
+func (bs *BoltStorage) SetURLLabels(ctx context.Context, shortID, userID string, labels models.URLLabels) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		record, found, err := getBoltRecord(tx, shortID)
+		if err != nil {
+			return err
+		}
+		if !found || record.UserID != userID || record.IsDeleted {
+			return ErrURLNotFound
+		}
+		record.setLabels(labels)
+		return putBoltRecord(tx, record, false)
+	})
+}
+
+// SetURLRouting заменяет правила выбора адреса в транзакции записи
+func (bs *BoltStorage) SetURLRouting(ctx context.Context, shortID, userID string, routing *models.Routing) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		record, found, err := getBoltRecord(tx, shortID)
+		if err != nil {
+			return err
+		}
+		if !found || record.UserID != userID || record.IsDeleted {
+			return ErrURLNotFound
+		}
+		record.Routing = routing
+		return putBoltRecord(tx, record, false)
+	})
+}
+
+// GetUserTags считает метки URL пользователя
+func (bs *BoltStorage) GetUserTags(ctx context.Context, userID string) ([]models.TagCount, error) {
+	urls, err := bs.GetUserURLs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return countTags(urls), nil
+}
+
+// PurgeDeletedURLs окончательно удаляет до limit URL, удаленных или истекших раньше before,
+// вместе с записями индекса длинных URL и списков пользователей.
+// Кандидаты отбираются в транзакции чтения, поэтому транзакция записи
+// не обходит бакет и затрагивает только найденные ключи.
+func (bs *BoltStorage) PurgeDeletedURLs(ctx context.Context, before time.Time, limit int) ([]models.URLData, error) {
+	for {
+		purged, found, err := bs.purgeBatch(ctx, before, limit)
+		// Всех кандидатов восстановили или продлили после отбора, отбираем заново
+		if err != nil || len(purged) > 0 || !found {
+			return purged, err
+		}
+	}
+}
+
+// purgeBatch удаляет один пакет PurgeDeletedURLs и сообщает, были ли найдены кандидаты
+func (bs *BoltStorage) purgeBatch(ctx context.Context, before time.Time, limit int) ([]models.URLData, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	var candidates [][]byte
+	err := bs.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(urlsBucket).Cursor()
+		for shortID, data := cursor.First(); shortID != nil && len(candidates) < limit; shortID, data = cursor.Next() {
+			record, _, err := decodeURLRecord(data)
+			if err != nil {
+				return fmt.Errorf("failed to decode record %q: %w", shortID, err)
+			}
+			if record.purgeable(before) {
+				candidates = append(candidates, slices.Clone(shortID))
+			}
+		}
+		return nil
+	})
+	if err != nil || len(candidates) == 0 {
+		return nil, false, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, true, err
+	}
+
+	var purged []models.URLData
+	err = bs.db.Update(func(tx *bolt.Tx) error {
+		urls := tx.Bucket(urlsBucket)
+		for _, shortID := range candidates {
+			data := urls.Get(shortID)
+			if data == nil {
+				continue
+			}
+			record, _, err := decodeURLRecord(data)
+			if err != nil {
+				return fmt.Errorf("failed to decode record %q: %w", shortID, err)
+			}
+			// Запись могли восстановить или продлить после отбора
+			if !record.purgeable(before) {
+				continue
+			}
+			if err := deleteBoltRecord(tx, record); err != nil {
+				return err
+			}
+			purged = append(purged, record.urlData())
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, true, err
+	}
+
+	return purged, true, nil
+}
+
+// ReleaseURL окончательно удаляет неработающий URL в одной транзакции
+func (bs *BoltStorage) ReleaseURL(ctx context.Context, shortID string, now time.Time) (models.URLData, error) {
+	if err := ctx.Err(); err != nil {
+		return models.URLData{}, err
+	}
+
+	var released models.URLData
+	err := bs.db.Update(func(tx *bolt.Tx) error {
+		data := tx.Bucket(urlsBucket).Get([]byte(shortID))
+		if data == nil {
+			return ErrURLNotFound
+		}
+		record, _, err := decodeURLRecord(data)
+		if err != nil {
+			return fmt.Errorf("failed to decode record %q: %w", shortID, err)
+		}
+		released = record.urlData()
+		if !released.Defunct(now) {
+			return ErrURLExists
+		}
+		return deleteBoltRecord(tx, record)
+	})
+	if err != nil {
+		return models.URLData{}, err
+	}
+	return released, nil
+}
+
+// SaveURLData сохраняет URL с метаданными в одной транзакции.
+// URL пользователя упорядочиваются по времени создания.
+func (bs *BoltStorage) SaveURLData(ctx context.Context, urls []models.URLData) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return bs.db.Update(func(tx *bolt.Tx) error {
+		for _, url := range urls {
+			if err := checkBoltConflict(tx, url.ShortURL, url.OriginalURL); err != nil {
+				return err
+			}
+			if err := putBoltRecord(tx, newURLRecordFromData(url), true); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// IterateURLs обходит записи в одной транзакции чтения:
+// сначала URL каждого пользователя в порядке создания, затем URL без владельца
+func (bs *BoltStorage) IterateURLs(ctx context.Context, fn func(url models.URLData) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return bs.db.View(func(tx *bolt.Tx) error {
+		emit := func(record urlRecord) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return fn(record.urlData())
+		}
+
+		err := tx.Bucket(usersBucket).ForEachBucket(func(userID []byte) error {
+			return tx.Bucket(usersBucket).Bucket(userID).ForEach(func(_, shortID []byte) error {
+				record, found, err := getBoltRecord(tx, string(shortID))
+				if err != nil || !found {
+					return err
+				}
+				return emit(record)
+			})
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(urlsBucket).ForEach(func(shortID, data []byte) error {
+			record, _, err := decodeURLRecord(data)
+			if err != nil {
+				return fmt.Errorf("failed to decode record %q: %w", shortID, err)
+			}
+			if record.UserID != "" {
+				return nil
+			}
+			return emit(record)
+		})
+	})
+}
+
+// checkBoltConflict проверяет, что короткий и длинный URL еще не заняты
+func checkBoltConflict(tx *bolt.Tx, shortID, longURL string) error {
+	shortExists := tx.Bucket(urlsBucket).Get([]byte(shortID)) != nil
+	longExists := tx.Bucket(longURLsBucket).Get([]byte(longURL)) != nil
+	return conflictError(shortExists, longExists)
+}
+
+// getBoltRecord читает запись по короткому идентификатору
+func getBoltRecord(tx *bolt.Tx, shortID string) (urlRecord, bool, error) {
+	data := tx.Bucket(urlsBucket).Get([]byte(shortID))
+	if data == nil {
+		return urlRecord{}, false, nil
+	}
+	record, _, err := decodeURLRecord(data)
+	if err != nil {
+		return urlRecord{}, false, fmt.Errorf("failed to decode record %q: %w", shortID, err)
+	}
+	return record, true, nil
+}
+
+// deleteBoltRecord удаляет запись из всех бакетов
+func deleteBoltRecord(tx *bolt.Tx, record urlRecord) error {
+	if err := tx.Bucket(urlsBucket).Delete([]byte(record.ShortURL)); err != nil {
+		return err
+	}
+	longURLs := tx.Bucket(longURLsBucket)
+	if string(longURLs.Get([]byte(record.OriginalURL))) == record.ShortURL {
+		if err := longURLs.Delete([]byte(record.OriginalURL)); err != nil {
+			return err
+		}
+	}
+	if record.UserID == "" {
+		return nil
+	}
+
+	userBucket := tx.Bucket(usersBucket).Bucket([]byte(record.UserID))
+	if userBucket == nil {
+		return nil
+	}
+	return userBucket.Delete(userIndexKey(record))
+}
+
+// putBoltRecord сохраняет запись и обновляет индекс длинных URL.
+// Для новой записи isNew добавляет ее в список URL пользователя.
+func putBoltRecord(tx *bolt.Tx, record urlRecord, isNew bool) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(urlsBucket).Put([]byte(record.ShortURL), data); err != nil {
+		return err
+	}
+	if err := tx.Bucket(longURLsBucket).Put([]byte(record.OriginalURL), []byte(record.ShortURL)); err != nil {
+		return err
+	}
+	if !isNew || record.UserID == "" {
+		return nil
+	}
+
+	userBucket, err := tx.Bucket(usersBucket).CreateBucketIfNotExists([]byte(record.UserID))
+	if err != nil {
+		return err
+	}
+	return userBucket.Put(userIndexKey(record), []byte(record.ShortURL))
+}
+
+// userIndexKey ключ записи в бакете пользователя: время создания и короткий
+// идентификатор, поэтому обход бакета идет в порядке created_at, short_id,
+// а запись удаляется по ключу без обхода списка
+func userIndexKey(record urlRecord) []byte {
+	key := make([]byte, 12, 12+len(record.ShortURL))
+	// Смена знакового бита сохраняет порядок и для времени до 1970 года
+	binary.BigEndian.PutUint64(key, uint64(record.CreatedAt.Unix())^1<<63)
+	binary.BigEndian.PutUint32(key[8:], uint32(record.CreatedAt.Nanosecond()))
+	return append(key, record.ShortURL...)
+}
+
+// legacyUserKeyLen длина ключа прежнего формата бакета пользователя,
+// порядкового номера из NextSequence
+const legacyUserKeyLen = 8
+
+// migrateUserIndex переводит бакеты пользователей с порядковых номеров
+// на ключи userIndexKey. Ключи нового формата всегда длиннее прежних.
+func migrateUserIndex(tx *bolt.Tx) error {
+	users := tx.Bucket(usersBucket)
+	return users.ForEachBucket(func(userID []byte) error {
+		userBucket := users.Bucket(userID)
+		var legacy [][]byte
+		err := userBucket.ForEach(func(key, _ []byte) error {
+			if len(key) == legacyUserKeyLen {
+				legacy = append(legacy, slices.Clone(key))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range legacy {
+			shortID := string(userBucket.Get(key))
+			if err := userBucket.Delete(key); err != nil {
+				return err
+			}
+			record, found, err := getBoltRecord(tx, shortID)
+			if err != nil {
+				return err
+			}
+			if found {
+				if err := userBucket.Put(userIndexKey(record), []byte(shortID)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
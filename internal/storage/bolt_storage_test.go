@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Eorthus/shorturl/internal/models"
+)
+
+func TestBoltStorage(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.db")
+
+	store, err := NewBoltStorage(ctx, path)
+	require.NoError(t, err)
+
+	require.NoError(t, store.SaveURL(ctx, "bolt1", "https://bolt1.com", "user1"))
+	require.NoError(t, store.SaveURLBatch(ctx, map[string]string{
+		"bolt2": "https://bolt2.com",
+		"bolt3": "https://bolt3.com",
+	}, "user1"))
+	require.NoError(t, store.MarkURLsAsDeleted(ctx, []string{"bolt2"}, "user1"))
+	require.NoError(t, store.Close())
+
+	t.Run("Персистентность данных", func(t *testing.T) {
+		reopened, err := NewBoltStorage(ctx, path)
+		require.NoError(t, err)
+		defer reopened.Close()
+
+		resultURL, isDeleted, err := reopened.GetURL(ctx, "bolt1")
+		assert.NoError(t, err)
+		assert.False(t, isDeleted)
+		assert.Equal(t, "https://bolt1.com", resultURL)
+
+		_, isDeleted, err = reopened.GetURL(ctx, "bolt2")
+		assert.NoError(t, err)
+		assert.True(t, isDeleted)
+
+		shortID, err := reopened.GetShortIDByLongURL(ctx, "https://bolt3.com")
+		assert.NoError(t, err)
+		assert.Equal(t, "bolt3", shortID)
+
+		userURLs, err := reopened.GetUserURLs(ctx, "user1")
+		assert.NoError(t, err)
+		assert.Len(t, userURLs, 3)
+		assert.Equal(t, "bolt1", userURLs[0].ShortURL)
+	})
+}
+
+func TestBoltStorage_UserIndex(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "urls.db")
+	early := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	store, err := NewBoltStorage(ctx, path)
+	require.NoError(t, err)
+	require.NoError(t, store.SaveURLData(ctx, []models.URLData{
+		{ShortURL: "late", OriginalURL: "https://late.com", UserID: "user1", CreatedAt: early.Add(time.Hour)},
+		{ShortURL: "early", OriginalURL: "https://early.com", UserID: "user1", CreatedAt: early},
+		{ShortURL: "same", OriginalURL: "https://same.com", UserID: "user1", CreatedAt: early},
+	}))
+
+	// Переводим индекс в прежний формат с порядковыми номерами в порядке вставки
+	require.NoError(t, store.db.Update(func(tx *bolt.Tx) error {
+		users := tx.Bucket(usersBucket)
+		require.NoError(t, users.DeleteBucket([]byte("user1")))
+		userBucket, err := users.CreateBucket([]byte("user1"))
+		require.NoError(t, err)
+		for _, shortID := range []string{"late", "early", "same"} {
+			seq, err := userBucket.NextSequence()
+			require.NoError(t, err)
+			key := make([]byte, legacyUserKeyLen)
+			binary.BigEndian.PutUint64(key, seq)
+			require.NoError(t, userBucket.Put(key, []byte(shortID)))
+		}
+		return nil
+	}))
+	require.NoError(t, store.Close())
+
+	reopened, err := NewBoltStorage(ctx, path)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	shortIDs := func() []string {
+		urls, err := reopened.GetUserURLs(ctx, "user1")
+		require.NoError(t, err)
+		ids := make([]string, 0, len(urls))
+		for _, url := range urls {
+			ids = append(ids, url.ShortURL)
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"early", "same", "late"}, shortIDs(), "Порядок по времени создания и идентификатору")
+
+	require.NoError(t, reopened.MarkURLsAsDeleted(ctx, []string{"same"}, "user1"))
+	purged, err := reopened.PurgeDeletedURLs(ctx, time.Now(), 10)
+	require.NoError(t, err)
+	require.Len(t, purged, 1)
+	assert.Equal(t, []string{"early", "late"}, shortIDs(), "Перенесенная запись удаляется по ключу")
+}
@@ -0,0 +1,361 @@
+package storage
+
+import (
+	"container/list"
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Eorthus/shorturl/internal/models"
+)
+
+// CacheStats содержит счетчики кэша хранилища
+type CacheStats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+	Size   int   `json:"size"`
+}
+
+// cachedURL значение кэша GetURLData
+type cachedURL struct {
+	url   models.URLData
+	found bool
+}
+
+// CachedStorage оборачивает хранилище ограниченным LRU-кэшем с TTL
+// для GetURL, GetURLData и GetShortIDByLongURL.
+//
+// Кэшируются и отрицательные ответы. Изменяющие операции сбрасывают
+// затронутые ключи. Изменения, сделанные в обход декоратора (например,
+// другим экземпляром сервиса), становятся видны не позже чем через TTL.
+type CachedStorage struct {
+	Storage
+	urls     *lruCache[cachedURL]
+	shortIDs *lruCache[string]
+	hits     atomic.Int64
+	misses   atomic.Int64
+}
+
+// NewCachedStorage создает декоратор с кэшами на size записей каждый
+func NewCachedStorage(store Storage, size int, ttl time.Duration) *CachedStorage {
+	return &CachedStorage{
+		Storage:  store,
+		urls:     newLRUCache[cachedURL](size, ttl),
+		shortIDs: newLRUCache[string](size, ttl),
+	}
+}
+
+// Stats возвращает текущие счетчики попаданий и промахов
+func (cs *CachedStorage) Stats() CacheStats {
+	return CacheStats{
+		Hits:   cs.hits.Load(),
+		Misses: cs.misses.Load(),
+		Size:   cs.urls.Len() + cs.shortIDs.Len(),
+	}
+}
+
+// Close закрывает обернутое хранилище, если оно держит ресурсы
+func (cs *CachedStorage) Close() error {
+	if closer, ok := cs.Storage.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// GetURL возвращает URL из кэша или из хранилища
+func (cs *CachedStorage) GetURL(ctx context.Context, shortID string) (string, bool, error) {
+	url, _, err := cs.GetURLData(ctx, shortID)
+	return url.OriginalURL, url.IsDeleted, err
+}
+
+// GetURLData возвращает данные URL из кэша или из хранилища.
+// Срок действия проверяет вызывающий, поэтому кэшированная запись
+// не продлевает жизнь истекшей ссылки.
+func (cs *CachedStorage) GetURLData(ctx context.Context, shortID string) (models.URLData, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return models.URLData{}, false, err
+	}
+
+	if entry, ok := cs.urls.Get(shortID); ok {
+		cs.hits.Add(1)
+		return entry.url, entry.found, nil
+	}
+	cs.misses.Add(1)
+
+	gen := cs.urls.Generation()
+	url, found, err := cs.Storage.GetURLData(ctx, shortID)
+	if err != nil {
+		return models.URLData{}, false, err
+	}
+	cs.urls.Add(shortID, cachedURL{url: url, found: found}, gen)
+
+	return url, found, nil
+}
+
+// GetShortIDByLongURL возвращает короткий идентификатор из кэша или из хранилища
+func (cs *CachedStorage) GetShortIDByLongURL(ctx context.Context, longURL string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	if shortID, ok := cs.shortIDs.Get(longURL); ok {
+		cs.hits.Add(1)
+		return shortID, nil
+	}
+	cs.misses.Add(1)
+
+	gen := cs.shortIDs.Generation()
+	shortID, err := cs.Storage.GetShortIDByLongURL(ctx, longURL)
+	if err != nil {
+		return "", err
+	}
+	cs.shortIDs.Add(longURL, shortID, gen)
+
+	return shortID, nil
+}
+
+// SaveURL сохраняет URL и сбрасывает отрицательные записи кэша
+func (cs *CachedStorage) SaveURL(ctx context.Context, shortID, longURL, userID string) error {
+	defer cs.urls.Remove(shortID)
+	defer cs.shortIDs.Remove(longURL)
+
+	return cs.Storage.SaveURL(ctx, shortID, longURL, userID)
+}
+
+// SaveURLBatch сохраняет пакет URL и сбрасывает затронутые записи кэша
+func (cs *CachedStorage) SaveURLBatch(ctx context.Context, urls map[string]string, userID string) error {
+	defer func() {
+		for shortID, longURL := range urls {
+			cs.urls.Remove(shortID)
+			cs.shortIDs.Remove(longURL)
+		}
+	}()
+
+	return cs.Storage.SaveURLBatch(ctx, urls, userID)
+}
+
+// SaveURLData сохраняет URL с метаданными и сбрасывает затронутые записи кэша
+func (cs *CachedStorage) SaveURLData(ctx context.Context, urls []models.URLData) error {
+	defer func() {
+		for _, url := range urls {
+			cs.urls.Remove(url.ShortURL)
+			cs.shortIDs.Remove(url.OriginalURL)
+		}
+	}()
+
+	return cs.Storage.SaveURLData(ctx, urls)
+}
+
+// MarkURLsAsDeleted помечает URL удаленными и сбрасывает их из кэша
+func (cs *CachedStorage) MarkURLsAsDeleted(ctx context.Context, shortIDs []string, userID string) error {
+	defer func() {
+		for _, shortID := range shortIDs {
+			cs.urls.Remove(shortID)
+		}
+	}()
+
+	return cs.Storage.MarkURLsAsDeleted(ctx, shortIDs, userID)
+}
+
+// RestoreURLs восстанавливает удаленные URL и сбрасывает их из кэша
+func (cs *CachedStorage) RestoreURLs(ctx context.Context, shortIDs []string, userID string) ([]string, error) {
+	defer func() {
+		for _, shortID := range shortIDs {
+			cs.urls.Remove(shortID)
+		}
+	}()
+
+	return cs.Storage.RestoreURLs(ctx, shortIDs, userID)
+}
+
+// UpdateURL меняет адрес назначения и сбрасывает URL и оба длинных адреса из кэша
+func (cs *CachedStorage) UpdateURL(ctx context.Context, shortID, userID, longURL string) (string, error) {
+	defer cs.urls.Remove(shortID)
+	defer cs.shortIDs.Remove(longURL)
+
+	previous, err := cs.Storage.UpdateURL(ctx, shortID, userID, longURL)
+	if err == nil {
+		cs.shortIDs.Remove(previous)
+	}
+	return previous, err
+}
+
+// SetURLLabels меняет заголовок, метки и заметку и сбрасывает URL из кэша
+func (cs *CachedStorage) SetURLLabels(ctx context.Context, shortID, userID string, labels models.URLLabels) error {
+	defer cs.urls.Remove(shortID)
+
+	return cs.Storage.SetURLLabels(ctx, shortID, userID, labels)
+}
+
+// SetURLRouting меняет правила выбора адреса и сбрасывает URL из кэша
+func (cs *CachedStorage) SetURLRouting(ctx context.Context, shortID, userID string, routing *models.Routing) error {
+	defer cs.urls.Remove(shortID)
+
+	return cs.Storage.SetURLRouting(ctx, shortID, userID, routing)
+}
+
+// ConsumeClick списывает переход и сбрасывает URL из кэша
+func (cs *CachedStorage) ConsumeClick(ctx context.Context, shortID string) (bool, error) {
+	defer cs.urls.Remove(shortID)
+
+	return cs.Storage.ConsumeClick(ctx, shortID)
+}
+
+// PurgeDeletedURLs окончательно удаляет URL и сбрасывает их из кэша
+func (cs *CachedStorage) PurgeDeletedURLs(ctx context.Context, before time.Time, limit int) ([]models.URLData, error) {
+	purged, err := cs.Storage.PurgeDeletedURLs(ctx, before, limit)
+	for _, url := range purged {
+		cs.urls.Remove(url.ShortURL)
+		cs.shortIDs.Remove(url.OriginalURL)
+	}
+	return purged, err
+}
+
+// ReleaseURL окончательно удаляет неработающий URL и сбрасывает его из кэша
+func (cs *CachedStorage) ReleaseURL(ctx context.Context, shortID string, now time.Time) (models.URLData, error) {
+	released, err := cs.Storage.ReleaseURL(ctx, shortID, now)
+	if err == nil {
+		cs.urls.Remove(shortID)
+		cs.shortIDs.Remove(released.OriginalURL)
+	}
+	return released, err
+}
+
+// lruCache потокобезопасный LRU-кэш с ограничением по времени жизни записей.
+//
+// Поколения защищают от гонки чтения и инвалидации: значение, прочитанное
+// из хранилища до сброса ключа, не попадает в кэш после него. Сброс
+// запоминает поколение ключа в надгробии, поэтому сброс одного ключа не
+// мешает кэшировать остальные. Надгробий не больше capacity; поколение
+// вытесненного надгробия становится нижней границей для всех ключей.
+type lruCache[V any] struct {
+	mutex    sync.Mutex
+	capacity int
+	ttl      time.Duration
+	items    map[string]*list.Element
+	order    *list.List
+	gen      uint64
+	now      func() time.Time
+
+	removed    map[string]*list.Element
+	tombstones *list.List
+	// floor поколение последнего вытесненного надгробия
+	floor uint64
+}
+
+type lruEntry[V any] struct {
+	key       string
+	value     V
+	expiresAt time.Time
+}
+
+// lruTombstone поколение последнего сброса ключа
+type lruTombstone struct {
+	key string
+	gen uint64
+}
+
+func newLRUCache[V any](capacity int, ttl time.Duration) *lruCache[V] {
+	return &lruCache[V]{
+		capacity:   capacity,
+		ttl:        ttl,
+		items:      make(map[string]*list.Element, capacity),
+		order:      list.New(),
+		now:        time.Now,
+		removed:    make(map[string]*list.Element),
+		tombstones: list.New(),
+	}
+}
+
+// Get возвращает живое значение и поднимает его в начало очереди
+func (c *lruCache[V]) Get(key string) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var zero V
+	elem, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+	entry := elem.Value.(*lruEntry[V])
+	if c.ttl > 0 && c.now().After(entry.expiresAt) {
+		c.order.Remove(elem)
+		delete(c.items, key)
+		return zero, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// Generation возвращает текущее поколение кэша; его нужно взять до чтения из хранилища
+func (c *lruCache[V]) Generation() uint64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.gen
+}
+
+// Add сохраняет значение, если ключ не сбрасывался после поколения gen
+func (c *lruCache[V]) Add(key string, value V, gen uint64) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.capacity <= 0 || gen < c.floor {
+		return
+	}
+	if elem, ok := c.removed[key]; ok && elem.Value.(*lruTombstone).gen > gen {
+		return
+	}
+
+	expiresAt := c.now().Add(c.ttl)
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*lruEntry[V])
+		entry.value = value
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&lruEntry[V]{key: key, value: value, expiresAt: expiresAt})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruEntry[V]).key)
+	}
+}
+
+// Remove удаляет ключ и запоминает поколение его сброса
+func (c *lruCache[V]) Remove(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.capacity <= 0 {
+		return
+	}
+	if elem, ok := c.items[key]; ok {
+		c.order.Remove(elem)
+		delete(c.items, key)
+	}
+
+	c.gen++
+	if elem, ok := c.removed[key]; ok {
+		elem.Value.(*lruTombstone).gen = c.gen
+		c.tombstones.MoveToBack(elem)
+		return
+	}
+	c.removed[key] = c.tombstones.PushBack(&lruTombstone{key: key, gen: c.gen})
+	if c.tombstones.Len() > c.capacity {
+		// Надгробия упорядочены по поколению, первое самое старое
+		oldest := c.tombstones.Remove(c.tombstones.Front()).(*lruTombstone)
+		delete(c.removed, oldest.key)
+		c.floor = oldest.gen
+	}
+}
+
+// Len возвращает количество записей в кэше
+func (c *lruCache[V]) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
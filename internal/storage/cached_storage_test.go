@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Eorthus/shorturl/internal/models"
+)
+
+// countingStorage считает обращения к обернутому хранилищу
+type countingStorage struct {
+	*MemoryStorage
+	getURLCalls     int
+	getShortIDCalls int
+}
+
+func (cs *countingStorage) GetURLData(ctx context.Context, shortID string) (models.URLData, bool, error) {
+	cs.getURLCalls++
+	return cs.MemoryStorage.GetURLData(ctx, shortID)
+}
+
+func (cs *countingStorage) GetShortIDByLongURL(ctx context.Context, longURL string) (string, error) {
+	cs.getShortIDCalls++
+	return cs.MemoryStorage.GetShortIDByLongURL(ctx, longURL)
+}
+
+func newCountingStorage(t *testing.T) *countingStorage {
+	memory, err := NewMemoryStorage(context.Background())
+	require.NoError(t, err)
+	return &countingStorage{MemoryStorage: memory}
+}
+
+func TestCachedStorage(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Повторное чтение из кэша", func(t *testing.T) {
+		backend := newCountingStorage(t)
+		cached := NewCachedStorage(backend, 10, time.Minute)
+		require.NoError(t, cached.SaveURL(ctx, "abc", "https://example.com", "user1"))
+
+		for range 3 {
+			longURL, isDeleted, err := cached.GetURL(ctx, "abc")
+			assert.NoError(t, err)
+			assert.False(t, isDeleted)
+			assert.Equal(t, "https://example.com", longURL)
+		}
+		assert.Equal(t, 1, backend.getURLCalls)
+		assert.Equal(t, CacheStats{Hits: 2, Misses: 1, Size: 1}, cached.Stats())
+	})
+
+	t.Run("Отрицательные ответы", func(t *testing.T) {
+		backend := newCountingStorage(t)
+		cached := NewCachedStorage(backend, 10, time.Minute)
+
+		for range 2 {
+			longURL, _, err := cached.GetURL(ctx, "missing")
+			assert.NoError(t, err)
+			assert.Empty(t, longURL)
+
+			shortID, err := cached.GetShortIDByLongURL(ctx, "https://missing.com")
+			assert.NoError(t, err)
+			assert.Empty(t, shortID)
+		}
+		assert.Equal(t, 1, backend.getURLCalls)
+		assert.Equal(t, 1, backend.getShortIDCalls)
+
+		require.NoError(t, cached.SaveURL(ctx, "missing", "https://missing.com", "user1"))
+
+		longURL, _, err := cached.GetURL(ctx, "missing")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://missing.com", longURL, "Сохранение сбрасывает отрицательный ответ")
+
+		shortID, err := cached.GetShortIDByLongURL(ctx, "https://missing.com")
+		assert.NoError(t, err)
+		assert.Equal(t, "missing", shortID)
+	})
+
+	t.Run("Инвалидация при удалении", func(t *testing.T) {
+		cached := NewCachedStorage(newCountingStorage(t), 10, time.Minute)
+		require.NoError(t, cached.SaveURL(ctx, "del", "https://del.com", "user1"))
+
+		_, isDeleted, err := cached.GetURL(ctx, "del")
+		require.NoError(t, err)
+		require.False(t, isDeleted)
+
+		require.NoError(t, cached.MarkURLsAsDeleted(ctx, []string{"del"}, "user1"))
+
+		_, isDeleted, err = cached.GetURL(ctx, "del")
+		assert.NoError(t, err)
+		assert.True(t, isDeleted)
+	})
+
+	t.Run("Истечение TTL", func(t *testing.T) {
+		backend := newCountingStorage(t)
+		cached := NewCachedStorage(backend, 10, time.Minute)
+		now := time.Now()
+		cached.urls.now = func() time.Time { return now }
+
+		_, _, err := cached.GetURL(ctx, "ttl")
+		require.NoError(t, err)
+		now = now.Add(2 * time.Minute)
+		_, _, err = cached.GetURL(ctx, "ttl")
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, backend.getURLCalls)
+	})
+}
+
+func TestLRUCache(t *testing.T) {
+	t.Run("Вытеснение давно неиспользуемых записей", func(t *testing.T) {
+		cache := newLRUCache[string](2, time.Minute)
+		cache.Add("a", "1", cache.Generation())
+		cache.Add("b", "2", cache.Generation())
+		_, _ = cache.Get("a")
+		cache.Add("c", "3", cache.Generation())
+
+		_, ok := cache.Get("b")
+		assert.False(t, ok)
+		_, ok = cache.Get("a")
+		assert.True(t, ok)
+		_, ok = cache.Get("c")
+		assert.True(t, ok)
+		assert.Equal(t, 2, cache.Len())
+	})
+
+	t.Run("Устаревшее поколение не записывается", func(t *testing.T) {
+		cache := newLRUCache[string](2, time.Minute)
+		gen := cache.Generation()
+		cache.Remove("a")
+		cache.Add("a", "stale", gen)
+
+		_, ok := cache.Get("a")
+		assert.False(t, ok)
+	})
+
+	t.Run("Сброс другого ключа не мешает записи", func(t *testing.T) {
+		cache := newLRUCache[string](2, time.Minute)
+		gen := cache.Generation()
+		cache.Remove("b")
+		cache.Add("a", "1", gen)
+		value, ok := cache.Get("a")
+		assert.True(t, ok)
+		assert.Equal(t, "1", value)
+
+		// Чтение, начатое после сброса, снова кэшируется
+		cache.Add("b", "2", cache.Generation())
+		_, ok = cache.Get("b")
+		assert.True(t, ok)
+		// Более старое чтение не перезаписывает его
+		cache.Add("b", "stale", gen)
+		value, _ = cache.Get("b")
+		assert.Equal(t, "2", value)
+	})
+
+	t.Run("Вытесненное надгробие", func(t *testing.T) {
+		cache := newLRUCache[string](2, time.Minute)
+		gen := cache.Generation()
+		cache.Remove("a")
+		cache.Remove("b")
+		cache.Remove("c")
+		cache.Add("a", "stale", gen)
+
+		_, ok := cache.Get("a")
+		assert.False(t, ok, "Без надгробия устаревшее чтение отклоняется по нижней границе")
+		assert.Equal(t, 2, cache.tombstones.Len())
+	})
+}
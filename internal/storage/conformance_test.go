@@ -0,0 +1,72 @@
+package storage_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/Eorthus/shorturl/internal/storage"
+	"github.com/Eorthus/shorturl/internal/storage/storagetest"
+)
+
+func TestMemoryStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		store, err := storage.NewMemoryStorage(context.Background())
+		require.NoError(t, err)
+		return store
+	})
+}
+
+func TestCachedStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		store, err := storage.NewMemoryStorage(context.Background())
+		require.NoError(t, err)
+		return storage.NewCachedStorage(store, 100, time.Minute)
+	})
+}
+
+func TestFileStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		store, err := storage.NewFileStorage(context.Background(), filepath.Join(t.TempDir(), "urls.json"))
+		require.NoError(t, err)
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
+
+func TestBoltStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		store, err := storage.NewBoltStorage(context.Background(), filepath.Join(t.TempDir(), "urls.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { store.Close() })
+		return store
+	})
+}
+
+// TestDatabaseStorage_Conformance запускается только при заданной TEST_DATABASE_DSN,
+// таблица urls очищается перед каждым подтестом
+func TestDatabaseStorage_Conformance(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		ctx := context.Background()
+		store, err := storage.NewDatabaseStorage(ctx, dsn)
+		require.NoError(t, err)
+		t.Cleanup(func() { store.Close() })
+
+		db, err := storage.OpenDatabase(ctx, dsn)
+		require.NoError(t, err)
+		defer db.Close()
+		_, err = db.ExecContext(ctx, "TRUNCATE urls")
+		require.NoError(t, err)
+
+		return store
+	})
+}
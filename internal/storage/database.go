@@ -3,8 +3,12 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/jackc/pgerrcode"
 	"github.com/lib/pq"
@@ -17,43 +21,39 @@ type DatabaseStorage struct {
 	db *sql.DB
 }
 
-// ErrURLExists возникает при попытке сохранить существующий URL
-var ErrURLExists = errors.New("URL already exists")
-
-// NewDatabaseStorage создает новое хранилище в базе данных
+// NewDatabaseStorage создает новое хранилище в базе данных и применяет миграции схемы
 func NewDatabaseStorage(ctx context.Context, dsn string) (*DatabaseStorage, error) {
-	db, err := sql.Open("postgres", dsn)
+	db, err := OpenDatabase(ctx, dsn)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
 
-	if err := db.PingContext(ctx); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		db.Close()
+		return nil, err
 	}
-
-	storage := &DatabaseStorage{db: db}
-	if err := storage.createTable(ctx); err != nil {
-		return nil, fmt.Errorf("failed to create table: %w", err)
+	if _, err := migrator.Up(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
-	return storage, nil
+	return &DatabaseStorage{db: db}, nil
 }
 
-func (s *DatabaseStorage) createTable(ctx context.Context) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS urls (
-		id SERIAL PRIMARY KEY,
-		short_id VARCHAR(10) UNIQUE NOT NULL,
-		user_id TEXT,
-		original_url TEXT NOT NULL,
-		is_deleted BOOLEAN DEFAULT FALSE,
-		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-	);
-	CREATE UNIQUE INDEX IF NOT EXISTS idx_original_url ON urls(original_url);
-	`
+// OpenDatabase открывает подключение к PostgreSQL и проверяет его доступность
+func OpenDatabase(ctx context.Context, dsn string) (*sql.DB, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
 
-	_, err := s.db.ExecContext(ctx, query)
-	return err
+	return db, nil
 }
 
 // Close закрывает соединение с базой данных
@@ -65,19 +65,30 @@ func (s *DatabaseStorage) Close() error {
 func (s *DatabaseStorage) SaveURL(ctx context.Context, shortID, longURL string, userID string) error {
 	_, err := s.db.ExecContext(ctx, "INSERT INTO urls (short_id, original_url, user_id) VALUES ($1, $2, $3)", shortID, longURL, userID)
 	if err != nil {
-		pqErr, ok := err.(*pq.Error)
-		if ok && pqErr.Code == pgerrcode.UniqueViolation {
-			// Если URL уже существует, вернем существующий shortID
-			existingShortID, err := s.GetShortIDByLongURL(ctx, longURL)
-			if err == nil && existingShortID != "" {
-				return nil // URL уже существует, но это не ошибка
-			}
+		if conflict := uniqueViolation(err); conflict != nil {
+			return conflict
 		}
 		return fmt.Errorf("failed to save URL: %w", err)
 	}
 	return nil
 }
 
+// shortIDConstraint ограничение уникальности колонки short_id
+const shortIDConstraint = "urls_short_id_key"
+
+// uniqueViolation переводит нарушение ограничения уникальности в ошибку конфликта
+// или возвращает nil для прочих ошибок
+func uniqueViolation(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != pgerrcode.UniqueViolation {
+		return nil
+	}
+	if pqErr.Constraint == shortIDConstraint {
+		return ErrShortIDExists
+	}
+	return ErrURLExists
+}
+
 // GetURL возвращает оригинальный URL по короткому идентификатору
 func (s *DatabaseStorage) GetURL(ctx context.Context, shortID string) (string, bool, error) {
 
@@ -95,6 +106,19 @@ func (s *DatabaseStorage) GetURL(ctx context.Context, shortID string) (string, b
 	return longURL, isDeleted, nil
 }
 
+// GetURLData возвращает данные URL по короткому идентификатору
+func (s *DatabaseStorage) GetURLData(ctx context.Context, shortID string) (models.URLData, bool, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT "+urlDataColumns+" FROM urls WHERE short_id = $1", shortID)
+	url, err := scanURLData(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.URLData{}, false, nil
+	}
+	if err != nil {
+		return models.URLData{}, false, err
+	}
+	return url, true, nil
+}
+
 // Ping пингует db
 func (s *DatabaseStorage) Ping(ctx context.Context) error {
 	return s.db.PingContext(ctx)
@@ -114,9 +138,19 @@ func (s *DatabaseStorage) SaveURLBatch(ctx context.Context, urls map[string]stri
 	}
 	defer stmt.Close()
 
-	for shortID, longURL := range urls {
-		_, err = stmt.ExecContext(ctx, shortID, longURL, userID)
+	// Вставляем в детерминированном порядке, чтобы параллельные пакеты не взаимоблокировались
+	shortIDs := make([]string, 0, len(urls))
+	for shortID := range urls {
+		shortIDs = append(shortIDs, shortID)
+	}
+	slices.Sort(shortIDs)
+
+	for _, shortID := range shortIDs {
+		_, err = stmt.ExecContext(ctx, shortID, urls[shortID], userID)
 		if err != nil {
+			if conflict := uniqueViolation(err); conflict != nil {
+				return conflict
+			}
 			return fmt.Errorf("failed to execute statement: %w", err)
 		}
 	}
@@ -137,9 +171,9 @@ func (s *DatabaseStorage) GetShortIDByLongURL(ctx context.Context, longURL strin
 	return shortID, nil
 }
 
-// GetUserURLs отдает массив URL пользователя
+// GetUserURLs отдает массив URL пользователя с метаданными
 func (s *DatabaseStorage) GetUserURLs(ctx context.Context, userID string) ([]models.URLData, error) {
-	rows, err := s.db.QueryContext(ctx, "SELECT short_id, original_url FROM urls WHERE user_id = $1", userID)
+	rows, err := s.db.QueryContext(ctx, "SELECT "+urlDataColumns+" FROM urls WHERE user_id = $1 ORDER BY created_at, short_id", userID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query user URLs: %w", err)
 	}
@@ -147,9 +181,9 @@ func (s *DatabaseStorage) GetUserURLs(ctx context.Context, userID string) ([]mod
 
 	var urls []models.URLData
 	for rows.Next() {
-		var url models.URLData
-		if err := rows.Scan(&url.ShortURL, &url.OriginalURL); err != nil {
-			return nil, fmt.Errorf("failed to scan URL data: %w", err)
+		url, err := scanURLData(rows)
+		if err != nil {
+			return nil, err
 		}
 		urls = append(urls, url)
 	}
@@ -160,11 +194,224 @@ func (s *DatabaseStorage) GetUserURLs(ctx context.Context, userID string) ([]mod
 	return urls, nil
 }
 
+// SaveURLData сохраняет URL с метаданными в одной транзакции.
+// Порядок URL пользователя совпадает с порядком в urls.
+func (s *DatabaseStorage) SaveURLData(ctx context.Context, urls []models.URLData) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO urls (short_id, original_url, user_id, is_deleted, created_at, deleted_at, expires_at, clicks_left, password_hash, redirect_code, passthrough, tags, note, routing, title, not_before)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC()
+	for _, url := range urls {
+		createdAt := url.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = now
+		}
+		// Без времени удаления удаленный URL никогда не попадет под окончательное удаление
+		deletedAt := url.DeletedAt
+		if url.IsDeleted && deletedAt == nil {
+			deletedAt = &createdAt
+		}
+		passwordHash := sql.NullString{String: url.PasswordHash, Valid: url.PasswordHash != ""}
+		redirectCode := sql.NullInt32{Int32: int32(url.RedirectCode), Valid: url.RedirectCode != 0}
+		note := sql.NullString{String: url.Note, Valid: url.Note != ""}
+		routing, err := routingJSON(url.Routing)
+		if err != nil {
+			return err
+		}
+		_, err = stmt.ExecContext(ctx, url.ShortURL, url.OriginalURL, url.UserID, url.IsDeleted, createdAt, deletedAt,
+			url.ExpiresAt, url.ClicksLeft, passwordHash, redirectCode, url.Passthrough, tagsArray(url.Tags), note, routing,
+			sql.NullString{String: url.Title, Valid: url.Title != ""}, url.NotBefore)
+		if err != nil {
+			if conflict := uniqueViolation(err); conflict != nil {
+				return conflict
+			}
+			return fmt.Errorf("failed to execute statement: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// IterateURLs обходит записи в порядке вставки
+func (s *DatabaseStorage) IterateURLs(ctx context.Context, fn func(url models.URLData) error) error {
+	rows, err := s.db.QueryContext(ctx, "SELECT "+urlDataColumns+" FROM urls ORDER BY id")
+	if err != nil {
+		return fmt.Errorf("failed to query URLs: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		url, err := scanURLData(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(url); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating URL rows: %w", err)
+	}
+
+	return nil
+}
+
+// urlDataColumns колонки, которые читает scanURLData
+const urlDataColumns = "short_id, original_url, COALESCE(user_id, ''), is_deleted, created_at, deleted_at, expires_at, clicks_left, COALESCE(password_hash, ''), COALESCE(redirect_code, 0), passthrough, tags, COALESCE(note, ''), routing, COALESCE(title, ''), not_before"
+
+// rowScanner общий интерфейс sql.Row и sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanURLData читает строку с колонками urlDataColumns.
+// sql.ErrNoRows возвращается без обертки.
+func scanURLData(row rowScanner) (models.URLData, error) {
+	var url models.URLData
+	var deletedAt, expiresAt, notBefore sql.NullTime
+	var clicksLeft sql.NullInt64
+	var tags pq.StringArray
+	var routing []byte
+	err := row.Scan(&url.ShortURL, &url.OriginalURL, &url.UserID, &url.IsDeleted, &url.CreatedAt, &deletedAt, &expiresAt, &clicksLeft,
+		&url.PasswordHash, &url.RedirectCode, &url.Passthrough, &tags, &url.Note, &routing, &url.Title, &notBefore)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.URLData{}, err
+	}
+	if err != nil {
+		return models.URLData{}, fmt.Errorf("failed to scan URL data: %w", err)
+	}
+	if deletedAt.Valid {
+		url.DeletedAt = &deletedAt.Time
+	}
+	if expiresAt.Valid {
+		url.ExpiresAt = &expiresAt.Time
+	}
+	if notBefore.Valid {
+		url.NotBefore = &notBefore.Time
+	}
+	if clicksLeft.Valid {
+		left := int(clicksLeft.Int64)
+		url.ClicksLeft = &left
+	}
+	if len(tags) > 0 {
+		url.Tags = tags
+	}
+	if routing != nil {
+		url.Routing = &models.Routing{}
+		if err := json.Unmarshal(routing, url.Routing); err != nil {
+			return models.URLData{}, fmt.Errorf("failed to decode URL routing: %w", err)
+		}
+	}
+	return url, nil
+}
+
+// routingJSON сериализует правила выбора адреса для колонки routing, nil дает NULL
+func routingJSON(routing *models.Routing) (any, error) {
+	if routing == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(routing)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode URL routing: %w", err)
+	}
+	return string(data), nil
+}
+
+// tagsArray преобразует метки в массив PostgreSQL; колонка tags не допускает NULL
+func tagsArray(tags []string) pq.StringArray {
+	if tags == nil {
+		return pq.StringArray{}
+	}
+	return tags
+}
+
+// GetUserURLsPage отдает страницу URL пользователя, используя курсор по (created_at, short_id)
+func (s *DatabaseStorage) GetUserURLsPage(ctx context.Context, userID string, query models.URLQuery) (models.URLPage, error) {
+	desc := query.Sort == models.SortCreatedDesc
+	cursor, err := decodeCursor(query.Cursor, desc)
+	if err != nil {
+		return models.URLPage{}, err
+	}
+
+	conditions := []string{"user_id = $1"}
+	args := []any{userID}
+	addArg := func(value any) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if query.Deleted != nil {
+		conditions = append(conditions, "is_deleted = "+addArg(*query.Deleted))
+	}
+	if query.Search != "" {
+		conditions = append(conditions, "original_url ILIKE '%' || "+addArg(escapeLike(query.Search))+" || '%'")
+	}
+	if query.Tag != "" {
+		conditions = append(conditions, "tags @> ARRAY["+addArg(query.Tag)+"]::TEXT[]")
+	}
+	direction, comparison := "ASC", ">"
+	if desc {
+		direction, comparison = "DESC", "<"
+	}
+	if cursor != nil {
+		conditions = append(conditions, fmt.Sprintf("(created_at, short_id) %s (%s, %s)",
+			comparison, addArg(cursor.createdAt), addArg(cursor.shortID)))
+	}
+
+	// Запрашиваем на одну запись больше, чтобы узнать, есть ли следующая страница
+	sqlQuery := fmt.Sprintf(`
+		SELECT %s FROM urls
+		WHERE %s
+		ORDER BY created_at %s, short_id %s
+		LIMIT %s`,
+		urlDataColumns, strings.Join(conditions, " AND "), direction, direction, addArg(query.Limit+1))
+
+	rows, err := s.db.QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		return models.URLPage{}, fmt.Errorf("failed to query user URLs: %w", err)
+	}
+	defer rows.Close()
+
+	page := models.URLPage{URLs: make([]models.URLData, 0, query.Limit)}
+	for rows.Next() {
+		url, err := scanURLData(rows)
+		if err != nil {
+			return models.URLPage{}, err
+		}
+		if len(page.URLs) == query.Limit {
+			page.NextCursor = encodeCursor(page.URLs[len(page.URLs)-1], desc)
+			break
+		}
+		page.URLs = append(page.URLs, url)
+	}
+	if err := rows.Err(); err != nil {
+		return models.URLPage{}, fmt.Errorf("error iterating URL rows: %w", err)
+	}
+
+	return page, nil
+}
+
+// escapeLike экранирует спецсимволы шаблона LIKE
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
 // MarkURLsAsDeleted помечает запись как удаленную
 func (s *DatabaseStorage) MarkURLsAsDeleted(ctx context.Context, shortIDs []string, userID string) error {
 	result, err := s.db.ExecContext(ctx, `
         UPDATE urls
-        SET is_deleted = TRUE
+        SET is_deleted = TRUE, deleted_at = COALESCE(deleted_at, NOW())
         WHERE short_id = ANY($1) AND user_id = $2
     `, pq.Array(shortIDs), userID)
 
@@ -178,3 +425,265 @@ func (s *DatabaseStorage) MarkURLsAsDeleted(ctx context.Context, shortIDs []stri
 
 	return nil
 }
+
+// RestoreURLs снимает пометку удаления с URL пользователя.
+// Порядок результата совпадает с порядком запроса.
+func (s *DatabaseStorage) RestoreURLs(ctx context.Context, shortIDs []string, userID string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE urls
+		SET is_deleted = FALSE, deleted_at = NULL
+		WHERE short_id = ANY($1) AND user_id = $2 AND is_deleted
+		RETURNING short_id
+	`, pq.Array(shortIDs), userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore URLs: %w", err)
+	}
+	defer rows.Close()
+
+	found := make(map[string]bool)
+	for rows.Next() {
+		var shortID string
+		if err := rows.Scan(&shortID); err != nil {
+			return nil, err
+		}
+		found[shortID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating restored rows: %w", err)
+	}
+
+	var restored []string
+	for _, shortID := range shortIDs {
+		if found[shortID] {
+			restored = append(restored, shortID)
+			delete(found, shortID)
+		}
+	}
+	return restored, nil
+}
+
+// UpdateURL меняет адрес назначения и записывает прежний в url_history в одной транзакции.
+// Строка блокируется, поэтому параллельные изменения не теряют версии.
+func (s *DatabaseStorage) UpdateURL(ctx context.Context, shortID, userID, longURL string) (string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var previous string
+	err = tx.QueryRowContext(ctx, `
+		SELECT original_url FROM urls
+		WHERE short_id = $1 AND user_id = $2 AND is_deleted IS NOT TRUE
+		FOR UPDATE`, shortID, userID).Scan(&previous)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrURLNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to lock URL: %w", err)
+	}
+	if longURL == previous {
+		return previous, nil
+	}
+
+	// Уникальный индекс длинных URL сам переносится на новый адрес
+	if _, err := tx.ExecContext(ctx, "UPDATE urls SET original_url = $2 WHERE short_id = $1", shortID, longURL); err != nil {
+		if conflict := uniqueViolation(err); conflict != nil {
+			return "", conflict
+		}
+		return "", fmt.Errorf("failed to update URL: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "INSERT INTO url_history (short_id, original_url) VALUES ($1, $2)", shortID, previous); err != nil {
+		return "", fmt.Errorf("failed to save URL history: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return previous, nil
+}
+
+// GetURLHistory собирает версии из текущей строки urls и прежних адресов в url_history
+func (s *DatabaseStorage) GetURLHistory(ctx context.Context, shortID, userID string) ([]models.URLVersion, error) {
+	var current string
+	var createdAt time.Time
+	err := s.db.QueryRowContext(ctx,
+		"SELECT original_url, created_at FROM urls WHERE short_id = $1 AND user_id = $2",
+		shortID, userID).Scan(&current, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrURLNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query URL: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx,
+		"SELECT original_url, replaced_at FROM url_history WHERE short_id = $1 ORDER BY id", shortID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query URL history: %w", err)
+	}
+	defer rows.Close()
+
+	var revisions []urlRevision
+	for rows.Next() {
+		var revision urlRevision
+		if err := rows.Scan(&revision.OriginalURL, &revision.ReplacedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan URL history: %w", err)
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating URL history rows: %w", err)
+	}
+
+	return urlHistory(createdAt, current, revisions), nil
+}
+
+// SetURLLabels заменяет заголовок, метки и заметку URL пользователя
+func (s *DatabaseStorage) SetURLLabels(ctx context.Context, shortID, userID string, labels models.URLLabels) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE urls SET title = $3, tags = $4, note = $5
+		WHERE short_id = $1 AND user_id = $2 AND is_deleted IS NOT TRUE`,
+		shortID, userID, sql.NullString{String: labels.Title, Valid: labels.Title != ""},
+		tagsArray(labels.Tags), sql.NullString{String: labels.Note, Valid: labels.Note != ""})
+	if err != nil {
+		return fmt.Errorf("failed to update URL labels: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if updated == 0 {
+		return ErrURLNotFound
+	}
+	return nil
+}
+
+// SetURLRouting заменяет правила выбора адреса URL пользователя
+func (s *DatabaseStorage) SetURLRouting(ctx context.Context, shortID, userID string, routing *models.Routing) error {
+	value, err := routingJSON(routing)
+	if err != nil {
+		return err
+	}
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE urls SET routing = $3
+		WHERE short_id = $1 AND user_id = $2 AND is_deleted IS NOT TRUE`,
+		shortID, userID, value)
+	if err != nil {
+		return fmt.Errorf("failed to update URL routing: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if updated == 0 {
+		return ErrURLNotFound
+	}
+	return nil
+}
+
+// GetUserTags считает метки неудаленных URL пользователя
+func (s *DatabaseStorage) GetUserTags(ctx context.Context, userID string) ([]models.TagCount, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT tag, COUNT(*) FROM urls, unnest(tags) AS tag
+		WHERE user_id = $1 AND is_deleted IS NOT TRUE
+		GROUP BY tag
+		ORDER BY COUNT(*) DESC, tag COLLATE "C"`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query user tags: %w", err)
+	}
+	defer rows.Close()
+
+	tags := make([]models.TagCount, 0)
+	for rows.Next() {
+		var tag models.TagCount
+		if err := rows.Scan(&tag.Tag, &tag.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan tag: %w", err)
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tag rows: %w", err)
+	}
+	return tags, nil
+}
+
+// ConsumeClick списывает переход условным UPDATE: строка блокируется на время
+// обновления, поэтому параллельные переходы не уводят счетчик ниже нуля
+func (s *DatabaseStorage) ConsumeClick(ctx context.Context, shortID string) (bool, error) {
+	var left int
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE urls SET clicks_left = clicks_left - 1
+		WHERE short_id = $1 AND clicks_left > 0
+		RETURNING clicks_left`, shortID).Scan(&left)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to consume click: %w", err)
+	}
+	return true, nil
+}
+
+// PurgeDeletedURLs окончательно удаляет до limit URL, удаленных или истекших раньше before.
+// Один пакет удаляется одним запросом, поэтому блокировки строк держатся недолго.
+func (s *DatabaseStorage) PurgeDeletedURLs(ctx context.Context, before time.Time, limit int) ([]models.URLData, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		DELETE FROM urls
+		WHERE id IN (
+			SELECT id FROM urls
+			WHERE (is_deleted AND deleted_at < $1) OR expires_at < $1
+			ORDER BY id
+			LIMIT $2
+		)
+		RETURNING `+urlDataColumns, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to purge URLs: %w", err)
+	}
+	defer rows.Close()
+
+	var purged []models.URLData
+	for rows.Next() {
+		url, err := scanURLData(rows)
+		if err != nil {
+			return nil, err
+		}
+		purged = append(purged, url)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating purged rows: %w", err)
+	}
+
+	return purged, nil
+}
+
+// defunctCondition условие models.URLData.Defunct для момента $2
+const defunctCondition = "(expires_at <= $2 OR clicks_left <= 0)"
+
+// ReleaseURL окончательно удаляет неработающий URL вместе с его историей
+func (s *DatabaseStorage) ReleaseURL(ctx context.Context, shortID string, now time.Time) (models.URLData, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		DELETE FROM urls
+		WHERE short_id = $1 AND `+defunctCondition+`
+		RETURNING `+urlDataColumns, shortID, now)
+	if err != nil {
+		return models.URLData{}, fmt.Errorf("failed to release URL: %w", err)
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		return scanURLData(rows)
+	}
+	if err := rows.Err(); err != nil {
+		return models.URLData{}, fmt.Errorf("failed to release URL: %w", err)
+	}
+
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM urls WHERE short_id = $1)", shortID).Scan(&exists); err != nil {
+		return models.URLData{}, fmt.Errorf("failed to check URL: %w", err)
+	}
+	if exists {
+		return models.URLData{}, ErrURLExists
+	}
+	return models.URLData{}, ErrURLNotFound
+}
@@ -4,14 +4,20 @@ import (
 	"context"
 	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgerrcode"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
 	"github.com/Eorthus/shorturl/internal/models"
 )
 
+// urlDataRowColumns колонки строк, которые читает scanURLData
+var urlDataRowColumns = []string{"short_id", "original_url", "user_id", "is_deleted", "created_at", "deleted_at", "expires_at", "clicks_left", "password_hash", "redirect_code", "passthrough", "tags", "note", "routing", "title", "not_before"}
+
 func setupTest(t *testing.T) (*DatabaseStorage, sqlmock.Sqlmock) {
 	db, mock, err := sqlmock.New()
 	require.NoError(t, err)
@@ -35,6 +41,32 @@ func TestDatabaseStorage_SaveURL(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestDatabaseStorage_SaveURL_Duplicate(t *testing.T) {
+	store, mock := setupTest(t)
+	defer store.db.Close()
+
+	mock.ExpectExec("INSERT INTO urls").
+		WithArgs("abc123", "https://example.com", "user1").
+		WillReturnError(&pq.Error{Code: pgerrcode.UniqueViolation, Constraint: "idx_original_url"})
+
+	err := store.SaveURL(context.Background(), "abc123", "https://example.com", "user1")
+	assert.ErrorIs(t, err, ErrURLExists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabaseStorage_SaveURL_ShortIDTaken(t *testing.T) {
+	store, mock := setupTest(t)
+	defer store.db.Close()
+
+	mock.ExpectExec("INSERT INTO urls").
+		WithArgs("abc123", "https://example.org", "user1").
+		WillReturnError(&pq.Error{Code: pgerrcode.UniqueViolation, Constraint: "urls_short_id_key"})
+
+	err := store.SaveURL(context.Background(), "abc123", "https://example.org", "user1")
+	assert.ErrorIs(t, err, ErrShortIDExists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestDatabaseStorage_GetURL(t *testing.T) {
 	store, mock := setupTest(t)
 	defer store.db.Close()
@@ -57,6 +89,60 @@ func TestDatabaseStorage_GetURL(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestDatabaseStorage_GetURLData(t *testing.T) {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	notBefore := createdAt.Add(time.Hour)
+	expiresAt := createdAt.Add(24 * time.Hour)
+
+	t.Run("Existing URL", func(t *testing.T) {
+		store, mock := setupTest(t)
+		defer store.db.Close()
+
+		rows := sqlmock.NewRows(urlDataRowColumns).
+			AddRow("abc123", "https://example.com", "user1", false, createdAt, nil, expiresAt, 3, "hash", 308, true, "{promo,spring-sale}", "Весенняя акция",
+				`{"rules":[{"platform":"ios","url":"https://apps.example.com"}]}`, "Весна", notBefore)
+		mock.ExpectQuery("SELECT short_id, original_url, COALESCE\\(user_id, ''\\), is_deleted, created_at, deleted_at, expires_at, clicks_left, COALESCE\\(password_hash, ''\\), COALESCE\\(redirect_code, 0\\), passthrough, tags, COALESCE\\(note, ''\\), routing, COALESCE\\(title, ''\\), not_before FROM urls WHERE short_id = \\$1").
+			WithArgs("abc123").
+			WillReturnRows(rows)
+
+		clicksLeft := 3
+		url, found, err := store.GetURLData(context.Background(), "abc123")
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, models.URLData{
+			ShortURL:     "abc123",
+			OriginalURL:  "https://example.com",
+			UserID:       "user1",
+			CreatedAt:    createdAt,
+			NotBefore:    &notBefore,
+			ExpiresAt:    &expiresAt,
+			ClicksLeft:   &clicksLeft,
+			PasswordHash: "hash",
+			RedirectCode: 308,
+			Passthrough:  true,
+			Title:        "Весна",
+			Tags:         []string{"promo", "spring-sale"},
+			Note:         "Весенняя акция",
+			Routing:      &models.Routing{Rules: []models.RoutingRule{{Platform: "ios", URL: "https://apps.example.com"}}},
+		}, url)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Non-existing URL", func(t *testing.T) {
+		store, mock := setupTest(t)
+		defer store.db.Close()
+
+		mock.ExpectQuery("SELECT .* FROM urls WHERE short_id = \\$1").
+			WithArgs("missing").
+			WillReturnError(sql.ErrNoRows)
+
+		_, found, err := store.GetURLData(context.Background(), "missing")
+		assert.NoError(t, err)
+		assert.False(t, found)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
 func TestDatabaseStorage_SaveURLBatch(t *testing.T) {
 	store, mock := setupTest(t)
 	defer store.db.Close()
@@ -71,9 +157,9 @@ func TestDatabaseStorage_SaveURLBatch(t *testing.T) {
 	mock.ExpectPrepare("INSERT INTO urls")
 
 	// Важно: задаем ожидания в том же порядке, в котором будут выполняться запросы
-	for shortID, longURL := range urls {
+	for _, shortID := range []string{"def456", "ghi789"} {
 		mock.ExpectExec("INSERT INTO urls").
-			WithArgs(shortID, longURL, userID).
+			WithArgs(shortID, urls[shortID], userID).
 			WillReturnResult(sqlmock.NewResult(1, 1))
 	}
 	mock.ExpectCommit()
@@ -123,23 +209,28 @@ func TestDatabaseStorage_GetUserURLs(t *testing.T) {
 	defer store.db.Close()
 
 	userID := "user1"
-	expectedURLs := []models.URLData{
-		{ShortURL: "abc123", OriginalURL: "https://example.com"},
-		{ShortURL: "def456", OriginalURL: "https://example.org"},
-	}
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	deletedAt := createdAt.Add(time.Hour)
 
-	rows := sqlmock.NewRows([]string{"short_id", "original_url"})
-	for _, url := range expectedURLs {
-		rows.AddRow(url.ShortURL, url.OriginalURL)
-	}
+	rows := sqlmock.NewRows(urlDataRowColumns).
+		AddRow("abc123", "https://example.com", userID, false, createdAt, nil, nil, nil, "", 0, false, "{promo}", "", nil, "Акция", nil).
+		AddRow("def456", "https://example.org", userID, true, createdAt.Add(time.Minute), deletedAt, nil, nil, "", 0, false, "{}", "", nil, "", nil)
 
-	mock.ExpectQuery("SELECT short_id, original_url FROM urls WHERE user_id = \\$1").
+	mock.ExpectQuery(`SELECT short_id, original_url, .+ FROM urls WHERE user_id = \$1 ORDER BY created_at, short_id`).
 		WithArgs(userID).
 		WillReturnRows(rows)
 
 	urls, err := store.GetUserURLs(context.Background(), userID)
 	assert.NoError(t, err)
-	assert.Equal(t, expectedURLs, urls)
+	require.Len(t, urls, 2)
+	assert.Equal(t, "abc123", urls[0].ShortURL)
+	assert.Equal(t, userID, urls[0].UserID)
+	assert.Equal(t, createdAt, urls[0].CreatedAt)
+	assert.Equal(t, []string{"promo"}, urls[0].Tags)
+	assert.Equal(t, "Акция", urls[0].Title)
+	assert.True(t, urls[1].IsDeleted)
+	require.NotNil(t, urls[1].DeletedAt)
+	assert.Equal(t, deletedAt, *urls[1].DeletedAt)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -150,7 +241,7 @@ func TestDatabaseStorage_MarkURLsAsDeleted(t *testing.T) {
 	shortIDs := []string{"abc123", "def456"}
 	userID := "user1"
 
-	mock.ExpectExec("UPDATE urls SET is_deleted = TRUE WHERE short_id = ANY").
+	mock.ExpectExec(`UPDATE urls SET is_deleted = TRUE, deleted_at = COALESCE\(deleted_at, NOW\(\)\) WHERE short_id = ANY`).
 		WithArgs(sqlmock.AnyArg(), userID).
 		WillReturnResult(sqlmock.NewResult(0, 2))
 
@@ -158,3 +249,333 @@ func TestDatabaseStorage_MarkURLsAsDeleted(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestDatabaseStorage_RestoreURLs(t *testing.T) {
+	store, mock := setupTest(t)
+	defer store.db.Close()
+
+	mock.ExpectQuery(`UPDATE urls SET is_deleted = FALSE, deleted_at = NULL WHERE short_id = ANY\(\$1\) AND user_id = \$2 AND is_deleted RETURNING short_id`).
+		WithArgs(sqlmock.AnyArg(), "user1").
+		WillReturnRows(sqlmock.NewRows([]string{"short_id"}).AddRow("def456").AddRow("abc123"))
+
+	restored, err := store.RestoreURLs(context.Background(), []string{"abc123", "missing", "def456", "abc123"}, "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"abc123", "def456"}, restored, "Порядок запроса, без повторов")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabaseStorage_SaveURLData(t *testing.T) {
+	store, mock := setupTest(t)
+	defer store.db.Close()
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	expiresAt := createdAt.Add(time.Hour)
+	urls := []models.URLData{
+		{ShortURL: "abc123", OriginalURL: "https://example.com", UserID: "user1", CreatedAt: createdAt, ExpiresAt: &expiresAt, PasswordHash: "hash"},
+		{ShortURL: "def456", OriginalURL: "https://example.org", UserID: "user1", IsDeleted: true, CreatedAt: createdAt},
+	}
+	// Удаленный URL без времени удаления считается удаленным в момент создания
+	deletedAt := map[string]any{"abc123": nil, "def456": createdAt}
+	expires := map[string]any{"abc123": expiresAt, "def456": nil}
+	// Пустой хеш пароля сохраняется как NULL
+	passwordHash := map[string]any{"abc123": "hash", "def456": nil}
+
+	mock.ExpectBegin()
+	mock.ExpectPrepare("INSERT INTO urls")
+	for _, url := range urls {
+		mock.ExpectExec("INSERT INTO urls").
+			WithArgs(url.ShortURL, url.OriginalURL, url.UserID, url.IsDeleted, createdAt, deletedAt[url.ShortURL], expires[url.ShortURL], nil, passwordHash[url.ShortURL], nil, false, "{}", nil, nil, nil, nil).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	mock.ExpectCommit()
+
+	err := store.SaveURLData(context.Background(), urls)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabaseStorage_IterateURLs(t *testing.T) {
+	store, mock := setupTest(t)
+	defer store.db.Close()
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	expectedURLs := []models.URLData{
+		{ShortURL: "abc123", OriginalURL: "https://example.com", UserID: "user1", CreatedAt: createdAt},
+		{ShortURL: "def456", OriginalURL: "https://example.org", UserID: "", IsDeleted: true, CreatedAt: createdAt, DeletedAt: &createdAt},
+	}
+
+	rows := sqlmock.NewRows(urlDataRowColumns).
+		AddRow("abc123", "https://example.com", "user1", false, createdAt, nil, nil, nil, "", 0, false, "{}", "", nil, "", nil).
+		AddRow("def456", "https://example.org", "", true, createdAt, createdAt, nil, nil, "", 0, false, "{}", "", nil, "", nil)
+	mock.ExpectQuery("SELECT short_id, original_url, COALESCE\\(user_id, ''\\), is_deleted, created_at, deleted_at, expires_at, clicks_left, COALESCE\\(password_hash, ''\\), COALESCE\\(redirect_code, 0\\), passthrough, tags, COALESCE\\(note, ''\\), routing, COALESCE\\(title, ''\\), not_before FROM urls ORDER BY id").
+		WillReturnRows(rows)
+
+	var urls []models.URLData
+	err := store.IterateURLs(context.Background(), func(url models.URLData) error {
+		urls = append(urls, url)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, expectedURLs, urls)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabaseStorage_GetUserURLsPage(t *testing.T) {
+	store, mock := setupTest(t)
+	defer store.db.Close()
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	deleted := false
+	cursor := encodeCursor(models.URLData{ShortURL: "aaa111", CreatedAt: createdAt}, true)
+
+	expiresAt := createdAt.Add(time.Hour)
+	rows := sqlmock.NewRows(urlDataRowColumns).
+		AddRow("bbb222", "https://example.com/b", "user1", false, createdAt.Add(-time.Minute), nil, expiresAt, nil, "", 0, false, "{}", "", nil, "", nil).
+		AddRow("ccc333", "https://example.com/c", "user1", false, createdAt.Add(-2*time.Minute), nil, nil, nil, "", 0, false, "{}", "", nil, "", nil)
+
+	mock.ExpectQuery(`WHERE user_id = \$1 AND is_deleted = \$2 AND original_url ILIKE '%' \|\| \$3 \|\| '%' `+
+		`AND tags @> ARRAY\[\$4\]::TEXT\[\] AND \(created_at, short_id\) < \(\$5, \$6\)\s+ORDER BY created_at DESC, short_id DESC\s+LIMIT \$7`).
+		WithArgs("user1", false, `50\%\_off`, "promo", createdAt, "aaa111", 2).
+		WillReturnRows(rows)
+
+	page, err := store.GetUserURLsPage(context.Background(), "user1", models.URLQuery{
+		Limit:   1,
+		Cursor:  cursor,
+		Sort:    models.SortCreatedDesc,
+		Deleted: &deleted,
+		Search:  "50%_off",
+		Tag:     "promo",
+	})
+	require.NoError(t, err)
+	require.Len(t, page.URLs, 1)
+	assert.Equal(t, "bbb222", page.URLs[0].ShortURL)
+	assert.Equal(t, "user1", page.URLs[0].UserID)
+	require.NotNil(t, page.URLs[0].ExpiresAt)
+	assert.Equal(t, expiresAt, *page.URLs[0].ExpiresAt)
+	assert.Equal(t, encodeCursor(page.URLs[0], true), page.NextCursor)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabaseStorage_PurgeDeletedURLs(t *testing.T) {
+	store, mock := setupTest(t)
+	defer store.db.Close()
+
+	before := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	deletedAt := createdAt.Add(time.Hour)
+
+	rows := sqlmock.NewRows(urlDataRowColumns).
+		AddRow("abc123", "https://example.com", "user1", true, createdAt, deletedAt, nil, nil, "", 0, false, "{}", "", nil, "", nil)
+	mock.ExpectQuery(`DELETE FROM urls\s+WHERE id IN \(\s+SELECT id FROM urls\s+`+
+		`WHERE \(is_deleted AND deleted_at < \$1\) OR expires_at < \$1\s+ORDER BY id\s+LIMIT \$2`).
+		WithArgs(before, 100).
+		WillReturnRows(rows)
+
+	purged, err := store.PurgeDeletedURLs(context.Background(), before, 100)
+	require.NoError(t, err)
+	assert.Equal(t, []models.URLData{{
+		ShortURL:    "abc123",
+		OriginalURL: "https://example.com",
+		UserID:      "user1",
+		IsDeleted:   true,
+		CreatedAt:   createdAt,
+		DeletedAt:   &deletedAt,
+	}}, purged)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabaseStorage_ReleaseURL(t *testing.T) {
+	store, mock := setupTest(t)
+	defer store.db.Close()
+
+	now := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	expiresAt := now.Add(-time.Hour)
+	release := `DELETE FROM urls\s+WHERE short_id = \$1 AND \(expires_at <= \$2 OR clicks_left <= 0\)\s+RETURNING`
+	exists := `SELECT EXISTS\(SELECT 1 FROM urls WHERE short_id = \$1\)`
+
+	mock.ExpectQuery(release).WithArgs("abc123", now).
+		WillReturnRows(sqlmock.NewRows(urlDataRowColumns).
+			AddRow("abc123", "https://example.com", "user1", false, createdAt, nil, expiresAt, nil, "", 0, false, "{}", "", nil, "", nil))
+	mock.ExpectQuery(release).WithArgs("live", now).WillReturnRows(sqlmock.NewRows(urlDataRowColumns))
+	mock.ExpectQuery(exists).WithArgs("live").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(release).WithArgs("missing", now).WillReturnRows(sqlmock.NewRows(urlDataRowColumns))
+	mock.ExpectQuery(exists).WithArgs("missing").WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	released, err := store.ReleaseURL(context.Background(), "abc123", now)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com", released.OriginalURL)
+	assert.Equal(t, &expiresAt, released.ExpiresAt)
+
+	_, err = store.ReleaseURL(context.Background(), "live", now)
+	assert.ErrorIs(t, err, ErrURLExists)
+	_, err = store.ReleaseURL(context.Background(), "missing", now)
+	assert.ErrorIs(t, err, ErrURLNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabaseStorage_ConsumeClick(t *testing.T) {
+	store, mock := setupTest(t)
+	defer store.db.Close()
+
+	query := `UPDATE urls SET clicks_left = clicks_left - 1\s+WHERE short_id = \$1 AND clicks_left > 0\s+RETURNING clicks_left`
+	mock.ExpectQuery(query).
+		WithArgs("abc123").
+		WillReturnRows(sqlmock.NewRows([]string{"clicks_left"}).AddRow(0))
+	mock.ExpectQuery(query).
+		WithArgs("abc123").
+		WillReturnError(sql.ErrNoRows)
+
+	consumed, err := store.ConsumeClick(context.Background(), "abc123")
+	assert.NoError(t, err)
+	assert.True(t, consumed)
+
+	consumed, err = store.ConsumeClick(context.Background(), "abc123")
+	assert.NoError(t, err)
+	assert.False(t, consumed, "Исчерпанная ссылка не должна списывать переходы")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabaseStorage_UpdateURL(t *testing.T) {
+	selectQuery := `SELECT original_url FROM urls\s+WHERE short_id = \$1 AND user_id = \$2 AND is_deleted IS NOT TRUE\s+FOR UPDATE`
+
+	t.Run("Successful update", func(t *testing.T) {
+		store, mock := setupTest(t)
+		defer store.db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(selectQuery).
+			WithArgs("abc123", "user1").
+			WillReturnRows(sqlmock.NewRows([]string{"original_url"}).AddRow("https://old.com"))
+		mock.ExpectExec("UPDATE urls SET original_url = \\$2 WHERE short_id = \\$1").
+			WithArgs("abc123", "https://new.com").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO url_history \\(short_id, original_url\\) VALUES \\(\\$1, \\$2\\)").
+			WithArgs("abc123", "https://old.com").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		previous, err := store.UpdateURL(context.Background(), "abc123", "user1", "https://new.com")
+		require.NoError(t, err)
+		assert.Equal(t, "https://old.com", previous)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Foreign URL", func(t *testing.T) {
+		store, mock := setupTest(t)
+		defer store.db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(selectQuery).
+			WithArgs("abc123", "user2").
+			WillReturnError(sql.ErrNoRows)
+		mock.ExpectRollback()
+
+		_, err := store.UpdateURL(context.Background(), "abc123", "user2", "https://new.com")
+		assert.ErrorIs(t, err, ErrURLNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Destination already shortened", func(t *testing.T) {
+		store, mock := setupTest(t)
+		defer store.db.Close()
+
+		mock.ExpectBegin()
+		mock.ExpectQuery(selectQuery).
+			WithArgs("abc123", "user1").
+			WillReturnRows(sqlmock.NewRows([]string{"original_url"}).AddRow("https://old.com"))
+		mock.ExpectExec("UPDATE urls SET original_url").
+			WithArgs("abc123", "https://taken.com").
+			WillReturnError(&pq.Error{Code: pgerrcode.UniqueViolation, Constraint: "idx_original_url"})
+		mock.ExpectRollback()
+
+		_, err := store.UpdateURL(context.Background(), "abc123", "user1", "https://taken.com")
+		assert.ErrorIs(t, err, ErrURLExists)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDatabaseStorage_GetURLHistory(t *testing.T) {
+	store, mock := setupTest(t)
+	defer store.db.Close()
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	replacedAt := createdAt.Add(time.Hour)
+
+	mock.ExpectQuery("SELECT original_url, created_at FROM urls WHERE short_id = \\$1 AND user_id = \\$2").
+		WithArgs("abc123", "user1").
+		WillReturnRows(sqlmock.NewRows([]string{"original_url", "created_at"}).AddRow("https://new.com", createdAt))
+	mock.ExpectQuery("SELECT original_url, replaced_at FROM url_history WHERE short_id = \\$1 ORDER BY id").
+		WithArgs("abc123").
+		WillReturnRows(sqlmock.NewRows([]string{"original_url", "replaced_at"}).AddRow("https://old.com", replacedAt))
+
+	versions, err := store.GetURLHistory(context.Background(), "abc123", "user1")
+	require.NoError(t, err)
+	assert.Equal(t, []models.URLVersion{
+		{Version: 1, OriginalURL: "https://old.com", ActiveFrom: createdAt, ActiveUntil: &replacedAt},
+		{Version: 2, OriginalURL: "https://new.com", ActiveFrom: replacedAt},
+	}, versions)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabaseStorage_SetURLLabels(t *testing.T) {
+	t.Run("Заголовок, метки и заметка заменяются", func(t *testing.T) {
+		store, mock := setupTest(t)
+		defer store.db.Close()
+
+		mock.ExpectExec("UPDATE urls SET title = \\$3, tags = \\$4, note = \\$5").
+			WithArgs("abc123", "user1", "Акция", `{"promo","sale"}`, "Весна").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := store.SetURLLabels(context.Background(), "abc123", "user1", models.URLLabels{Title: "Акция", Tags: []string{"promo", "sale"}, Note: "Весна"})
+		assert.NoError(t, err)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+
+	t.Run("Чужой URL не найден", func(t *testing.T) {
+		store, mock := setupTest(t)
+		defer store.db.Close()
+
+		mock.ExpectExec("UPDATE urls SET title = \\$3, tags = \\$4, note = \\$5").
+			WithArgs("abc123", "user2", nil, "{}", nil).
+			WillReturnResult(sqlmock.NewResult(0, 0))
+
+		err := store.SetURLLabels(context.Background(), "abc123", "user2", models.URLLabels{})
+		assert.ErrorIs(t, err, ErrURLNotFound)
+		assert.NoError(t, mock.ExpectationsWereMet())
+	})
+}
+
+func TestDatabaseStorage_GetUserTags(t *testing.T) {
+	store, mock := setupTest(t)
+	defer store.db.Close()
+
+	mock.ExpectQuery("SELECT tag, COUNT\\(\\*\\) FROM urls, unnest\\(tags\\) AS tag").
+		WithArgs("user1").
+		WillReturnRows(sqlmock.NewRows([]string{"tag", "count"}).AddRow("promo", 2).AddRow("sale", 1))
+
+	tags, err := store.GetUserTags(context.Background(), "user1")
+	require.NoError(t, err)
+	assert.Equal(t, []models.TagCount{{Tag: "promo", Count: 2}, {Tag: "sale", Count: 1}}, tags)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDatabaseStorage_SetURLRouting(t *testing.T) {
+	store, mock := setupTest(t)
+	defer store.db.Close()
+
+	mock.ExpectExec("UPDATE urls SET routing = \\$3").
+		WithArgs("abc123", "user1", `{"variants":[{"name":"a","url":"https://a.example.com","weight":1}]}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE urls SET routing = \\$3").
+		WithArgs("abc123", "user2", nil).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err := store.SetURLRouting(context.Background(), "abc123", "user1", &models.Routing{
+		Variants: []models.RoutingVariant{{Name: "a", URL: "https://a.example.com", Weight: 1}},
+	})
+	assert.NoError(t, err)
+	err = store.SetURLRouting(context.Background(), "abc123", "user2", nil)
+	assert.ErrorIs(t, err, ErrURLNotFound, "Чужой URL не найден")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -2,31 +2,93 @@ package storage
 
 import (
 	"bufio"
+	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"slices"
 	"sync"
+	"time"
 
 	"github.com/Eorthus/shorturl/internal/models"
 )
 
-// FileStorage реализует файловое хранение URL
+// DefaultCompactThreshold размер журнала в байтах, после которого запускается компактизация
+const DefaultCompactThreshold int64 = 4 << 20
+
+// Режимы сброса журнала на диск
+const (
+	// SyncAlways сбрасывает журнал на диск после каждой записи
+	SyncAlways = "always"
+	// SyncNone оставляет сброс операционной системе: быстрее, но последние
+	// записи могут потеряться при сбое питания
+	SyncNone = "none"
+)
+
+// FileOptions параметры файлового хранилища
+type FileOptions struct {
+	// CompactThreshold размер журнала, после которого запускается компактизация;
+	// нулевое или отрицательное значение отключает компактизацию
+	CompactThreshold int64
+	// Sync режим сброса журнала на диск, по умолчанию SyncAlways
+	Sync string
+}
+
+// FileStorage реализует файловое хранение URL.
+//
+// Файл является журналом в формате JSON Lines: каждая мутация дописывает
+// в конец файла актуальное состояние затронутых записей, при загрузке
+// побеждает последняя запись для каждого короткого идентификатора.
+// Когда журнал превышает порог, он в фоне переписывается во временный
+// файл, который атомарно заменяет исходный.
 type FileStorage struct {
-	filePath    string
-	data        map[string]models.URLData
-	userURLs    map[string][]string
-	deletedURLs map[string]bool
-	mutex       sync.RWMutex
+	filePath string
+	data     map[string]urlRecord
+	longURLs map[string]string
+	userURLs map[string][]string
+	mutex    sync.RWMutex
+
+	journal          *os.File
+	journalSize      int64
+	compactThreshold int64
+	sync             bool
+	compacting       bool
+	pending          [][]byte // записи, дописанные во время компактизации
+	wg               sync.WaitGroup
 }
 
-// NewFileStorage создает новое файловое хранилище
+// NewFileStorage создает новое файловое хранилище с порогом компактизации по умолчанию
 func NewFileStorage(ctx context.Context, filePath string) (*FileStorage, error) {
+	return NewFileStorageWithCompaction(ctx, filePath, DefaultCompactThreshold)
+}
+
+// NewFileStorageWithCompaction создает новое файловое хранилище.
+// Журнал компактизируется, когда его размер превышает threshold байт;
+// нулевое или отрицательное значение отключает компактизацию.
+func NewFileStorageWithCompaction(ctx context.Context, filePath string, threshold int64) (*FileStorage, error) {
+	return NewFileStorageWithOptions(ctx, filePath, FileOptions{CompactThreshold: threshold})
+}
+
+// NewFileStorageWithOptions создает новое файловое хранилище с параметрами opts
+func NewFileStorageWithOptions(ctx context.Context, filePath string, opts FileOptions) (*FileStorage, error) {
+	switch opts.Sync {
+	case "", SyncAlways, SyncNone:
+	default:
+		return nil, fmt.Errorf("unknown file storage sync mode %q", opts.Sync)
+	}
+
 	fs := &FileStorage{
-		filePath:    filePath,
-		data:        make(map[string]models.URLData),
-		userURLs:    make(map[string][]string),
-		deletedURLs: make(map[string]bool),
+		filePath:         filePath,
+		data:             make(map[string]urlRecord),
+		longURLs:         make(map[string]string),
+		userURLs:         make(map[string][]string),
+		compactThreshold: opts.CompactThreshold,
+		sync:             opts.Sync != SyncNone,
 	}
 
 	// Проверяем существование файла, но не создаем его
@@ -37,57 +99,158 @@ func NewFileStorage(ctx context.Context, filePath string) (*FileStorage, error)
 	}
 
 	// Если файл существует, загружаем данные из файла
-	if err == nil && fs.loadFromFile(ctx) != nil {
-		return nil, fs.loadFromFile(ctx)
+	if err == nil {
+		if err := fs.loadFromFile(ctx); err != nil {
+			return nil, err
+		}
 	}
 
 	return fs, nil
 }
 
+// Close дожидается завершения компактизации и закрывает журнал
+func (fs *FileStorage) Close() error {
+	fs.wg.Wait()
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if fs.journal == nil {
+		return nil
+	}
+	err := fs.journal.Close()
+	fs.journal = nil
+	return err
+}
+
 // SaveURL сохраняет URL в файловое хранилище
 func (fs *FileStorage) SaveURL(ctx context.Context, shortID, longURL, userID string) error {
 	fs.mutex.Lock()
 	defer fs.mutex.Unlock()
 
-	urlData := models.URLData{
-		ShortURL:    shortID,
-		OriginalURL: longURL,
+	_, longExists := fs.longURLs[longURL]
+	_, shortExists := fs.data[shortID]
+	if err := conflictError(shortExists, longExists); err != nil {
+		return err
 	}
 
-	fs.data[shortID] = urlData
-	fs.userURLs[userID] = append(fs.userURLs[userID], shortID)
-
-	return fs.saveToFile(ctx)
+	return fs.writeRecords(ctx, newURLRecord(shortID, longURL, userID))
 }
 
 // GetURL возвращает URL из файлового хранилища
 func (fs *FileStorage) GetURL(ctx context.Context, shortID string) (string, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+
 	fs.mutex.RLock()
 	defer fs.mutex.RUnlock()
 
-	urlData, exists := fs.data[shortID]
+	record, exists := fs.data[shortID]
 	if !exists {
 		return "", false, nil
 	}
 
-	isDeleted := fs.deletedURLs[shortID]
-	return urlData.OriginalURL, isDeleted, nil
+	return record.OriginalURL, record.IsDeleted, nil
+}
+
+// GetURLData возвращает данные URL из файлового хранилища
+func (fs *FileStorage) GetURLData(ctx context.Context, shortID string) (models.URLData, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return models.URLData{}, false, err
+	}
+
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	record, exists := fs.data[shortID]
+	if !exists {
+		return models.URLData{}, false, nil
+	}
+
+	return record.urlData(), true, nil
 }
 
-// SaveURLBatch сохраняем массив URL
+// SaveURLBatch сохраняем массив URL.
+// Пакет сохраняется целиком либо не сохраняется вовсе.
 func (fs *FileStorage) SaveURLBatch(ctx context.Context, urls map[string]string, userID string) error {
 	fs.mutex.Lock()
 	defer fs.mutex.Unlock()
 
+	records := make([]urlRecord, 0, len(urls))
+	seen := make(map[string]bool, len(urls))
 	for shortID, longURL := range urls {
-		fs.data[shortID] = models.URLData{
-			ShortURL:    shortID,
-			OriginalURL: longURL,
+		_, shortExists := fs.data[shortID]
+		_, longExists := fs.longURLs[longURL]
+		if err := conflictError(shortExists, longExists || seen[longURL]); err != nil {
+			return err
 		}
-		fs.userURLs[userID] = append(fs.userURLs[userID], shortID)
+		seen[longURL] = true
+		records = append(records, newURLRecord(shortID, longURL, userID))
 	}
 
-	return fs.saveToFile(ctx)
+	return fs.writeRecords(ctx, records...)
+}
+
+// SaveURLData сохраняет URL с метаданными.
+// Пакет сохраняется целиком либо не сохраняется вовсе.
+func (fs *FileStorage) SaveURLData(ctx context.Context, urls []models.URLData) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	records := make([]urlRecord, 0, len(urls))
+	seenShort := make(map[string]bool, len(urls))
+	seenLong := make(map[string]bool, len(urls))
+	for _, url := range urls {
+		_, shortExists := fs.data[url.ShortURL]
+		_, longExists := fs.longURLs[url.OriginalURL]
+		if err := conflictError(shortExists || seenShort[url.ShortURL], longExists || seenLong[url.OriginalURL]); err != nil {
+			return err
+		}
+		seenShort[url.ShortURL] = true
+		seenLong[url.OriginalURL] = true
+		records = append(records, newURLRecordFromData(url))
+	}
+
+	if err := fs.writeRecords(ctx, records...); err != nil {
+		return err
+	}
+
+	// Импортированные URL могут быть старше уже сохраненных
+	sorted := make(map[string]bool)
+	for _, record := range records {
+		if record.UserID != "" && !sorted[record.UserID] {
+			sorted[record.UserID] = true
+			fs.sortByCreation(fs.userURLs[record.UserID])
+		}
+	}
+	return nil
+}
+
+// IterateURLs обходит все записи в порядке создания.
+// fn вызывается вне блокировки над снимком данных.
+func (fs *FileStorage) IterateURLs(ctx context.Context, fn func(url models.URLData) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	fs.mutex.RLock()
+	records := make([]urlRecord, 0, len(fs.data))
+	for _, record := range fs.data {
+		records = append(records, record)
+	}
+	fs.mutex.RUnlock()
+
+	slices.SortFunc(records, compareRecords)
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(record.urlData()); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Ping пингует db
@@ -104,36 +267,266 @@ func (fs *FileStorage) Ping(ctx context.Context) error {
 	}
 }
 
-func (fs *FileStorage) saveToFile(ctx context.Context) error {
-	select {
-	case <-ctx.Done():
-		return ctx.Err()
-	default:
-		file, err := os.OpenFile(fs.filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+// encodeRecord сериализует запись в строку журнала
+func encodeRecord(record urlRecord) ([]byte, error) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+// writeRecords дописывает записи в журнал и после успешной записи
+// применяет их к индексам в памяти. В режиме SyncAlways запись считается
+// успешной только после сброса на диск. Неудачная или частичная запись
+// отрезается, чтобы журнал не содержал изменений, не примененных в памяти.
+// Вызывается под блокировкой записи.
+func (fs *FileStorage) writeRecords(ctx context.Context, records ...urlRecord) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, record := range records {
+		line, err := encodeRecord(record)
 		if err != nil {
 			return err
 		}
-		defer file.Close()
+		buf.Write(line)
+	}
 
-		writer := bufio.NewWriter(file)
-		defer writer.Flush()
+	if fs.journal == nil {
+		file, err := os.OpenFile(fs.filePath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+		if err != nil {
+			return err
+		}
+		fs.journal = file
+	}
 
-		encoder := json.NewEncoder(writer)
-		for shortID, urlData := range fs.data {
-			data := struct {
-				models.URLData
-				IsDeleted bool `json:"is_deleted"`
-			}{
-				URLData:   urlData,
-				IsDeleted: fs.deletedURLs[shortID],
-			}
-			if err := encoder.Encode(data); err != nil {
-				return err
-			}
+	offset := fs.journalSize
+	_, err := fs.journal.Write(buf.Bytes())
+	if err == nil && fs.sync {
+		err = fs.journal.Sync()
+	}
+	if err != nil {
+		if truncErr := fs.journal.Truncate(offset); truncErr != nil {
+			// Хвост журнала неизвестен, следующая запись откроет файл заново,
+			// а загрузка отрежет недописанную строку
+			fs.journal.Close()
+			fs.journal = nil
+			return errors.Join(err, truncErr)
 		}
+		return err
+	}
+	fs.journalSize += int64(buf.Len())
+
+	for _, record := range records {
+		fs.apply(record)
+	}
 
+	if fs.compacting {
+		fs.pending = append(fs.pending, slices.Clone(buf.Bytes()))
+	} else if fs.compactThreshold > 0 && fs.journalSize > fs.compactThreshold {
+		fs.startCompaction()
+	}
+
+	return nil
+}
+
+// startCompaction запускает компактизацию в фоне. Вызывается под блокировкой записи.
+func (fs *FileStorage) startCompaction() {
+	fs.compacting = true
+	fs.wg.Add(1)
+	go func() {
+		defer fs.wg.Done()
+		// Ошибка компактизации не приводит к потере данных: журнал остается прежним
+		_ = fs.compact()
+	}()
+}
+
+// apply обновляет данные и индексы в памяти. Вызывается под блокировкой записи.
+func (fs *FileStorage) apply(record urlRecord) {
+	if record.Purged {
+		fs.remove(record.ShortURL)
+		return
+	}
+
+	prev, existed := fs.data[record.ShortURL]
+	if existed && prev.OriginalURL != record.OriginalURL {
+		delete(fs.longURLs, prev.OriginalURL)
+	}
+
+	fs.data[record.ShortURL] = record
+	fs.longURLs[record.OriginalURL] = record.ShortURL
+	if !existed && record.UserID != "" {
+		fs.userURLs[record.UserID] = append(fs.userURLs[record.UserID], record.ShortURL)
+	}
+}
+
+// remove удаляет запись из данных и индексов. Вызывается под блокировкой записи.
+func (fs *FileStorage) remove(shortID string) {
+	record, exists := fs.data[shortID]
+	if !exists {
+		return
+	}
+	delete(fs.data, shortID)
+	if fs.longURLs[record.OriginalURL] == shortID {
+		delete(fs.longURLs, record.OriginalURL)
+	}
+	if record.UserID != "" {
+		list := slices.DeleteFunc(fs.userURLs[record.UserID], func(id string) bool {
+			return id == shortID
+		})
+		if len(list) == 0 {
+			delete(fs.userURLs, record.UserID)
+		} else {
+			fs.userURLs[record.UserID] = list
+		}
+	}
+}
+
+// compact переписывает журнал, оставляя по одной строке на запись.
+// Снимок пишется во временный файл без блокировки, записи, появившиеся
+// за это время, дописываются под блокировкой перед атомарной заменой файла.
+func (fs *FileStorage) compact() error {
+	fs.mutex.Lock()
+	snapshot, err := fs.snapshot()
+	if err != nil {
+		fs.compacting = false
+		fs.mutex.Unlock()
+		return err
+	}
+	fs.pending = nil
+	fs.mutex.Unlock()
+
+	tmpPath := fs.filePath + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		fs.finishCompaction()
+		return err
+	}
+	_, err = tmp.Write(snapshot)
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	again := false
+	defer func() {
+		fs.compacting = false
+		fs.pending = nil
+		if again {
+			fs.startCompaction()
+		}
+	}()
+
+	size := int64(len(snapshot))
+	for _, lines := range fs.pending {
+		if err != nil {
+			break
+		}
+		var n int
+		n, err = tmp.Write(lines)
+		size += int64(n)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, fs.filePath)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	// Старый дескриптор указывает на замененный файл, следующая запись откроет новый
+	if fs.journal != nil {
+		fs.journal.Close()
+		fs.journal = nil
+	}
+	fs.journalSize = size
+	// Записи, дописанные во время компактизации, могли снова раздуть журнал
+	again = len(fs.pending) > 0 && size > fs.compactThreshold
+
+	return fs.syncDir()
+}
+
+// snapshot сериализует все записи хранилища.
+// Вызывается под блокировкой.
+func (fs *FileStorage) snapshot() ([]byte, error) {
+	var buf bytes.Buffer
+	for _, record := range fs.data {
+		line, err := encodeRecord(record)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(line)
+	}
+	return buf.Bytes(), nil
+}
+
+// rewriteFile синхронно заменяет файл текущим снимком через временный файл
+func (fs *FileStorage) rewriteFile() error {
+	snapshot, err := fs.snapshot()
+	if err != nil {
+		return err
+	}
+
+	tmpPath := fs.filePath + ".tmp"
+	if err := writeFileSync(tmpPath, snapshot); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, fs.filePath); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	fs.journalSize = int64(len(snapshot))
+	return fs.syncDir()
+}
+
+// syncDir в режиме SyncAlways сбрасывает на диск каталог журнала,
+// чтобы замена файла переименованием пережила сбой питания
+func (fs *FileStorage) syncDir() error {
+	if !fs.sync {
 		return nil
 	}
+	dir, err := os.Open(filepath.Dir(fs.filePath))
+	if err != nil {
+		return err
+	}
+	err = dir.Sync()
+	if closeErr := dir.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// writeFileSync записывает данные в файл и сбрасывает их на диск
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// finishCompaction сбрасывает состояние прерванной компактизации
+func (fs *FileStorage) finishCompaction() {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+	fs.compacting = false
+	fs.pending = nil
 }
 
 func (fs *FileStorage) loadFromFile(ctx context.Context) error {
@@ -141,71 +534,335 @@ func (fs *FileStorage) loadFromFile(ctx context.Context) error {
 	case <-ctx.Done():
 		return ctx.Err()
 	default:
-		file, err := os.OpenFile(fs.filePath, os.O_RDONLY|os.O_CREATE, 0666)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
+	}
 
-		decoder := json.NewDecoder(file)
-		for decoder.More() {
-			var data struct {
-				models.URLData
-				IsDeleted bool `json:"is_deleted"`
-			}
-			if err := decoder.Decode(&data); err != nil {
+	file, err := os.OpenFile(fs.filePath, os.O_RDONLY|os.O_CREATE, 0666)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	legacy := false
+	reader := bufio.NewReader(file)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			record, upgraded, err := decodeURLRecord(line)
+			if err != nil {
+				// Недописанная последняя строка остается после сбоя во время записи,
+				// отрезаем ее, чтобы следующая запись начиналась с новой строки
+				if errors.Is(readErr, io.EOF) {
+					if err := os.Truncate(fs.filePath, fs.journalSize); err != nil {
+						return err
+					}
+					break
+				}
 				return err
 			}
-			fs.data[data.ShortURL] = data.URLData
-			if data.IsDeleted {
-				fs.deletedURLs[data.ShortURL] = true
+			legacy = legacy || upgraded
+			if record.Purged {
+				delete(fs.data, record.ShortURL)
+			} else {
+				fs.data[record.ShortURL] = record
+			}
+		}
+		fs.journalSize += int64(len(line))
+		if readErr != nil {
+			if errors.Is(readErr, io.EOF) {
+				break
 			}
+			return readErr
 		}
+	}
 
-		return nil
+	fs.rebuildIndexes()
+
+	// Переписываем файл устаревшего формата, чтобы дальше журнал был однородным
+	if legacy {
+		return fs.rewriteFile()
 	}
+	return nil
+}
+
+// rebuildIndexes восстанавливает индекс длинных URL и списки URL пользователей в порядке создания
+func (fs *FileStorage) rebuildIndexes() {
+	fs.longURLs = make(map[string]string, len(fs.data))
+	fs.userURLs = make(map[string][]string)
+	for shortID, record := range fs.data {
+		fs.longURLs[record.OriginalURL] = shortID
+		if record.UserID != "" {
+			fs.userURLs[record.UserID] = append(fs.userURLs[record.UserID], shortID)
+		}
+	}
+	for _, shortIDs := range fs.userURLs {
+		fs.sortByCreation(shortIDs)
+	}
+}
+
+// sortByCreation упорядочивает короткие идентификаторы по времени создания записей
+func (fs *FileStorage) sortByCreation(shortIDs []string) {
+	slices.SortFunc(shortIDs, func(a, b string) int {
+		return compareRecords(fs.data[a], fs.data[b])
+	})
+}
+
+// compareRecords сравнивает записи по времени создания, затем по короткому идентификатору
+func compareRecords(a, b urlRecord) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.ShortURL, b.ShortURL)
 }
 
 // GetShortIDByLongURL вытягивает short_id URL по идентификатору
 func (fs *FileStorage) GetShortIDByLongURL(ctx context.Context, longURL string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
 	fs.mutex.RLock()
 	defer fs.mutex.RUnlock()
 
-	for shortID, urlData := range fs.data {
-		if urlData.OriginalURL == longURL {
-			return shortID, nil
-		}
-	}
-	return "", nil
+	return fs.longURLs[longURL], nil
 }
 
 // GetUserURLs отдает массив URL пользователя
 func (fs *FileStorage) GetUserURLs(ctx context.Context, userID string) ([]models.URLData, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	fs.mutex.RLock()
 	defer fs.mutex.RUnlock()
 
 	shortIDs := fs.userURLs[userID]
 	urls := make([]models.URLData, 0, len(shortIDs))
 	for _, shortID := range shortIDs {
-		if urlData, exists := fs.data[shortID]; exists {
-			urls = append(urls, urlData)
+		if record, exists := fs.data[shortID]; exists {
+			urls = append(urls, record.urlData())
 		}
 	}
 
 	return urls, nil
 }
 
-// MarkURLsAsDeleted помечает запись как удаленную
+// GetUserURLsPage отдает страницу URL пользователя
+func (fs *FileStorage) GetUserURLsPage(ctx context.Context, userID string, query models.URLQuery) (models.URLPage, error) {
+	urls, err := fs.GetUserURLs(ctx, userID)
+	if err != nil {
+		return models.URLPage{}, err
+	}
+	return paginate(urls, query)
+}
+
+// MarkURLsAsDeleted помечает запись как удаленную.
+// URL других пользователей пропускаются.
 func (fs *FileStorage) MarkURLsAsDeleted(ctx context.Context, shortIDs []string, userID string) error {
 	fs.mutex.Lock()
 	defer fs.mutex.Unlock()
 
+	now := time.Now().UTC()
+	records := make([]urlRecord, 0, len(shortIDs))
 	for _, shortID := range shortIDs {
 		// Проверяем, принадлежит ли URL данному пользователю
-		if slices.Contains(fs.userURLs[userID], shortID) {
-			fs.deletedURLs[shortID] = true
+		if record, exists := fs.data[shortID]; exists && record.UserID == userID && !record.IsDeleted {
+			record.markDeleted(now)
+			records = append(records, record)
+		}
+	}
+
+	if len(records) == 0 {
+		return ctx.Err()
+	}
+	return fs.writeRecords(ctx, records...)
+}
+
+// RestoreURLs дописывает в журнал записи со снятой пометкой удаления
+func (fs *FileStorage) RestoreURLs(ctx context.Context, shortIDs []string, userID string) ([]string, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	var restored []string
+	records := make([]urlRecord, 0, len(shortIDs))
+	for _, shortID := range shortIDs {
+		if record, exists := fs.data[shortID]; exists && record.UserID == userID && record.IsDeleted {
+			record.restore()
+			records = append(records, record)
+			restored = append(restored, shortID)
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, ctx.Err()
+	}
+	if err := fs.writeRecords(ctx, records...); err != nil {
+		return nil, err
+	}
+	return restored, nil
+}
+
+// ConsumeClick списывает переход и дописывает обновленную запись в журнал
+func (fs *FileStorage) ConsumeClick(ctx context.Context, shortID string) (bool, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	record, exists := fs.data[shortID]
+	if !exists || !record.consumeClick() {
+		return false, ctx.Err()
+	}
+	if err := fs.writeRecords(ctx, record); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UpdateURL дописывает в журнал запись с новым адресом и историей прежних
+func (fs *FileStorage) UpdateURL(ctx context.Context, shortID, userID, longURL string) (string, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	record, exists := fs.data[shortID]
+	if !exists || record.UserID != userID || record.IsDeleted {
+		return "", ErrURLNotFound
+	}
+	previous := record.OriginalURL
+	if longURL == previous {
+		return previous, ctx.Err()
+	}
+	if _, exists := fs.longURLs[longURL]; exists {
+		return "", ErrURLExists
+	}
+
+	record.changeDestination(longURL, time.Now().UTC())
+	if err := fs.writeRecords(ctx, record); err != nil {
+		return "", err
+	}
+	return previous, nil
+}
+
+// GetURLHistory возвращает версии адреса назначения URL пользователя
+func (fs *FileStorage) GetURLHistory(ctx context.Context, shortID, userID string) ([]models.URLVersion, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	record, exists := fs.data[shortID]
+	if !exists || record.UserID != userID {
+		return nil, ErrURLNotFound
+	}
+	return urlHistory(record.CreatedAt, record.OriginalURL, record.History), nil
+}
+
+// SetURLLabels дописывает в журнал запись с новыми заголовком, метками и заметкой
+func (fs *FileStorage) SetURLLabels(ctx context.Context, shortID, userID string, labels models.URLLabels) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	record, exists := fs.data[shortID]
+	if !exists || record.UserID != userID || record.IsDeleted {
+		return ErrURLNotFound
+	}
+	record.setLabels(labels)
+	return fs.writeRecords(ctx, record)
+}
+
+// SetURLRouting дописывает в журнал запись с новыми правилами выбора адреса
+func (fs *FileStorage) SetURLRouting(ctx context.Context, shortID, userID string, routing *models.Routing) error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	record, exists := fs.data[shortID]
+	if !exists || record.UserID != userID || record.IsDeleted {
+		return ErrURLNotFound
+	}
+	record.Routing = cloneRouting(routing)
+	return fs.writeRecords(ctx, record)
+}
+
+// GetUserTags считает метки URL пользователя
+func (fs *FileStorage) GetUserTags(ctx context.Context, userID string) ([]models.TagCount, error) {
+	urls, err := fs.GetUserURLs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return countTags(urls), nil
+}
+
+// PurgeDeletedURLs окончательно удаляет до limit URL, удаленных или истекших раньше before.
+// В журнал дописываются строки-надгробия, которые исчезают при компактизации.
+// Кандидаты отбираются под блокировкой чтения, блокировка записи берется
+// только на проверку и удаление найденных записей.
+func (fs *FileStorage) PurgeDeletedURLs(ctx context.Context, before time.Time, limit int) ([]models.URLData, error) {
+	for {
+		purged, found, err := fs.purgeBatch(ctx, before, limit)
+		// Всех кандидатов восстановили или продлили после отбора, отбираем заново
+		if err != nil || len(purged) > 0 || !found {
+			return purged, err
+		}
+	}
+}
+
+// purgeBatch удаляет один пакет PurgeDeletedURLs и сообщает, были ли найдены кандидаты
+func (fs *FileStorage) purgeBatch(ctx context.Context, before time.Time, limit int) ([]models.URLData, bool, error) {
+	var candidates []string
+	fs.mutex.RLock()
+	for shortID, record := range fs.data {
+		if len(candidates) == limit {
+			break
+		}
+		if record.purgeable(before) {
+			candidates = append(candidates, shortID)
+		}
+	}
+	fs.mutex.RUnlock()
+	if len(candidates) == 0 {
+		return nil, false, ctx.Err()
+	}
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	var purged []models.URLData
+	var tombstones []urlRecord
+	for _, shortID := range candidates {
+		record, exists := fs.data[shortID]
+		// Запись могли удалить, восстановить или продлить после отбора
+		if !exists || !record.purgeable(before) {
+			continue
 		}
+		purged = append(purged, record.urlData())
+		record.Purged = true
+		tombstones = append(tombstones, record)
+	}
+
+	if len(tombstones) == 0 {
+		return nil, true, ctx.Err()
+	}
+	if err := fs.writeRecords(ctx, tombstones...); err != nil {
+		return nil, true, err
 	}
+	return purged, true, nil
+}
+
+// ReleaseURL окончательно удаляет неработающий URL строкой-надгробием в журнале
+func (fs *FileStorage) ReleaseURL(ctx context.Context, shortID string, now time.Time) (models.URLData, error) {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
 
-	return fs.saveToFile(ctx)
+	record, exists := fs.data[shortID]
+	if !exists {
+		return models.URLData{}, ErrURLNotFound
+	}
+	url := record.urlData()
+	if !url.Defunct(now) {
+		return models.URLData{}, ErrURLExists
+	}
+	record.Purged = true
+	if err := fs.writeRecords(ctx, record); err != nil {
+		return models.URLData{}, err
+	}
+	return url, nil
 }
@@ -3,9 +3,11 @@ package storage
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/Eorthus/shorturl/internal/models"
 	"github.com/stretchr/testify/assert"
@@ -200,3 +202,248 @@ func splitLines(data []byte) [][]byte {
 	}
 	return lines
 }
+
+func TestFileStorage_Journal(t *testing.T) {
+	ctx := context.Background()
+	tempFile := filepath.Join(t.TempDir(), "journal.json")
+
+	t.Run("Мутации дописываются в конец файла", func(t *testing.T) {
+		store, err := NewFileStorageWithCompaction(ctx, tempFile, 0)
+		require.NoError(t, err)
+
+		require.NoError(t, store.SaveURL(ctx, "j1", "https://journal1.com", "user1"))
+		require.NoError(t, store.SaveURL(ctx, "j2", "https://journal2.com", "user1"))
+		require.NoError(t, store.MarkURLsAsDeleted(ctx, []string{"j1"}, "user1"))
+		require.NoError(t, store.Close())
+
+		content, err := os.ReadFile(tempFile)
+		require.NoError(t, err)
+		assert.Len(t, splitLines(content), 3, "Каждая мутация должна добавлять строку в журнал")
+
+		reopened, err := NewFileStorageWithCompaction(ctx, tempFile, 0)
+		require.NoError(t, err)
+
+		_, isDeleted, err := reopened.GetURL(ctx, "j1")
+		assert.NoError(t, err)
+		assert.True(t, isDeleted, "Последняя запись в журнале должна побеждать")
+	})
+
+	t.Run("Недописанная строка отбрасывается", func(t *testing.T) {
+		file, err := os.OpenFile(tempFile, os.O_WRONLY|os.O_APPEND, 0666)
+		require.NoError(t, err)
+		_, err = file.WriteString(`{"short_url":"broken","original_u`)
+		require.NoError(t, err)
+		require.NoError(t, file.Close())
+
+		store, err := NewFileStorageWithCompaction(ctx, tempFile, 0)
+		require.NoError(t, err)
+
+		require.NoError(t, store.SaveURL(ctx, "j3", "https://journal3.com", "user1"))
+		require.NoError(t, store.Close())
+
+		reopened, err := NewFileStorageWithCompaction(ctx, tempFile, 0)
+		require.NoError(t, err)
+
+		resultURL, _, err := reopened.GetURL(ctx, "j3")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://journal3.com", resultURL)
+	})
+	t.Run("Окончательное удаление переживает перезапуск", func(t *testing.T) {
+		purgeFile := filepath.Join(t.TempDir(), "purge.json")
+		store, err := NewFileStorageWithCompaction(ctx, purgeFile, 0)
+		require.NoError(t, err)
+
+		require.NoError(t, store.SaveURL(ctx, "p1", "https://purge1.com", "user1"))
+		require.NoError(t, store.SaveURL(ctx, "p2", "https://purge2.com", "user1"))
+		require.NoError(t, store.MarkURLsAsDeleted(ctx, []string{"p1"}, "user1"))
+		purged, err := store.PurgeDeletedURLs(ctx, time.Now().Add(time.Minute), 10)
+		require.NoError(t, err)
+		require.Len(t, purged, 1)
+		require.NoError(t, store.Close())
+
+		reopened, err := NewFileStorageWithCompaction(ctx, purgeFile, 0)
+		require.NoError(t, err)
+
+		resultURL, _, err := reopened.GetURL(ctx, "p1")
+		assert.NoError(t, err)
+		assert.Empty(t, resultURL, "Надгробие в журнале должно удалять запись при загрузке")
+		userURLs, err := reopened.GetUserURLs(ctx, "user1")
+		assert.NoError(t, err)
+		require.Len(t, userURLs, 1)
+		assert.Equal(t, "p2", userURLs[0].ShortURL)
+	})
+
+	t.Run("Остаток переходов переживает перезапуск", func(t *testing.T) {
+		clicksFile := filepath.Join(t.TempDir(), "clicks.json")
+		store, err := NewFileStorageWithCompaction(ctx, clicksFile, 0)
+		require.NoError(t, err)
+
+		clicks := 2
+		require.NoError(t, store.SaveURLData(ctx, []models.URLData{
+			{ShortURL: "c1", OriginalURL: "https://clicks.com", UserID: "user1", ClicksLeft: &clicks},
+		}))
+		consumed, err := store.ConsumeClick(ctx, "c1")
+		require.NoError(t, err)
+		require.True(t, consumed)
+		require.NoError(t, store.Close())
+
+		reopened, err := NewFileStorageWithCompaction(ctx, clicksFile, 0)
+		require.NoError(t, err)
+
+		url, _, err := reopened.GetURLData(ctx, "c1")
+		require.NoError(t, err)
+		require.NotNil(t, url.ClicksLeft)
+		assert.Equal(t, 1, *url.ClicksLeft)
+	})
+
+	t.Run("История адресов переживает перезапуск", func(t *testing.T) {
+		historyFile := filepath.Join(t.TempDir(), "history.json")
+		store, err := NewFileStorageWithCompaction(ctx, historyFile, 0)
+		require.NoError(t, err)
+
+		require.NoError(t, store.SaveURL(ctx, "h1", "https://old.com", "user1"))
+		_, err = store.UpdateURL(ctx, "h1", "user1", "https://new.com")
+		require.NoError(t, err)
+		require.NoError(t, store.Close())
+
+		reopened, err := NewFileStorageWithCompaction(ctx, historyFile, 0)
+		require.NoError(t, err)
+
+		shortID, err := reopened.GetShortIDByLongURL(ctx, "https://new.com")
+		require.NoError(t, err)
+		assert.Equal(t, "h1", shortID)
+		shortID, err = reopened.GetShortIDByLongURL(ctx, "https://old.com")
+		require.NoError(t, err)
+		assert.Empty(t, shortID)
+
+		versions, err := reopened.GetURLHistory(ctx, "h1", "user1")
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		assert.Equal(t, "https://old.com", versions[0].OriginalURL)
+		assert.Equal(t, "https://new.com", versions[1].OriginalURL)
+	})
+}
+
+func TestFileStorage_WriteFailure(t *testing.T) {
+	ctx := context.Background()
+	tempFile := filepath.Join(t.TempDir(), "failure.json")
+
+	_, err := NewFileStorageWithOptions(ctx, tempFile, FileOptions{Sync: "sometimes"})
+	assert.Error(t, err, "Неизвестный режим сброса отклоняется")
+
+	store, err := NewFileStorageWithOptions(ctx, tempFile, FileOptions{Sync: SyncAlways})
+	require.NoError(t, err)
+	require.NoError(t, store.SaveURL(ctx, "ok1", "https://ok1.com", "user1"))
+
+	// Дескриптор только для чтения: запись в журнал завершается ошибкой
+	readOnly, err := os.Open(tempFile)
+	require.NoError(t, err)
+	store.journal.Close()
+	store.journal = readOnly
+
+	assert.Error(t, store.SaveURL(ctx, "lost", "https://lost.com", "user1"))
+	longURL, _, err := store.GetURL(ctx, "lost")
+	require.NoError(t, err)
+	assert.Empty(t, longURL, "Неудачная запись не применяется в памяти")
+
+	require.NoError(t, store.SaveURL(ctx, "ok2", "https://ok2.com", "user1"), "Следующая запись открывает журнал заново")
+	require.NoError(t, store.Close())
+
+	reopened, err := NewFileStorageWithOptions(ctx, tempFile, FileOptions{Sync: SyncNone})
+	require.NoError(t, err)
+	for shortID, expected := range map[string]string{"ok1": "https://ok1.com", "ok2": "https://ok2.com", "lost": ""} {
+		longURL, _, err := reopened.GetURL(ctx, shortID)
+		require.NoError(t, err)
+		assert.Equal(t, expected, longURL, shortID)
+	}
+}
+
+func TestFileStorage_Compaction(t *testing.T) {
+	ctx := context.Background()
+	tempFile := filepath.Join(t.TempDir(), "compaction.json")
+
+	store, err := NewFileStorageWithCompaction(ctx, tempFile, 512)
+	require.NoError(t, err)
+
+	userID := "user1"
+	shortIDs := make([]string, 0, 50)
+	for i := 0; i < 50; i++ {
+		shortID := fmt.Sprintf("c%d", i)
+		require.NoError(t, store.SaveURL(ctx, shortID, fmt.Sprintf("https://compaction%d.com", i), userID))
+		shortIDs = append(shortIDs, shortID)
+	}
+	require.NoError(t, store.MarkURLsAsDeleted(ctx, shortIDs[:10], userID))
+	require.NoError(t, store.Close())
+
+	_, err = os.Stat(tempFile + ".tmp")
+	assert.True(t, os.IsNotExist(err), "Временный файл не должен оставаться после компактизации")
+
+	content, err := os.ReadFile(tempFile)
+	require.NoError(t, err)
+	assert.Less(t, len(splitLines(content)), 60, "Журнал должен быть компактизирован")
+
+	reopened, err := NewFileStorageWithCompaction(ctx, tempFile, 512)
+	require.NoError(t, err)
+
+	for i, shortID := range shortIDs {
+		resultURL, isDeleted, err := reopened.GetURL(ctx, shortID)
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("https://compaction%d.com", i), resultURL)
+		assert.Equal(t, i < 10, isDeleted)
+	}
+}
+
+func TestFileStorage_Format(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Владелец переживает перезапуск", func(t *testing.T) {
+		tempFile := filepath.Join(t.TempDir(), "owners.json")
+
+		store, err := NewFileStorage(ctx, tempFile)
+		require.NoError(t, err)
+		require.NoError(t, store.SaveURL(ctx, "own1", "https://owner1.com", "user1"))
+		require.NoError(t, store.SaveURL(ctx, "own2", "https://owner2.com", "user1"))
+		require.NoError(t, store.Close())
+
+		reopened, err := NewFileStorage(ctx, tempFile)
+		require.NoError(t, err)
+
+		userURLs, err := reopened.GetUserURLs(ctx, "user1")
+		assert.NoError(t, err)
+		require.Len(t, userURLs, 2)
+		assert.Equal(t, "own1", userURLs[0].ShortURL)
+		assert.Equal(t, "own2", userURLs[1].ShortURL)
+
+		require.NoError(t, reopened.MarkURLsAsDeleted(ctx, []string{"own1"}, "user1"))
+		_, isDeleted, err := reopened.GetURL(ctx, "own1")
+		assert.NoError(t, err)
+		assert.True(t, isDeleted, "Владелец должен иметь возможность удалить URL после перезапуска")
+	})
+
+	t.Run("Устаревший формат обновляется при загрузке", func(t *testing.T) {
+		tempFile := filepath.Join(t.TempDir(), "legacy.json")
+		legacy := `{"short_url":"old1","original_url":"https://legacy1.com","is_deleted":false}
+{"short_url":"old2","original_url":"https://legacy2.com","is_deleted":true}
+`
+		require.NoError(t, os.WriteFile(tempFile, []byte(legacy), 0666))
+
+		store, err := NewFileStorage(ctx, tempFile)
+		require.NoError(t, err)
+
+		resultURL, isDeleted, err := store.GetURL(ctx, "old2")
+		assert.NoError(t, err)
+		assert.True(t, isDeleted)
+		assert.Equal(t, "https://legacy2.com", resultURL)
+
+		content, err := os.ReadFile(tempFile)
+		require.NoError(t, err)
+		lines := splitLines(content)
+		assert.Len(t, lines, 2)
+		for _, line := range lines {
+			var record urlRecord
+			require.NoError(t, json.Unmarshal(line, &record))
+			assert.Equal(t, recordFormatVersion, record.Version, "Файл должен быть переписан в текущем формате")
+			assert.False(t, record.CreatedAt.IsZero(), "Дата создания должна быть заполнена")
+		}
+	})
+}
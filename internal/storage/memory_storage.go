@@ -1,29 +1,99 @@
 package storage
 
 import (
+	"cmp"
 	"context"
+	"slices"
 	"sync"
+	"time"
 
 	"github.com/Eorthus/shorturl/internal/models"
 )
 
-// MemoryStorage реализует хранение URL в памяти
+// DefaultMemoryShards количество шардов MemoryStorage по умолчанию
+const DefaultMemoryShards = 32
+
+// memoryRecord запись URL в шарде по короткому идентификатору
+type memoryRecord struct {
+	longURL   string
+	userID    string
+	isDeleted bool
+	createdAt time.Time
+	deletedAt *time.Time
+	notBefore *time.Time
+	expiresAt *time.Time
+	// clicksLeft заменяется целиком, поэтому выданные копии URLData не меняются
+	clicksLeft   *int
+	passwordHash string
+	redirectCode int
+	passthrough  bool
+	// history заменяется целиком при каждом изменении адреса
+	history []urlRevision
+	title   string
+	// tags заменяется целиком при изменении меток
+	tags []string
+	note string
+	// routing заменяется целиком при изменении правил
+	routing *models.Routing
+}
+
+// idShard хранит записи, чьи короткие идентификаторы попали в шард
+type idShard struct {
+	mutex   sync.RWMutex
+	records map[string]*memoryRecord
+}
+
+// longShard индексирует короткие идентификаторы по длинному URL
+type longShard struct {
+	mutex    sync.RWMutex
+	shortIDs map[string]string
+}
+
+// userURL элемент списка URL пользователя
+type userURL struct {
+	shortID   string
+	createdAt time.Time
+}
+
+// userShard хранит короткие идентификаторы пользователей в порядке создания
+type userShard struct {
+	mutex sync.RWMutex
+	urls  map[string][]userURL
+}
+
+// MemoryStorage реализует хранение URL в памяти.
+//
+// Данные разбиты на шарды по хешу ключа, каждый со своей блокировкой:
+// записи по короткому идентификатору, индекс длинных URL и списки
+// пользователей. Блокировки берутся в порядке «длинный URL →
+// короткий идентификатор → пользователь», внутри одного вида —
+// по возрастанию номера шарда, поэтому взаимные блокировки невозможны.
 type MemoryStorage struct {
-	shortToLong map[string]string
-	longToShort map[string]string
-	userURLs    map[string][]string
-	deletedURLs map[string]bool
-	mutex       sync.RWMutex
+	ids   []idShard
+	longs []longShard
+	users []userShard
 }
 
 // NewMemoryStorage создает новое хранилище в памяти
 func NewMemoryStorage(ctx context.Context) (*MemoryStorage, error) {
-	return &MemoryStorage{
-		shortToLong: make(map[string]string),
-		longToShort: make(map[string]string),
-		userURLs:    make(map[string][]string),
-		deletedURLs: make(map[string]bool),
-	}, nil
+	return NewMemoryStorageWithShards(ctx, DefaultMemoryShards)
+}
+
+// NewMemoryStorageWithShards создает хранилище в памяти с указанным числом шардов.
+// Значения меньше единицы заменяются на один шард.
+func NewMemoryStorageWithShards(ctx context.Context, shards int) (*MemoryStorage, error) {
+	shards = max(shards, 1)
+	ms := &MemoryStorage{
+		ids:   make([]idShard, shards),
+		longs: make([]longShard, shards),
+		users: make([]userShard, shards),
+	}
+	for i := range shards {
+		ms.ids[i].records = make(map[string]*memoryRecord)
+		ms.longs[i].shortIDs = make(map[string]string)
+		ms.users[i].urls = make(map[string][]userURL)
+	}
+	return ms, nil
 }
 
 // Close освобождает ресурсы хранилища в памяти
@@ -31,101 +101,652 @@ func (ms *MemoryStorage) Close() error {
 	return nil // No need to close memory storage
 }
 
+// shardIndex возвращает номер шарда для ключа (FNV-1a)
+func (ms *MemoryStorage) shardIndex(key string) int {
+	hash := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		hash ^= uint32(key[i])
+		hash *= 16777619
+	}
+	return int(hash % uint32(len(ms.ids)))
+}
+
 // SaveURL сохраняет URL в хранилище в памяти
 func (ms *MemoryStorage) SaveURL(ctx context.Context, shortID, longURL, userID string) error {
-	ms.mutex.Lock()
-	defer ms.mutex.Unlock()
-
-	if _, exists := ms.longToShort[longURL]; exists {
-		return ErrURLExists
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	if existingLongURL, exists := ms.shortToLong[shortID]; exists {
-		if existingLongURL != longURL {
-			return ErrURLExists
-		}
-		// Если существующий shortID уже указывает на тот же longURL, просто добавляем его к пользователю
-		ms.userURLs[userID] = append(ms.userURLs[userID], shortID)
-		return nil
+	long := &ms.longs[ms.shardIndex(longURL)]
+	long.mutex.Lock()
+	defer long.mutex.Unlock()
+
+	ids := &ms.ids[ms.shardIndex(shortID)]
+	ids.mutex.Lock()
+	defer ids.mutex.Unlock()
+
+	_, longExists := long.shortIDs[longURL]
+	_, shortExists := ids.records[shortID]
+	if err := conflictError(shortExists, longExists); err != nil {
+		return err
 	}
 
-	ms.shortToLong[shortID] = longURL
-	ms.longToShort[longURL] = shortID
-	ms.userURLs[userID] = append(ms.userURLs[userID], shortID)
+	ms.put(shortID, &memoryRecord{longURL: longURL, userID: userID, createdAt: time.Now()})
 	return nil
 }
 
+// put добавляет URL во все индексы. Вызывается под блокировками
+// шардов длинного URL и короткого идентификатора.
+func (ms *MemoryStorage) put(shortID string, record *memoryRecord) {
+	ms.ids[ms.shardIndex(shortID)].records[shortID] = record
+	ms.longs[ms.shardIndex(record.longURL)].shortIDs[record.longURL] = shortID
+
+	users := &ms.users[ms.shardIndex(record.userID)]
+	users.mutex.Lock()
+	defer users.mutex.Unlock()
+
+	// Обычно URL добавляется в конец, импортированные более старые URL встают по времени создания
+	list := users.urls[record.userID]
+	entry := userURL{shortID: shortID, createdAt: record.createdAt}
+	pos := len(list)
+	if pos > 0 && record.createdAt.Before(list[pos-1].createdAt) {
+		pos, _ = slices.BinarySearchFunc(list, entry, compareUserURLs)
+	}
+	users.urls[record.userID] = slices.Insert(list, pos, entry)
+}
+
+// compareUserURLs сравнивает элементы списка пользователя по времени создания
+func compareUserURLs(a, b userURL) int {
+	if c := a.createdAt.Compare(b.createdAt); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.shortID, b.shortID)
+}
+
 // GetURL возвращает оригинальный URL по короткому идентификатору
 func (ms *MemoryStorage) GetURL(ctx context.Context, shortID string) (string, bool, error) {
-	ms.mutex.RLock()
-	defer ms.mutex.RUnlock()
+	if err := ctx.Err(); err != nil {
+		return "", false, err
+	}
+
+	ids := &ms.ids[ms.shardIndex(shortID)]
+	ids.mutex.RLock()
+	defer ids.mutex.RUnlock()
 
-	longURL, exists := ms.shortToLong[shortID]
+	record, exists := ids.records[shortID]
 	if !exists {
 		return "", false, nil
 	}
+	return record.longURL, record.isDeleted, nil
+}
+
+// GetURLData возвращает данные URL по короткому идентификатору
+func (ms *MemoryStorage) GetURLData(ctx context.Context, shortID string) (models.URLData, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return models.URLData{}, false, err
+	}
+
+	ids := &ms.ids[ms.shardIndex(shortID)]
+	ids.mutex.RLock()
+	defer ids.mutex.RUnlock()
 
-	isDeleted := ms.deletedURLs[shortID]
-	return longURL, isDeleted, nil
+	record, exists := ids.records[shortID]
+	if !exists {
+		return models.URLData{}, false, nil
+	}
+	return record.urlData(shortID), true, nil
 }
 
 // Ping пингует db
 func (ms *MemoryStorage) Ping(ctx context.Context) error {
-	return nil // Memory storage is always available
+	return ctx.Err() // Memory storage is always available
 }
 
-// SaveURLBatch сохраняем массив URL
+// SaveURLBatch сохраняем массив URL.
+// Пакет сохраняется целиком либо не сохраняется вовсе.
 func (ms *MemoryStorage) SaveURLBatch(ctx context.Context, urls map[string]string, userID string) error {
-	ms.mutex.Lock()
-	defer ms.mutex.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	shortIDs := make([]string, 0, len(urls))
+	longURLs := make([]string, 0, len(urls))
+	for shortID, longURL := range urls {
+		shortIDs = append(shortIDs, shortID)
+		longURLs = append(longURLs, longURL)
+	}
+	unlock := ms.lockShards(shortIDs, longURLs)
+	defer unlock()
 
+	seen := make(map[string]bool, len(urls))
 	for shortID, longURL := range urls {
-		ms.shortToLong[shortID] = longURL
-		ms.longToShort[longURL] = shortID
-		ms.userURLs[userID] = append(ms.userURLs[userID], shortID)
+		_, shortExists := ms.ids[ms.shardIndex(shortID)].records[shortID]
+		_, longExists := ms.longs[ms.shardIndex(longURL)].shortIDs[longURL]
+		if err := conflictError(shortExists, longExists || seen[longURL]); err != nil {
+			return err
+		}
+		seen[longURL] = true
+	}
+
+	createdAt := time.Now()
+	for shortID, longURL := range urls {
+		ms.put(shortID, &memoryRecord{longURL: longURL, userID: userID, createdAt: createdAt})
 	}
 
 	return nil
 }
 
+// SaveURLData сохраняет URL с метаданными.
+// Пакет сохраняется целиком либо не сохраняется вовсе.
+func (ms *MemoryStorage) SaveURLData(ctx context.Context, urls []models.URLData) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	shortIDs := make([]string, 0, len(urls))
+	longURLs := make([]string, 0, len(urls))
+	for _, url := range urls {
+		shortIDs = append(shortIDs, url.ShortURL)
+		longURLs = append(longURLs, url.OriginalURL)
+	}
+	unlock := ms.lockShards(shortIDs, longURLs)
+	defer unlock()
+
+	seenShort := make(map[string]bool, len(urls))
+	seenLong := make(map[string]bool, len(urls))
+	for _, url := range urls {
+		_, shortExists := ms.ids[ms.shardIndex(url.ShortURL)].records[url.ShortURL]
+		_, longExists := ms.longs[ms.shardIndex(url.OriginalURL)].shortIDs[url.OriginalURL]
+		if err := conflictError(shortExists || seenShort[url.ShortURL], longExists || seenLong[url.OriginalURL]); err != nil {
+			return err
+		}
+		seenShort[url.ShortURL] = true
+		seenLong[url.OriginalURL] = true
+	}
+
+	now := time.Now()
+	for _, url := range urls {
+		createdAt := url.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = now
+		}
+		ms.put(url.ShortURL, &memoryRecord{
+			longURL:      url.OriginalURL,
+			userID:       url.UserID,
+			isDeleted:    url.IsDeleted,
+			createdAt:    createdAt,
+			deletedAt:    url.DeletedAt,
+			notBefore:    url.NotBefore,
+			expiresAt:    url.ExpiresAt,
+			clicksLeft:   url.ClicksLeft,
+			passwordHash: url.PasswordHash,
+			redirectCode: url.RedirectCode,
+			passthrough:  url.Passthrough,
+			title:        url.Title,
+			tags:         url.Tags,
+			note:         url.Note,
+			routing:      cloneRouting(url.Routing),
+		})
+	}
+
+	return nil
+}
+
+// lockShards блокирует на запись шарды длинных URL и коротких идентификаторов
+// по возрастанию номера и возвращает функцию снятия блокировок
+func (ms *MemoryStorage) lockShards(shortIDs, longURLs []string) func() {
+	longIdx := make([]int, 0, len(longURLs))
+	for _, longURL := range longURLs {
+		longIdx = append(longIdx, ms.shardIndex(longURL))
+	}
+	idIdx := make([]int, 0, len(shortIDs))
+	for _, shortID := range shortIDs {
+		idIdx = append(idIdx, ms.shardIndex(shortID))
+	}
+	slices.Sort(longIdx)
+	slices.Sort(idIdx)
+	longIdx = slices.Compact(longIdx)
+	idIdx = slices.Compact(idIdx)
+
+	for _, i := range longIdx {
+		ms.longs[i].mutex.Lock()
+	}
+	for _, i := range idIdx {
+		ms.ids[i].mutex.Lock()
+	}
+
+	return func() {
+		for _, i := range idIdx {
+			ms.ids[i].mutex.Unlock()
+		}
+		for _, i := range longIdx {
+			ms.longs[i].mutex.Unlock()
+		}
+	}
+}
+
 // GetShortIDByLongURL вытягивает short_id URL по идентификатору
 func (ms *MemoryStorage) GetShortIDByLongURL(ctx context.Context, longURL string) (string, error) {
-	ms.mutex.RLock()
-	defer ms.mutex.RUnlock()
-
-	shortID, exists := ms.longToShort[longURL]
-	if !exists {
-		return "", nil
+	if err := ctx.Err(); err != nil {
+		return "", err
 	}
-	return shortID, nil
+
+	long := &ms.longs[ms.shardIndex(longURL)]
+	long.mutex.RLock()
+	defer long.mutex.RUnlock()
+
+	return long.shortIDs[longURL], nil
 }
 
 // GetUserURLs отдает массив URL пользователя
 func (ms *MemoryStorage) GetUserURLs(ctx context.Context, userID string) ([]models.URLData, error) {
-	ms.mutex.RLock()
-	defer ms.mutex.RUnlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 
-	shortIDs := ms.userURLs[userID]
-	urls := make([]models.URLData, 0, len(shortIDs))
-	for _, shortID := range shortIDs {
-		if longURL, exists := ms.shortToLong[shortID]; exists {
-			urls = append(urls, models.URLData{ShortURL: shortID, OriginalURL: longURL})
+	users := &ms.users[ms.shardIndex(userID)]
+	users.mutex.RLock()
+	list := slices.Clone(users.urls[userID])
+	users.mutex.RUnlock()
+
+	urls := make([]models.URLData, 0, len(list))
+	for _, entry := range list {
+		ids := &ms.ids[ms.shardIndex(entry.shortID)]
+		ids.mutex.RLock()
+		record, exists := ids.records[entry.shortID]
+		if exists {
+			urls = append(urls, record.urlData(entry.shortID))
 		}
+		ids.mutex.RUnlock()
 	}
 
 	return urls, nil
 }
 
-// MarkURLsAsDeleted помечает запись как удаленную
+// GetUserURLsPage отдает страницу URL пользователя
+func (ms *MemoryStorage) GetUserURLsPage(ctx context.Context, userID string, query models.URLQuery) (models.URLPage, error) {
+	urls, err := ms.GetUserURLs(ctx, userID)
+	if err != nil {
+		return models.URLPage{}, err
+	}
+	return paginate(urls, query)
+}
+
+// urlData преобразует запись в модель URL. Вызывается под блокировкой шарда.
+func (r *memoryRecord) urlData(shortID string) models.URLData {
+	return models.URLData{
+		ShortURL:     shortID,
+		OriginalURL:  r.longURL,
+		UserID:       r.userID,
+		IsDeleted:    r.isDeleted,
+		CreatedAt:    r.createdAt,
+		DeletedAt:    r.deletedAt,
+		NotBefore:    r.notBefore,
+		ExpiresAt:    r.expiresAt,
+		ClicksLeft:   r.clicksLeft,
+		PasswordHash: r.passwordHash,
+		RedirectCode: r.redirectCode,
+		Passthrough:  r.passthrough,
+		Title:        r.title,
+		Tags:         r.tags,
+		Note:         r.note,
+		Routing:      r.routing,
+	}
+}
+
+// IterateURLs обходит все записи в порядке создания.
+// fn вызывается вне блокировок над снимком данных.
+func (ms *MemoryStorage) IterateURLs(ctx context.Context, fn func(url models.URLData) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var urls []models.URLData
+	for i := range ms.ids {
+		ids := &ms.ids[i]
+		ids.mutex.RLock()
+		for shortID, record := range ids.records {
+			urls = append(urls, record.urlData(shortID))
+		}
+		ids.mutex.RUnlock()
+	}
+
+	slices.SortFunc(urls, func(a, b models.URLData) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.ShortURL, b.ShortURL)
+	})
+	for _, url := range urls {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(url); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarkURLsAsDeleted помечает запись как удаленную.
+// URL других пользователей пропускаются.
 func (ms *MemoryStorage) MarkURLsAsDeleted(ctx context.Context, shortIDs []string, userID string) error {
-	ms.mutex.Lock()
-	defer ms.mutex.Unlock()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, shortID := range shortIDs {
+		ids := &ms.ids[ms.shardIndex(shortID)]
+		ids.mutex.Lock()
+		if record, exists := ids.records[shortID]; exists && record.userID == userID && !record.isDeleted {
+			record.isDeleted = true
+			record.deletedAt = &now
+		}
+		ids.mutex.Unlock()
+	}
 
+	return nil
+}
+
+// RestoreURLs снимает пометку удаления с URL пользователя
+func (ms *MemoryStorage) RestoreURLs(ctx context.Context, shortIDs []string, userID string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var restored []string
 	for _, shortID := range shortIDs {
-		if _, exists := ms.shortToLong[shortID]; exists {
-			ms.deletedURLs[shortID] = true
+		ids := &ms.ids[ms.shardIndex(shortID)]
+		ids.mutex.Lock()
+		if record, exists := ids.records[shortID]; exists && record.userID == userID && record.isDeleted {
+			record.isDeleted = false
+			record.deletedAt = nil
+			restored = append(restored, shortID)
+		}
+		ids.mutex.Unlock()
+	}
+
+	return restored, nil
+}
+
+// UpdateURL меняет адрес назначения под блокировками шардов прежнего
+// и нового длинного URL и короткого идентификатора
+func (ms *MemoryStorage) UpdateURL(ctx context.Context, shortID, userID, longURL string) (string, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+
+		// Прежний адрес нужен, чтобы заблокировать его шард до изменения записи
+		ids := &ms.ids[ms.shardIndex(shortID)]
+		ids.mutex.RLock()
+		record, exists := ids.records[shortID]
+		var previous string
+		if exists {
+			previous = record.longURL
 		}
+		ids.mutex.RUnlock()
+		if !exists || record.userID != userID {
+			return "", ErrURLNotFound
+		}
+
+		previous, retry, err := ms.updateURL(shortID, userID, previous, longURL)
+		if !retry {
+			return previous, err
+		}
+	}
+}
+
+// updateURL меняет адрес назначения, если он все еще равен previous.
+// Второе значение true означает, что адрес успел измениться и попытку нужно повторить.
+func (ms *MemoryStorage) updateURL(shortID, userID, previous, longURL string) (string, bool, error) {
+	unlock := ms.lockShards([]string{shortID}, []string{previous, longURL})
+	defer unlock()
+
+	record, exists := ms.ids[ms.shardIndex(shortID)].records[shortID]
+	if !exists || record.userID != userID || record.isDeleted {
+		return "", false, ErrURLNotFound
+	}
+	if record.longURL != previous {
+		return "", true, nil
+	}
+	if longURL == previous {
+		return previous, false, nil
+	}
+	if _, exists := ms.longs[ms.shardIndex(longURL)].shortIDs[longURL]; exists {
+		return "", false, ErrURLExists
+	}
+
+	long := &ms.longs[ms.shardIndex(previous)]
+	if long.shortIDs[previous] == shortID {
+		delete(long.shortIDs, previous)
+	}
+	ms.longs[ms.shardIndex(longURL)].shortIDs[longURL] = shortID
+	record.history = append(slices.Clip(record.history), urlRevision{OriginalURL: previous, ReplacedAt: time.Now()})
+	record.longURL = longURL
+	return previous, false, nil
+}
+
+// GetURLHistory возвращает версии адреса назначения URL пользователя
+func (ms *MemoryStorage) GetURLHistory(ctx context.Context, shortID, userID string) ([]models.URLVersion, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	ids := &ms.ids[ms.shardIndex(shortID)]
+	ids.mutex.RLock()
+	defer ids.mutex.RUnlock()
+
+	record, exists := ids.records[shortID]
+	if !exists || record.userID != userID {
+		return nil, ErrURLNotFound
 	}
+	return urlHistory(record.createdAt, record.longURL, record.history), nil
+}
+
+// SetURLLabels заменяет заголовок, метки и заметку под блокировкой шарда записи
+func (ms *MemoryStorage) SetURLLabels(ctx context.Context, shortID, userID string, labels models.URLLabels) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ids := &ms.ids[ms.shardIndex(shortID)]
+	ids.mutex.Lock()
+	defer ids.mutex.Unlock()
+
+	record, exists := ids.records[shortID]
+	if !exists || record.userID != userID || record.isDeleted {
+		return ErrURLNotFound
+	}
+	record.title = labels.Title
+	record.tags = slices.Clone(labels.Tags)
+	record.note = labels.Note
+	return nil
+}
+
+// SetURLRouting заменяет правила выбора адреса под блокировкой шарда записи
+func (ms *MemoryStorage) SetURLRouting(ctx context.Context, shortID, userID string, routing *models.Routing) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ids := &ms.ids[ms.shardIndex(shortID)]
+	ids.mutex.Lock()
+	defer ids.mutex.Unlock()
 
+	record, exists := ids.records[shortID]
+	if !exists || record.userID != userID || record.isDeleted {
+		return ErrURLNotFound
+	}
+	record.routing = cloneRouting(routing)
 	return nil
 }
+
+// GetUserTags считает метки URL пользователя
+func (ms *MemoryStorage) GetUserTags(ctx context.Context, userID string) ([]models.TagCount, error) {
+	urls, err := ms.GetUserURLs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return countTags(urls), nil
+}
+
+// ConsumeClick списывает переход под блокировкой шарда записи
+func (ms *MemoryStorage) ConsumeClick(ctx context.Context, shortID string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	ids := &ms.ids[ms.shardIndex(shortID)]
+	ids.mutex.Lock()
+	defer ids.mutex.Unlock()
+
+	record, exists := ids.records[shortID]
+	if !exists || record.clicksLeft == nil || *record.clicksLeft <= 0 {
+		return false, nil
+	}
+	left := *record.clicksLeft - 1
+	record.clicksLeft = &left
+	return true, nil
+}
+
+// PurgeDeletedURLs окончательно удаляет до limit URL, удаленных или истекших раньше before.
+// Кандидаты отбираются под блокировками чтения отдельных шардов, затем
+// блокируются только их шарды и проверяются повторно.
+func (ms *MemoryStorage) PurgeDeletedURLs(ctx context.Context, before time.Time, limit int) ([]models.URLData, error) {
+	for {
+		purged, found, err := ms.purgeBatch(ctx, before, limit)
+		// Всех кандидатов восстановили или продлили после отбора, отбираем заново
+		if err != nil || len(purged) > 0 || !found {
+			return purged, err
+		}
+	}
+}
+
+// purgeBatch удаляет один пакет PurgeDeletedURLs и сообщает, были ли найдены кандидаты
+func (ms *MemoryStorage) purgeBatch(ctx context.Context, before time.Time, limit int) ([]models.URLData, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+
+	var shortIDs, longURLs []string
+	for i := range ms.ids {
+		ids := &ms.ids[i]
+		ids.mutex.RLock()
+		for shortID, record := range ids.records {
+			if len(shortIDs) == limit {
+				break
+			}
+			if isPurgeable(record.urlData(shortID), before) {
+				shortIDs = append(shortIDs, shortID)
+				longURLs = append(longURLs, record.longURL)
+			}
+		}
+		ids.mutex.RUnlock()
+	}
+	found := len(shortIDs) > 0
+
+	var purged []models.URLData
+	for len(shortIDs) > 0 {
+		if err := ctx.Err(); err != nil {
+			return purged, found, err
+		}
+		batch, stale := ms.purgeURLs(shortIDs, longURLs, func(url models.URLData) bool {
+			return isPurgeable(url, before)
+		})
+		purged = append(purged, batch...)
+		// Адрес назначения изменился после чтения, повторяем с текущим адресом
+		shortIDs, longURLs = ms.currentLongURLs(stale)
+	}
+
+	return purged, found, nil
+}
+
+// purgeURLs удаляет записи, подходящие под purgeable, под блокировками шардов
+// их коротких и длинных URL. Записи, чей адрес назначения успел измениться
+// после чтения longURLs, не удаляются и возвращаются вторым значением
+// для повторной попытки.
+func (ms *MemoryStorage) purgeURLs(shortIDs, longURLs []string, purgeable func(models.URLData) bool) ([]models.URLData, []string) {
+	unlock := ms.lockShards(shortIDs, longURLs)
+	defer unlock()
+
+	var stale []string
+	purged := make([]models.URLData, 0, len(shortIDs))
+	for i, shortID := range shortIDs {
+		ids := &ms.ids[ms.shardIndex(shortID)]
+		record, exists := ids.records[shortID]
+		if !exists || !purgeable(record.urlData(shortID)) {
+			continue
+		}
+		if record.longURL != longURLs[i] {
+			// Шард нового адреса не заблокирован
+			stale = append(stale, shortID)
+			continue
+		}
+		delete(ids.records, shortID)
+		long := &ms.longs[ms.shardIndex(record.longURL)]
+		if long.shortIDs[record.longURL] == shortID {
+			delete(long.shortIDs, record.longURL)
+		}
+		ms.removeUserURL(record.userID, shortID)
+		purged = append(purged, record.urlData(shortID))
+	}
+
+	return purged, stale
+}
+
+// ReleaseURL окончательно удаляет неработающий URL
+func (ms *MemoryStorage) ReleaseURL(ctx context.Context, shortID string, now time.Time) (models.URLData, error) {
+	if err := ctx.Err(); err != nil {
+		return models.URLData{}, err
+	}
+
+	shortIDs, longURLs := ms.currentLongURLs([]string{shortID})
+	for len(shortIDs) > 0 {
+		var live bool
+		released, stale := ms.purgeURLs(shortIDs, longURLs, func(url models.URLData) bool {
+			live = !url.Defunct(now)
+			return !live
+		})
+		if len(released) > 0 {
+			return released[0], nil
+		}
+		if live {
+			return models.URLData{}, ErrURLExists
+		}
+		shortIDs, longURLs = ms.currentLongURLs(stale)
+	}
+	return models.URLData{}, ErrURLNotFound
+}
+
+// currentLongURLs читает текущие адреса назначения записей, исчезнувшие записи пропускаются
+func (ms *MemoryStorage) currentLongURLs(shortIDs []string) ([]string, []string) {
+	var found, longURLs []string
+	for _, shortID := range shortIDs {
+		ids := &ms.ids[ms.shardIndex(shortID)]
+		ids.mutex.RLock()
+		if record, exists := ids.records[shortID]; exists {
+			found = append(found, shortID)
+			longURLs = append(longURLs, record.longURL)
+		}
+		ids.mutex.RUnlock()
+	}
+	return found, longURLs
+}
+
+// removeUserURL удаляет URL из списка пользователя
+func (ms *MemoryStorage) removeUserURL(userID, shortID string) {
+	users := &ms.users[ms.shardIndex(userID)]
+	users.mutex.Lock()
+	defer users.mutex.Unlock()
+
+	list := slices.DeleteFunc(users.urls[userID], func(entry userURL) bool {
+		return entry.shortID == shortID
+	})
+	if len(list) == 0 {
+		delete(users.urls, userID)
+		return
+	}
+	users.urls[userID] = list
+}
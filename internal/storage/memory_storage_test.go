@@ -112,7 +112,7 @@ func TestMemoryStorage(t *testing.T) {
 		assert.NoError(t, err)
 
 		err = store.SaveURL(context.Background(), shortID, longURL2, userID)
-		assert.Equal(t, ErrURLExists, err)
+		assert.Equal(t, ErrShortIDExists, err)
 
 		resultURL, isDeleted, err := store.GetURL(context.Background(), shortID)
 		assert.NoError(t, err)
@@ -193,3 +193,75 @@ func TestMemoryStorage(t *testing.T) {
 		assert.Equal(t, urls[1].longURL, resultURL)
 	})
 }
+
+func TestMemoryStorage_Shards(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Конкурентные дубликаты", func(t *testing.T) {
+		store, err := NewMemoryStorageWithShards(ctx, 8)
+		require.NoError(t, err)
+
+		const workers = 50
+		results := make(chan error, workers)
+		for i := 0; i < workers; i++ {
+			go func(id int) {
+				results <- store.SaveURL(ctx, fmt.Sprintf("race%d", id), "https://race.com", "user1")
+			}(i)
+		}
+
+		saved := 0
+		for i := 0; i < workers; i++ {
+			if err := <-results; err == nil {
+				saved++
+			} else {
+				assert.ErrorIs(t, err, ErrURLExists)
+			}
+		}
+		assert.Equal(t, 1, saved, "Длинный URL сохраняется ровно один раз")
+
+		urls, err := store.GetUserURLs(ctx, "user1")
+		assert.NoError(t, err)
+		assert.Len(t, urls, 1)
+	})
+
+	t.Run("Пересекающиеся пакеты", func(t *testing.T) {
+		store, err := NewMemoryStorageWithShards(ctx, 4)
+		require.NoError(t, err)
+
+		const workers = 20
+		done := make(chan error, workers)
+		for i := 0; i < workers; i++ {
+			go func(id int) {
+				batch := make(map[string]string)
+				for j := 0; j < 10; j++ {
+					batch[fmt.Sprintf("b%d_%d", id, j)] = fmt.Sprintf("https://b%d.com", (id+j)%workers)
+				}
+				done <- store.SaveURLBatch(ctx, batch, "user1")
+			}(i)
+		}
+		for i := 0; i < workers; i++ {
+			err := <-done
+			if err != nil {
+				assert.ErrorIs(t, err, ErrURLExists)
+			}
+		}
+
+		urls, err := store.GetUserURLs(ctx, "user1")
+		assert.NoError(t, err)
+		for _, url := range urls {
+			shortID, err := store.GetShortIDByLongURL(ctx, url.OriginalURL)
+			assert.NoError(t, err)
+			assert.Equal(t, url.ShortURL, shortID, "Индексы шардов должны быть согласованы")
+		}
+	})
+
+	t.Run("Некорректное число шардов", func(t *testing.T) {
+		store, err := NewMemoryStorageWithShards(ctx, 0)
+		require.NoError(t, err)
+		require.NoError(t, store.SaveURL(ctx, "one", "https://one.com", "user1"))
+
+		longURL, _, err := store.GetURL(ctx, "one")
+		assert.NoError(t, err)
+		assert.Equal(t, "https://one.com", longURL)
+	})
+}
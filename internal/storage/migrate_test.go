@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	t.Run("Встроенные миграции", func(t *testing.T) {
+		migrations, err := loadMigrations(migrationFiles, "migrations")
+		require.NoError(t, err)
+		require.NotEmpty(t, migrations)
+
+		for i, m := range migrations {
+			assert.Equal(t, i+1, m.Version, "Версии миграций должны идти подряд")
+			assert.NotEmpty(t, m.Up)
+			assert.NotEmpty(t, m.Down)
+		}
+	})
+
+	t.Run("Сортировка по версии", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"m/0010_second.up.sql":  {Data: []byte("SELECT 10")},
+			"m/0002_first.up.sql":   {Data: []byte("SELECT 2")},
+			"m/0002_first.down.sql": {Data: []byte("SELECT -2")},
+		}
+
+		migrations, err := loadMigrations(fsys, "m")
+		require.NoError(t, err)
+		require.Len(t, migrations, 2)
+		assert.Equal(t, Migration{Version: 2, Name: "first", Up: "SELECT 2", Down: "SELECT -2"}, migrations[0])
+		assert.Equal(t, 10, migrations[1].Version)
+		assert.Empty(t, migrations[1].Down)
+	})
+
+	t.Run("Некорректное имя файла", func(t *testing.T) {
+		fsys := fstest.MapFS{
+			"m/first.up.sql": {Data: []byte("SELECT 1")},
+		}
+
+		_, err := loadMigrations(fsys, "m")
+		assert.Error(t, err)
+	})
+}
+
+func setupMigrator(t *testing.T) (*Migrator, sqlmock.Sqlmock) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	return &Migrator{
+		db: db,
+		migrations: []Migration{
+			{Version: 1, Name: "create", Up: "CREATE TABLE one", Down: "DROP TABLE one"},
+			{Version: 2, Name: "index", Up: "CREATE INDEX two", Down: "DROP INDEX two"},
+		},
+	}, mock
+}
+
+func TestMigrator_Up(t *testing.T) {
+	migrator, mock := setupMigrator(t)
+
+	mock.ExpectExec("SELECT pg_advisory_lock").WithArgs(migrationLockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE INDEX two").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").WithArgs(2, "index").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("SELECT pg_advisory_unlock").WithArgs(migrationLockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	applied, err := migrator.Up(context.Background())
+	assert.NoError(t, err)
+	require.Len(t, applied, 1)
+	assert.Equal(t, 2, applied[0].Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Down(t *testing.T) {
+	migrator, mock := setupMigrator(t)
+
+	mock.ExpectExec("SELECT pg_advisory_lock").WithArgs(migrationLockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()).AddRow(2, time.Now()))
+	mock.ExpectBegin()
+	mock.ExpectExec("DROP INDEX two").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM schema_migrations").WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("SELECT pg_advisory_unlock").WithArgs(migrationLockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	reverted, err := migrator.Down(context.Background())
+	assert.NoError(t, err)
+	require.NotNil(t, reverted)
+	assert.Equal(t, 2, reverted.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Status(t *testing.T) {
+	migrator, mock := setupMigrator(t)
+
+	appliedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT version, applied_at FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, appliedAt))
+
+	statuses, err := migrator.Status(context.Background())
+	assert.NoError(t, err)
+	require.Len(t, statuses, 2)
+	require.NotNil(t, statuses[0].AppliedAt)
+	assert.Equal(t, appliedAt, *statuses[0].AppliedAt)
+	assert.Nil(t, statuses[1].AppliedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
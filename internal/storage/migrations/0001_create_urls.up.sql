@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS urls (
+	id SERIAL PRIMARY KEY,
+	short_id VARCHAR(10) UNIQUE NOT NULL,
+	user_id TEXT,
+	original_url TEXT NOT NULL,
+	is_deleted BOOLEAN DEFAULT FALSE,
+	created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_original_url ON urls(original_url);
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_urls_user_id ON urls(user_id);
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_urls_user_created ON urls(user_id, created_at, short_id);
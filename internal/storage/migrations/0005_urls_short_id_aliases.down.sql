@@ -0,0 +1,2 @@
+-- Откат невозможен, пока в таблице есть псевдонимы длиннее 10 символов
+ALTER TABLE urls ALTER COLUMN short_id TYPE VARCHAR(10);
@@ -0,0 +1 @@
+ALTER TABLE urls ALTER COLUMN short_id TYPE VARCHAR(64);
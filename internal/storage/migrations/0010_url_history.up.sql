@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS url_history (
+	id SERIAL PRIMARY KEY,
+	short_id VARCHAR(64) NOT NULL REFERENCES urls(short_id) ON DELETE CASCADE,
+	original_url TEXT NOT NULL,
+	replaced_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_url_history_short_id ON url_history(short_id);
@@ -0,0 +1,3 @@
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS tags TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS note TEXT;
+CREATE INDEX IF NOT EXISTS idx_urls_tags ON urls USING GIN (tags);
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"cmp"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Eorthus/shorturl/internal/models"
+)
+
+// ErrInvalidCursor возвращается для поврежденного курсора или курсора другой сортировки
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// pageCursor позиция последнего URL страницы.
+// URL упорядочены по времени создания, при равенстве — по короткому идентификатору.
+type pageCursor struct {
+	createdAt time.Time
+	shortID   string
+}
+
+// encodeCursor кодирует позицию URL в непрозрачный курсор
+func encodeCursor(url models.URLData, desc bool) string {
+	raw := cursorDirection(desc) + ":" + strconv.FormatInt(url.CreatedAt.UnixNano(), 10) + ":" + url.ShortURL
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// cursorDirection метка направления сортировки в курсоре
+func cursorDirection(desc bool) string {
+	if desc {
+		return "d"
+	}
+	return "a"
+}
+
+// decodeCursor разбирает курсор и проверяет, что он выдан для той же сортировки
+func decodeCursor(cursor string, desc bool) (*pageCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), ":", 3)
+	if len(parts) != 3 || parts[0] != cursorDirection(desc) {
+		return nil, ErrInvalidCursor
+	}
+	nanos, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &pageCursor{createdAt: time.Unix(0, nanos).UTC(), shortID: parts[2]}, nil
+}
+
+// compareURLs сравнивает URL по времени создания, затем по короткому идентификатору
+func compareURLs(a, b models.URLData) int {
+	if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.ShortURL, b.ShortURL)
+}
+
+// matchesQuery проверяет фильтры выборки
+func matchesQuery(url models.URLData, query models.URLQuery) bool {
+	if query.Deleted != nil && url.IsDeleted != *query.Deleted {
+		return false
+	}
+	if query.Search != "" && !strings.Contains(strings.ToLower(url.OriginalURL), strings.ToLower(query.Search)) {
+		return false
+	}
+	if query.Tag != "" && !slices.Contains(url.Tags, query.Tag) {
+		return false
+	}
+	return true
+}
+
+// countTags считает метки неудаленных URL.
+// Используется хранилищами, которые держат список пользователя целиком.
+func countTags(urls []models.URLData) []models.TagCount {
+	counts := make(map[string]int)
+	for _, url := range urls {
+		if url.IsDeleted {
+			continue
+		}
+		for _, tag := range url.Tags {
+			counts[tag]++
+		}
+	}
+
+	tags := make([]models.TagCount, 0, len(counts))
+	for tag, count := range counts {
+		tags = append(tags, models.TagCount{Tag: tag, Count: count})
+	}
+	slices.SortFunc(tags, func(a, b models.TagCount) int {
+		if c := cmp.Compare(b.Count, a.Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Tag, b.Tag)
+	})
+	return tags
+}
+
+// paginate выбирает страницу из всех URL пользователя.
+// Используется хранилищами, которые держат список пользователя целиком.
+func paginate(urls []models.URLData, query models.URLQuery) (models.URLPage, error) {
+	desc := query.Sort == models.SortCreatedDesc
+	cursor, err := decodeCursor(query.Cursor, desc)
+	if err != nil {
+		return models.URLPage{}, err
+	}
+
+	slices.SortFunc(urls, func(a, b models.URLData) int {
+		if desc {
+			return compareURLs(b, a)
+		}
+		return compareURLs(a, b)
+	})
+
+	page := models.URLPage{URLs: make([]models.URLData, 0, min(query.Limit, len(urls)))}
+	for _, url := range urls {
+		if cursor != nil {
+			c := compareURLs(url, models.URLData{CreatedAt: cursor.createdAt, ShortURL: cursor.shortID})
+			if (!desc && c <= 0) || (desc && c >= 0) {
+				continue
+			}
+		}
+		if !matchesQuery(url, query) {
+			continue
+		}
+		if len(page.URLs) == query.Limit {
+			page.NextCursor = encodeCursor(page.URLs[len(page.URLs)-1], desc)
+			break
+		}
+		page.URLs = append(page.URLs, url)
+	}
+
+	return page, nil
+}
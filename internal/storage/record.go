@@ -0,0 +1,225 @@
+package storage
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/Eorthus/shorturl/internal/models"
+)
+
+// recordFormatVersion текущая версия сериализованной записи URL.
+// Записи без поля "v" относятся к устаревшему формату без владельца и даты создания.
+const recordFormatVersion = 2
+
+// urlRecord описывает запись URL в файловом и встроенном хранилищах
+type urlRecord struct {
+	Version     int        `json:"v"`
+	ShortURL    string     `json:"short_url"`
+	OriginalURL string     `json:"original_url"`
+	UserID      string     `json:"user_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	IsDeleted   bool       `json:"is_deleted"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	NotBefore   *time.Time `json:"not_before,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	ClicksLeft  *int       `json:"clicks_left,omitempty"`
+	// PasswordHash хеш пароля защищенной ссылки
+	PasswordHash string `json:"password_hash,omitempty"`
+	RedirectCode int    `json:"redirect_code,omitempty"`
+	Passthrough  bool   `json:"passthrough,omitempty"`
+	// Title заголовок, Tags метки и Note заметка пользователя
+	Title string   `json:"title,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+	Note  string   `json:"note,omitempty"`
+	// Routing правила выбора адреса назначения
+	Routing *models.Routing `json:"routing,omitempty"`
+	// History прежние адреса назначения в порядке замены
+	History []urlRevision `json:"history,omitempty"`
+	// Purged помечает строку журнала, окончательно удаляющую запись
+	Purged bool `json:"purged,omitempty"`
+}
+
+// newURLRecord создает запись для нового URL
+func newURLRecord(shortID, longURL, userID string) urlRecord {
+	return urlRecord{
+		Version:     recordFormatVersion,
+		ShortURL:    shortID,
+		OriginalURL: longURL,
+		UserID:      userID,
+		CreatedAt:   time.Now().UTC(),
+	}
+}
+
+// newURLRecordFromData создает запись с сохранением владельца, признака удаления,
+// времени создания, срока действия, оставшихся переходов, пароля, способа перенаправления,
+// заголовка, меток, заметки и правил выбора адреса
+func newURLRecordFromData(url models.URLData) urlRecord {
+	record := urlRecord{
+		Version:      recordFormatVersion,
+		ShortURL:     url.ShortURL,
+		OriginalURL:  url.OriginalURL,
+		UserID:       url.UserID,
+		CreatedAt:    url.CreatedAt.UTC(),
+		IsDeleted:    url.IsDeleted,
+		DeletedAt:    url.DeletedAt,
+		NotBefore:    url.NotBefore,
+		ExpiresAt:    url.ExpiresAt,
+		ClicksLeft:   url.ClicksLeft,
+		PasswordHash: url.PasswordHash,
+		RedirectCode: url.RedirectCode,
+		Passthrough:  url.Passthrough,
+		Title:        url.Title,
+		Tags:         url.Tags,
+		Note:         url.Note,
+		Routing:      cloneRouting(url.Routing),
+	}
+	if url.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now().UTC()
+	}
+	return record
+}
+
+// upgradeURLRecord приводит запись устаревшего формата к текущей версии.
+// Владелец в таких записях не сохранялся, датой создания считается момент обновления.
+func upgradeURLRecord(record urlRecord) urlRecord {
+	record.Version = recordFormatVersion
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now().UTC()
+	}
+	return record
+}
+
+// urlData преобразует запись в модель URL
+func (r urlRecord) urlData() models.URLData {
+	return models.URLData{
+		ShortURL:     r.ShortURL,
+		OriginalURL:  r.OriginalURL,
+		UserID:       r.UserID,
+		IsDeleted:    r.IsDeleted,
+		CreatedAt:    r.CreatedAt,
+		DeletedAt:    r.DeletedAt,
+		NotBefore:    r.NotBefore,
+		ExpiresAt:    r.ExpiresAt,
+		ClicksLeft:   r.ClicksLeft,
+		PasswordHash: r.PasswordHash,
+		RedirectCode: r.RedirectCode,
+		Passthrough:  r.Passthrough,
+		Title:        r.Title,
+		Tags:         r.Tags,
+		Note:         r.Note,
+		Routing:      r.Routing,
+	}
+}
+
+// urlRevision прежний адрес назначения ссылки
+type urlRevision struct {
+	OriginalURL string    `json:"original_url"`
+	ReplacedAt  time.Time `json:"replaced_at"`
+}
+
+// changeDestination заменяет адрес назначения, сохраняя прежний в истории.
+// История копируется, чтобы не менять ранее выданные копии записи.
+func (r *urlRecord) changeDestination(longURL string, now time.Time) {
+	r.History = append(slices.Clip(r.History), urlRevision{OriginalURL: r.OriginalURL, ReplacedAt: now})
+	r.OriginalURL = longURL
+}
+
+// urlHistory собирает версии адреса назначения от первой до текущей
+func urlHistory(createdAt time.Time, current string, revisions []urlRevision) []models.URLVersion {
+	versions := make([]models.URLVersion, 0, len(revisions)+1)
+	activeFrom := createdAt
+	for i, revision := range revisions {
+		replacedAt := revision.ReplacedAt
+		versions = append(versions, models.URLVersion{
+			Version:     i + 1,
+			OriginalURL: revision.OriginalURL,
+			ActiveFrom:  activeFrom,
+			ActiveUntil: &replacedAt,
+		})
+		activeFrom = replacedAt
+	}
+	return append(versions, models.URLVersion{
+		Version:     len(revisions) + 1,
+		OriginalURL: current,
+		ActiveFrom:  activeFrom,
+	})
+}
+
+// setLabels заменяет заголовок, метки и заметку.
+// Метки копируются, чтобы запись не делила срез с вызывающим.
+func (r *urlRecord) setLabels(labels models.URLLabels) {
+	r.Title = labels.Title
+	r.Tags = slices.Clone(labels.Tags)
+	r.Note = labels.Note
+}
+
+// cloneRouting копирует правила, чтобы хранилище не делило их с вызывающим.
+// Сохраненные правила не изменяются, а заменяются целиком.
+func cloneRouting(routing *models.Routing) *models.Routing {
+	if routing == nil {
+		return nil
+	}
+	return &models.Routing{
+		Rules:    slices.Clone(routing.Rules),
+		Variants: slices.Clone(routing.Variants),
+	}
+}
+
+// markDeleted помечает запись удаленной, сохраняя время первого удаления
+func (r *urlRecord) markDeleted(now time.Time) {
+	r.IsDeleted = true
+	if r.DeletedAt == nil {
+		r.DeletedAt = &now
+	}
+}
+
+// restore снимает с записи пометку удаления
+func (r *urlRecord) restore() {
+	r.IsDeleted = false
+	r.DeletedAt = nil
+}
+
+// consumeClick списывает переход у записи с ограничением числа переходов.
+// Счетчик заменяется новым, чтобы не менять ранее выданные копии.
+func (r *urlRecord) consumeClick() bool {
+	if r.ClicksLeft == nil || *r.ClicksLeft <= 0 {
+		return false
+	}
+	left := *r.ClicksLeft - 1
+	r.ClicksLeft = &left
+	return true
+}
+
+// purgeable проверяет, удалена ли запись или истек ли ее срок раньше before
+func (r urlRecord) purgeable(before time.Time) bool {
+	return isPurgeable(r.urlData(), before)
+}
+
+// isPurgeable общее правило отбора записей для окончательного удаления:
+// URL удален или истек раньше before. Для записей, удаленных до появления
+// времени удаления, используется время создания.
+func isPurgeable(url models.URLData, before time.Time) bool {
+	if url.ExpiresAt != nil && url.ExpiresAt.Before(before) {
+		return true
+	}
+	if !url.IsDeleted {
+		return false
+	}
+	if url.DeletedAt != nil {
+		return url.DeletedAt.Before(before)
+	}
+	return url.CreatedAt.Before(before)
+}
+
+// decodeURLRecord разбирает сериализованную запись и обновляет устаревший формат
+func decodeURLRecord(data []byte) (urlRecord, bool, error) {
+	var record urlRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return urlRecord{}, false, err
+	}
+	if record.Version < recordFormatVersion {
+		return upgradeURLRecord(record), true, nil
+	}
+	return record, false, nil
+}
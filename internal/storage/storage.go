@@ -4,6 +4,9 @@
 //   - MemoryStorage: хранение в памяти
 //   - FileStorage: файловое хранение
 //   - DatabaseStorage: хранение в PostgreSQL
+//   - BoltStorage: хранение во встроенной базе данных bbolt
+//
+// CachedStorage добавляет к любому из них кэш чтения.
 //
 // Для выбора типа хранилища используйте функцию InitStorage,
 // которая учитывает конфигурацию приложения.
@@ -11,54 +14,177 @@ package storage
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/Eorthus/shorturl/internal/config"
 	"github.com/Eorthus/shorturl/internal/models"
 )
 
+// Ошибки конфликтов при сохранении
+var (
+	// ErrURLExists возникает при попытке сохранить уже сохраненный длинный URL
+	ErrURLExists = errors.New("URL already exists")
+	// ErrShortIDExists возникает при попытке занять уже используемый короткий идентификатор
+	ErrShortIDExists = errors.New("short ID already exists")
+	// ErrURLNotFound возникает при изменении URL, который не найден или принадлежит другому пользователю
+	ErrURLNotFound = errors.New("URL not found")
+)
+
+// conflictError возвращает ошибку конфликта; длинный URL проверяется первым,
+// чтобы повторное сокращение того же URL можно было отличить от коллизии
+func conflictError(shortExists, longExists bool) error {
+	if longExists {
+		return ErrURLExists
+	}
+	if shortExists {
+		return ErrShortIDExists
+	}
+	return nil
+}
+
 // Storage определяет интерфейс для хранения и управления сокращенными URL.
 //
 // Интерфейс поддерживает следующие операции:
 //   - Сохранение URL
 //   - Получение оригинального URL по короткому идентификатору
+//   - Получение всех данных URL для перенаправления
 //   - Проверка доступности хранилища
 //   - Пакетное сохранение URL
 //   - Получение URL пользователя
 //   - Маркировка URL как удаленных
+//   - Изменение адреса назначения с сохранением истории
+//   - Заголовки, метки и заметки к ссылкам
+//   - Правила выбора адреса назначения
+//   - Учет переходов по ссылкам с ограниченным числом переходов
+//   - Окончательное удаление давно удаленных и истекших URL
 type Storage interface {
 	// SaveURL сохраняет пару короткий-длинный URL для указанного пользователя.
-	// Возвращает ошибку, если сохранение не удалось.
+	// Возвращает ErrURLExists, если длинный URL уже сохранен,
+	// и ErrShortIDExists, если короткий идентификатор занят.
 	SaveURL(ctx context.Context, shortID, longURL, userID string) error
 
 	// GetURL возвращает оригинальный URL по его короткому идентификатору.
 	// Возвращает URL, флаг удаления и ошибку.
 	GetURL(ctx context.Context, shortID string) (string, bool, error)
 
+	// GetURLData возвращает данные URL по короткому идентификатору
+	// вместе с владельцем, признаком удаления и сроком действия.
+	// Второе значение false означает, что URL не найден.
+	GetURLData(ctx context.Context, shortID string) (models.URLData, bool, error)
+
 	// Ping проверяет доступность хранилища.
 	// Возвращает ошибку, если хранилище недоступно.
 	Ping(ctx context.Context) error
 
 	// SaveURLBatch сохраняет множество URL в пакетном режиме.
 	// Принимает карту коротких URL к длинным и ID пользователя.
+	// Пакет сохраняется атомарно: при конфликте возвращается ErrURLExists
+	// или ErrShortIDExists, как в SaveURL, и ни один URL не сохраняется.
 	SaveURLBatch(ctx context.Context, urls map[string]string, userID string) error
 
 	// GetShortIDByLongURL ищет короткий идентификатор по длинному URL.
 	// Возвращает пустую строку, если URL не найден.
 	GetShortIDByLongURL(ctx context.Context, longURL string) (string, error)
 
-	// GetUserURLs возвращает все URL, созданные указанным пользователем.
+	// GetUserURLs возвращает все URL, созданные указанным пользователем, в порядке создания.
 	GetUserURLs(ctx context.Context, userID string) ([]models.URLData, error)
 
+	// GetUserURLsPage возвращает страницу URL пользователя.
+	// URL упорядочены по времени создания, при равенстве — по короткому идентификатору.
+	// query.Limit должен быть положительным. Для курсора, выданного
+	// с другой сортировкой или поврежденного, возвращается ErrInvalidCursor.
+	GetUserURLsPage(ctx context.Context, userID string, query models.URLQuery) (models.URLPage, error)
+
 	// MarkURLsAsDeleted помечает указанные URL как удаленные для пользователя.
+	// URL, принадлежащие другим пользователям, не изменяются.
 	MarkURLsAsDeleted(ctx context.Context, shortIDs []string, userID string) error
+
+	// RestoreURLs снимает пометку удаления с URL пользователя и возвращает
+	// идентификаторы восстановленных URL в порядке запроса. Чужие, не удаленные
+	// и окончательно удаленные URL пропускаются.
+	RestoreURLs(ctx context.Context, shortIDs []string, userID string) ([]string, error)
+
+	// UpdateURL меняет адрес назначения URL пользователя и возвращает прежний адрес,
+	// который сохраняется в истории. Индекс длинных URL переносится на новый адрес.
+	// Возвращает ErrURLNotFound, если URL не найден, удален или принадлежит
+	// другому пользователю, и ErrURLExists, если новый длинный URL уже сокращен.
+	// Замена адреса на тот же самый ничего не меняет.
+	UpdateURL(ctx context.Context, shortID, userID, longURL string) (string, error)
+
+	// GetURLHistory возвращает версии адреса назначения URL пользователя
+	// от первой до текущей. Возвращает ErrURLNotFound, если URL не найден
+	// или принадлежит другому пользователю.
+	GetURLHistory(ctx context.Context, shortID, userID string) ([]models.URLVersion, error)
+
+	// SetURLLabels заменяет заголовок, метки и заметку URL пользователя.
+	// Возвращает ErrURLNotFound, если URL не найден, удален или принадлежит
+	// другому пользователю.
+	SetURLLabels(ctx context.Context, shortID, userID string, labels models.URLLabels) error
+
+	// GetUserTags возвращает метки неудаленных URL пользователя с числом URL,
+	// упорядоченные по убыванию числа, при равенстве — по метке.
+	GetUserTags(ctx context.Context, userID string) ([]models.TagCount, error)
+
+	// SetURLRouting заменяет правила выбора адреса назначения URL пользователя,
+	// nil удаляет правила. Возвращает ErrURLNotFound, если URL не найден, удален
+	// или принадлежит другому пользователю.
+	SetURLRouting(ctx context.Context, shortID, userID string, routing *models.Routing) error
+
+	// ConsumeClick атомарно списывает один переход у ссылки с ограничением
+	// числа переходов. Возвращает false, если переходов не осталось,
+	// URL не найден или число переходов не ограничено. Параллельные вызовы
+	// не списывают больше переходов, чем было.
+	ConsumeClick(ctx context.Context, shortID string) (bool, error)
+
+	// IterateURLs вызывает fn для каждого сохраненного URL, включая удаленные,
+	// с заполненными владельцем, признаком удаления и временем создания.
+	// URL каждого пользователя передаются в порядке создания.
+	// Ошибка fn прерывает обход и возвращается без изменений.
+	IterateURLs(ctx context.Context, fn func(url models.URLData) error) error
+
+	// SaveURLData сохраняет URL вместе с владельцем, признаком удаления,
+	// временем создания и сроком действия; нулевое время создания заменяется текущим.
+	// URL добавляются в списки пользователей в порядке следования в urls.
+	// Как и SaveURLBatch, сохраняет все URL атомарно или возвращает ошибку конфликта.
+	SaveURLData(ctx context.Context, urls []models.URLData) error
+
+	// PurgeDeletedURLs окончательно удаляет не более limit URL, помеченных
+	// удаленными или истекших раньше before, и возвращает удаленные URL.
+	// Для URL, удаленных до появления времени удаления, учитывается время создания.
+	// Пакет может оказаться меньше limit, если URL восстановили или продлили
+	// во время очистки, поэтому окончание очистки означает только пустой пакет.
+	PurgeDeletedURLs(ctx context.Context, before time.Time, limit int) ([]models.URLData, error)
+
+	// ReleaseURL окончательно удаляет URL, который к моменту now больше не может
+	// сработать (models.URLData.Defunct), освобождая его длинный URL, и возвращает
+	// удаленный URL. Возвращает ErrURLNotFound, если URL не найден, и ErrURLExists,
+	// если URL еще работает.
+	ReleaseURL(ctx context.Context, shortID string, now time.Time) (models.URLData, error)
 }
 
-// InitStorage инициализирует хранилище в зависимости от конфигурации
+// InitStorage инициализирует хранилище в зависимости от конфигурации.
+// Приоритет: PostgreSQL, встроенная база данных, файл, память.
+// При CacheSize > 0 хранилище оборачивается в CachedStorage.
 func InitStorage(ctx context.Context, cfg *config.Config) (Storage, error) {
+	store, err := initBackend(ctx, cfg)
+	if err != nil || cfg.CacheSize <= 0 {
+		return store, err
+	}
+	return NewCachedStorage(store, cfg.CacheSize, cfg.CacheTTL), nil
+}
+
+// initBackend создает хранилище без кэша
+func initBackend(ctx context.Context, cfg *config.Config) (Storage, error) {
 	if cfg.DatabaseDSN != "" {
 		return NewDatabaseStorage(ctx, cfg.DatabaseDSN)
+	} else if cfg.BoltStoragePath != "" {
+		return NewBoltStorage(ctx, cfg.BoltStoragePath)
 	} else if cfg.FileStoragePath != "" {
-		return NewFileStorage(ctx, cfg.FileStoragePath)
+		return NewFileStorageWithOptions(ctx, cfg.FileStoragePath, FileOptions{
+			CompactThreshold: cfg.FileCompactSize,
+			Sync:             cfg.FileSync,
+		})
 	} else {
 		return NewMemoryStorage(ctx)
 	}
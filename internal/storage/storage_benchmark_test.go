@@ -3,9 +3,13 @@ package storage
 import (
 	"context"
 	"fmt"
+	"sync/atomic"
 	"testing"
 )
 
+// benchmarkShards варианты шардирования для сравнения масштабирования MemoryStorage
+var benchmarkShards = []int{1, DefaultMemoryShards}
+
 // BenchmarkMemoryStorage_SaveURL измеряет производительность сохранения URL
 func BenchmarkMemoryStorage_SaveURL(b *testing.B) {
 	ctx := context.Background()
@@ -82,17 +86,21 @@ func BenchmarkMemoryStorage_BatchOperations(b *testing.B) {
 		b.Fatal(err)
 	}
 
-	urls := make(map[string]string)
-	for i := 0; i < 100; i++ {
-		shortID := fmt.Sprintf("batch%d", i)
-		longURL := fmt.Sprintf("https://example%d.com", i)
-		urls[shortID] = longURL
+	// Пакеты готовятся заранее: повторное сохранение тех же URL вернет ErrURLExists
+	batches := make([]map[string]string, b.N)
+	for i := range batches {
+		batches[i] = make(map[string]string, 100)
+		for j := 0; j < 100; j++ {
+			shortID := fmt.Sprintf("batch%d_%d", i, j)
+			longURL := fmt.Sprintf("https://example%d_%d.com", i, j)
+			batches[i][shortID] = longURL
+		}
 	}
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		userID := fmt.Sprintf("user%d", i)
-		err := store.SaveURLBatch(ctx, urls, userID)
+		err := store.SaveURLBatch(ctx, batches[i], userID)
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -157,3 +165,74 @@ func BenchmarkMemoryStorage_DeleteURLs(b *testing.B) {
 		}
 	}
 }
+
+// BenchmarkMemoryStorage_SaveURLParallel сравнивает конкурентное сохранение
+// с одной блокировкой и с шардированием
+func BenchmarkMemoryStorage_SaveURLParallel(b *testing.B) {
+	ctx := context.Background()
+
+	for _, shards := range benchmarkShards {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			store, err := NewMemoryStorageWithShards(ctx, shards)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			var counter atomic.Int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					i := counter.Add(1)
+					shortID := fmt.Sprintf("bench%d", i)
+					longURL := fmt.Sprintf("https://example%d.com", i)
+					userID := fmt.Sprintf("user%d", i%100)
+
+					if err := store.SaveURL(ctx, shortID, longURL, userID); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkMemoryStorage_MixedParallel сравнивает конкурентную нагрузку
+// из чтений и записей с одной блокировкой и с шардированием
+func BenchmarkMemoryStorage_MixedParallel(b *testing.B) {
+	ctx := context.Background()
+
+	for _, shards := range benchmarkShards {
+		b.Run(fmt.Sprintf("shards=%d", shards), func(b *testing.B) {
+			store, err := NewMemoryStorageWithShards(ctx, shards)
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			// Предварительное заполнение хранилища
+			for i := 0; i < 1000; i++ {
+				err := store.SaveURL(ctx, fmt.Sprintf("read%d", i), fmt.Sprintf("https://read%d.com", i), "reader")
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			var counter atomic.Int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					i := counter.Add(1)
+					if i%10 == 0 {
+						err := store.SaveURL(ctx, fmt.Sprintf("write%d", i), fmt.Sprintf("https://write%d.com", i), "writer")
+						if err != nil {
+							b.Fatal(err)
+						}
+						continue
+					}
+					if _, _, err := store.GetURL(ctx, fmt.Sprintf("read%d", i%1000)); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
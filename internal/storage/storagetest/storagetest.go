@@ -0,0 +1,1013 @@
+// Package storagetest содержит общий набор поведенческих тестов для реализаций storage.Storage.
+//
+// Каждая реализация хранилища должна проходить набор целиком:
+//
+//	func TestMyStorage_Conformance(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.Storage {
+//			return newMyStorage(t)
+//		})
+//	}
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Eorthus/shorturl/internal/models"
+	"github.com/Eorthus/shorturl/internal/storage"
+)
+
+// Factory создает новое пустое хранилище для одного подтеста.
+// Освобождение ресурсов регистрируется через t.Cleanup.
+type Factory func(t *testing.T) storage.Storage
+
+// Run запускает набор поведенческих тестов против хранилищ, созданных factory
+func Run(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, store storage.Storage)
+	}{
+		{"SaveAndGet", testSaveAndGet},
+		{"GetURLData", testGetURLData},
+		{"DuplicateLongURL", testDuplicateLongURL},
+		{"DuplicateShortID", testDuplicateShortID},
+		{"GetShortIDByLongURL", testGetShortIDByLongURL},
+		{"GetUserURLs", testGetUserURLs},
+		{"GetUserURLsMetadata", testGetUserURLsMetadata},
+		{"DeleteOwnURLs", testDeleteOwnURLs},
+		{"DeleteForeignURLs", testDeleteForeignURLs},
+		{"RestoreURLs", testRestoreURLs},
+		{"BatchSave", testBatchSave},
+		{"BatchAtomicity", testBatchAtomicity},
+		{"GetUserURLsPage", testGetUserURLsPage},
+		{"SaveURLData", testSaveURLData},
+		{"IterateURLs", testIterateURLs},
+		{"PurgeDeletedURLs", testPurgeDeletedURLs},
+		{"PurgeDuringUpdate", testPurgeDuringUpdate},
+		{"PurgeDuringRestore", testPurgeDuringRestore},
+		{"ReleaseURL", testReleaseURL},
+		{"ConsumeClick", testConsumeClick},
+		{"UpdateURL", testUpdateURL},
+		{"URLLabels", testURLLabels},
+		{"URLRouting", testURLRouting},
+		{"ContextCancellation", testContextCancellation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, factory(t))
+		})
+	}
+}
+
+func testSaveAndGet(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveURL(ctx, "save1", "https://save.example.com", "user1"))
+
+	longURL, isDeleted, err := store.GetURL(ctx, "save1")
+	assert.NoError(t, err)
+	assert.False(t, isDeleted)
+	assert.Equal(t, "https://save.example.com", longURL)
+
+	longURL, isDeleted, err = store.GetURL(ctx, "missing")
+	assert.NoError(t, err, "Отсутствующий URL не является ошибкой")
+	assert.False(t, isDeleted)
+	assert.Empty(t, longURL)
+
+	assert.NoError(t, store.Ping(ctx))
+}
+
+func testGetURLData(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	notBefore := createdAt.Add(24 * time.Hour)
+	expiresAt := createdAt.Add(48 * time.Hour)
+
+	require.NoError(t, store.SaveURLData(ctx, []models.URLData{
+		{ShortURL: "data1", OriginalURL: "https://data1.example.com", UserID: "user1", CreatedAt: createdAt,
+			NotBefore: &notBefore, ExpiresAt: &expiresAt, PasswordHash: "hash",
+			RedirectCode: 301, Passthrough: true},
+	}))
+	require.NoError(t, store.SaveURL(ctx, "data2", "https://data2.example.com", "user1"))
+
+	url, found, err := store.GetURLData(ctx, "data1")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, "https://data1.example.com", url.OriginalURL)
+	assert.Equal(t, "user1", url.UserID)
+	assert.True(t, createdAt.Equal(url.CreatedAt))
+	require.NotNil(t, url.ExpiresAt, "Срок действия должен сохраняться")
+	assert.True(t, expiresAt.Equal(*url.ExpiresAt))
+	require.NotNil(t, url.NotBefore, "Время активации должно сохраняться")
+	assert.True(t, notBefore.Equal(*url.NotBefore))
+	assert.Equal(t, "hash", url.PasswordHash, "Хеш пароля должен сохраняться")
+	assert.Equal(t, 301, url.RedirectCode, "Код перенаправления должен сохраняться")
+	assert.True(t, url.Passthrough)
+
+	url, found, err = store.GetURLData(ctx, "data2")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Nil(t, url.ExpiresAt)
+	assert.Nil(t, url.NotBefore)
+	assert.Empty(t, url.PasswordHash)
+	assert.Zero(t, url.RedirectCode)
+	assert.False(t, url.Passthrough)
+
+	urls, err := store.GetUserURLs(ctx, "user1")
+	require.NoError(t, err)
+	require.Len(t, urls, 2)
+	require.NotNil(t, urls[0].ExpiresAt, "Срок действия должен попадать в список пользователя")
+
+	_, found, err = store.GetURLData(ctx, "missing")
+	assert.NoError(t, err)
+	assert.False(t, found)
+}
+
+func testDuplicateLongURL(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveURL(ctx, "dup1", "https://dup.example.com", "user1"))
+
+	err := store.SaveURL(ctx, "dup2", "https://dup.example.com", "user2")
+	assert.ErrorIs(t, err, storage.ErrURLExists)
+
+	shortID, err := store.GetShortIDByLongURL(ctx, "https://dup.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "dup1", shortID, "Существующая связь не должна меняться")
+
+	longURL, _, err := store.GetURL(ctx, "dup2")
+	assert.NoError(t, err)
+	assert.Empty(t, longURL, "Дубликат не должен сохраняться")
+}
+
+func testDuplicateShortID(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveURL(ctx, "same", "https://first.example.com", "user1"))
+
+	err := store.SaveURL(ctx, "same", "https://second.example.com", "user1")
+	assert.ErrorIs(t, err, storage.ErrShortIDExists, "Занятый идентификатор отличается от повторного URL")
+
+	err = store.SaveURL(ctx, "same", "https://first.example.com", "user1")
+	assert.ErrorIs(t, err, storage.ErrURLExists, "Повторный длинный URL важнее совпадения идентификатора")
+
+	longURL, _, err := store.GetURL(ctx, "same")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://first.example.com", longURL, "Существующий URL не должен перезаписываться")
+
+	shortID, err := store.GetShortIDByLongURL(ctx, "https://second.example.com")
+	assert.NoError(t, err)
+	assert.Empty(t, shortID)
+}
+
+func testGetShortIDByLongURL(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveURL(ctx, "lookup", "https://lookup.example.com", "user1"))
+
+	shortID, err := store.GetShortIDByLongURL(ctx, "https://lookup.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "lookup", shortID)
+
+	shortID, err = store.GetShortIDByLongURL(ctx, "https://missing.example.com")
+	assert.NoError(t, err)
+	assert.Empty(t, shortID)
+}
+
+func testGetUserURLs(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveURL(ctx, "user1a", "https://user1a.example.com", "user1"))
+	require.NoError(t, store.SaveURL(ctx, "user2a", "https://user2a.example.com", "user2"))
+	require.NoError(t, store.SaveURL(ctx, "user1b", "https://user1b.example.com", "user1"))
+
+	urls, err := store.GetUserURLs(ctx, "user1")
+	assert.NoError(t, err)
+	require.Len(t, urls, 2)
+	assert.Equal(t, "user1a", urls[0].ShortURL, "URL должны возвращаться в порядке создания")
+	assert.Equal(t, "https://user1a.example.com", urls[0].OriginalURL)
+	assert.Equal(t, "user1b", urls[1].ShortURL)
+
+	urls, err = store.GetUserURLs(ctx, "nobody")
+	assert.NoError(t, err)
+	assert.Empty(t, urls)
+}
+
+func testGetUserURLsMetadata(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	expiresAt := createdAt.Add(365 * 24 * time.Hour)
+	notBefore := createdAt.Add(time.Hour)
+	clicks := 3
+
+	require.NoError(t, store.SaveURLData(ctx, []models.URLData{
+		{ShortURL: "meta1", OriginalURL: "https://meta1.example.com", UserID: "user1", CreatedAt: createdAt,
+			ExpiresAt: &expiresAt, NotBefore: &notBefore, ClicksLeft: &clicks, PasswordHash: "hash", RedirectCode: 301,
+			Passthrough: true, Title: "Заголовок", Tags: []string{"promo"}, Note: "Заметка"},
+		{ShortURL: "meta2", OriginalURL: "https://meta2.example.com", UserID: "user1", CreatedAt: createdAt.Add(time.Second)},
+	}))
+	require.NoError(t, store.MarkURLsAsDeleted(ctx, []string{"meta2"}, "user1"))
+
+	urls, err := store.GetUserURLs(ctx, "user1")
+	require.NoError(t, err)
+	require.Len(t, urls, 2)
+
+	url := urls[0]
+	assert.Equal(t, "meta1", url.ShortURL)
+	assert.Equal(t, "user1", url.UserID)
+	assert.True(t, createdAt.Equal(url.CreatedAt))
+	require.NotNil(t, url.ExpiresAt)
+	assert.True(t, expiresAt.Equal(*url.ExpiresAt))
+	require.NotNil(t, url.NotBefore)
+	assert.True(t, notBefore.Equal(*url.NotBefore))
+	require.NotNil(t, url.ClicksLeft)
+	assert.Equal(t, 3, *url.ClicksLeft)
+	assert.Equal(t, "hash", url.PasswordHash)
+	assert.Equal(t, 301, url.RedirectCode)
+	assert.True(t, url.Passthrough)
+	assert.Equal(t, "Заголовок", url.Title)
+	assert.Equal(t, []string{"promo"}, url.Tags)
+	assert.Equal(t, "Заметка", url.Note)
+	assert.False(t, url.IsDeleted)
+
+	assert.Equal(t, "meta2", urls[1].ShortURL)
+	assert.True(t, urls[1].IsDeleted, "Удаленные URL возвращаются с признаком удаления")
+	assert.NotNil(t, urls[1].DeletedAt)
+}
+
+func testDeleteOwnURLs(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveURL(ctx, "del1", "https://del1.example.com", "user1"))
+	require.NoError(t, store.SaveURL(ctx, "del2", "https://del2.example.com", "user1"))
+
+	require.NoError(t, store.MarkURLsAsDeleted(ctx, []string{"del1", "missing"}, "user1"))
+
+	longURL, isDeleted, err := store.GetURL(ctx, "del1")
+	assert.NoError(t, err)
+	assert.True(t, isDeleted)
+	assert.Equal(t, "https://del1.example.com", longURL, "Удаленный URL остается доступным для чтения")
+
+	_, isDeleted, err = store.GetURL(ctx, "del2")
+	assert.NoError(t, err)
+	assert.False(t, isDeleted)
+}
+
+func testDeleteForeignURLs(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveURL(ctx, "owned", "https://owned.example.com", "owner"))
+
+	require.NoError(t, store.MarkURLsAsDeleted(ctx, []string{"owned"}, "intruder"))
+
+	_, isDeleted, err := store.GetURL(ctx, "owned")
+	assert.NoError(t, err)
+	assert.False(t, isDeleted, "Пользователь не может удалить чужой URL")
+}
+
+func testRestoreURLs(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	old := time.Now().UTC().Add(-10 * 24 * time.Hour)
+
+	require.NoError(t, store.SaveURLData(ctx, []models.URLData{
+		{ShortURL: "rest1", OriginalURL: "https://rest1.example.com", UserID: "user1"},
+		{ShortURL: "rest2", OriginalURL: "https://rest2.example.com", UserID: "user1"},
+		{ShortURL: "rest3", OriginalURL: "https://rest3.example.com", UserID: "user2"},
+		{ShortURL: "rest4", OriginalURL: "https://rest4.example.com", UserID: "user1"},
+		{ShortURL: "rest5", OriginalURL: "https://rest5.example.com", UserID: "user1", IsDeleted: true, CreatedAt: old, DeletedAt: &old},
+	}))
+	require.NoError(t, store.MarkURLsAsDeleted(ctx, []string{"rest1", "rest2"}, "user1"))
+	require.NoError(t, store.MarkURLsAsDeleted(ctx, []string{"rest3"}, "user2"))
+	_, err := store.PurgeDeletedURLs(ctx, time.Now().UTC().Add(-24*time.Hour), 10)
+	require.NoError(t, err)
+
+	restored, err := store.RestoreURLs(ctx, []string{"rest2", "rest3", "rest4", "rest5", "missing", "rest1"}, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rest2", "rest1"}, restored,
+		"Чужие, не удаленные и окончательно удаленные URL не восстанавливаются")
+
+	url, found, err := store.GetURLData(ctx, "rest1")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.False(t, url.IsDeleted)
+	assert.Nil(t, url.DeletedAt)
+	assert.Equal(t, "https://rest1.example.com", url.OriginalURL)
+
+	_, isDeleted, err := store.GetURL(ctx, "rest3")
+	require.NoError(t, err)
+	assert.True(t, isDeleted, "Пользователь не может восстановить чужой URL")
+
+	longURL, _, err := store.GetURL(ctx, "rest5")
+	require.NoError(t, err)
+	assert.Empty(t, longURL)
+
+	restored, err = store.RestoreURLs(ctx, []string{"rest1"}, "user1")
+	require.NoError(t, err)
+	assert.Empty(t, restored, "Повторное восстановление ничего не меняет")
+
+	shortID, err := store.GetShortIDByLongURL(ctx, "https://rest2.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "rest2", shortID)
+}
+
+func testBatchSave(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	urls := map[string]string{
+		"batch1": "https://batch1.example.com",
+		"batch2": "https://batch2.example.com",
+	}
+	require.NoError(t, store.SaveURLBatch(ctx, urls, "user1"))
+
+	for shortID, longURL := range urls {
+		result, isDeleted, err := store.GetURL(ctx, shortID)
+		assert.NoError(t, err)
+		assert.False(t, isDeleted)
+		assert.Equal(t, longURL, result)
+	}
+
+	userURLs, err := store.GetUserURLs(ctx, "user1")
+	assert.NoError(t, err)
+	assert.Len(t, userURLs, len(urls))
+}
+
+func testBatchAtomicity(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveURL(ctx, "taken", "https://taken.example.com", "user1"))
+
+	t.Run("Existing long URL", func(t *testing.T) {
+		err := store.SaveURLBatch(ctx, map[string]string{
+			"fresh1": "https://fresh1.example.com",
+			"fresh2": "https://taken.example.com",
+		}, "user2")
+		assert.ErrorIs(t, err, storage.ErrURLExists)
+
+		longURL, _, err := store.GetURL(ctx, "fresh1")
+		assert.NoError(t, err)
+		assert.Empty(t, longURL, "Пакет с конфликтом не должен сохраняться частично")
+	})
+
+	t.Run("Existing short ID", func(t *testing.T) {
+		err := store.SaveURLBatch(ctx, map[string]string{
+			"fresh3": "https://fresh3.example.com",
+			"taken":  "https://fresh4.example.com",
+		}, "user2")
+		assert.ErrorIs(t, err, storage.ErrShortIDExists)
+
+		longURL, _, err := store.GetURL(ctx, "fresh3")
+		assert.NoError(t, err)
+		assert.Empty(t, longURL, "Пакет с конфликтом не должен сохраняться частично")
+	})
+
+	t.Run("Duplicate inside batch", func(t *testing.T) {
+		err := store.SaveURLBatch(ctx, map[string]string{
+			"fresh5": "https://same.example.com",
+			"fresh6": "https://same.example.com",
+		}, "user2")
+		assert.ErrorIs(t, err, storage.ErrURLExists)
+
+		shortID, err := store.GetShortIDByLongURL(ctx, "https://same.example.com")
+		assert.NoError(t, err)
+		assert.Empty(t, shortID, "Пакет с конфликтом не должен сохраняться частично")
+	})
+
+	urls, err := store.GetUserURLs(ctx, "user2")
+	assert.NoError(t, err)
+	assert.Empty(t, urls)
+}
+
+func testGetUserURLsPage(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// page3 и page4 созданы одновременно и упорядочиваются по короткому идентификатору
+	require.NoError(t, store.SaveURLData(ctx, []models.URLData{
+		{ShortURL: "page1", OriginalURL: "https://one.example.com", UserID: "user1", CreatedAt: createdAt},
+		{ShortURL: "page2", OriginalURL: "https://two.example.com", UserID: "user1", CreatedAt: createdAt.Add(time.Second)},
+		{ShortURL: "page3", OriginalURL: "https://THREE.example.com", UserID: "user1", CreatedAt: createdAt.Add(2 * time.Second)},
+		{ShortURL: "page4", OriginalURL: "https://four.example.com", UserID: "user1", CreatedAt: createdAt.Add(2 * time.Second)},
+		{ShortURL: "other", OriginalURL: "https://other.example.com", UserID: "user2", CreatedAt: createdAt},
+	}))
+	require.NoError(t, store.MarkURLsAsDeleted(ctx, []string{"page2"}, "user1"))
+
+	collect := func(t *testing.T, query models.URLQuery) []string {
+		var shortIDs []string
+		for range 10 {
+			page, err := store.GetUserURLsPage(ctx, "user1", query)
+			require.NoError(t, err)
+			require.LessOrEqual(t, len(page.URLs), query.Limit)
+			for _, url := range page.URLs {
+				shortIDs = append(shortIDs, url.ShortURL)
+			}
+			if page.NextCursor == "" {
+				return shortIDs
+			}
+			query.Cursor = page.NextCursor
+		}
+		t.Fatal("Пагинация не завершилась")
+		return nil
+	}
+
+	t.Run("По возрастанию", func(t *testing.T) {
+		assert.Equal(t, []string{"page1", "page2", "page3", "page4"},
+			collect(t, models.URLQuery{Limit: 3, Sort: models.SortCreatedAsc}))
+	})
+
+	t.Run("По убыванию", func(t *testing.T) {
+		assert.Equal(t, []string{"page4", "page3", "page2", "page1"},
+			collect(t, models.URLQuery{Limit: 2, Sort: models.SortCreatedDesc}))
+	})
+
+	t.Run("Точная последняя страница", func(t *testing.T) {
+		page, err := store.GetUserURLsPage(ctx, "user1", models.URLQuery{Limit: 4, Sort: models.SortCreatedAsc})
+		require.NoError(t, err)
+		assert.Len(t, page.URLs, 4)
+		assert.Empty(t, page.NextCursor, "Без следующих URL курсор не выдается")
+	})
+
+	t.Run("Фильтр удаленных", func(t *testing.T) {
+		deleted, active := true, false
+		assert.Equal(t, []string{"page2"},
+			collect(t, models.URLQuery{Limit: 1, Sort: models.SortCreatedAsc, Deleted: &deleted}))
+		assert.Equal(t, []string{"page1", "page3", "page4"},
+			collect(t, models.URLQuery{Limit: 1, Sort: models.SortCreatedAsc, Deleted: &active}))
+	})
+
+	t.Run("Поиск без учета регистра", func(t *testing.T) {
+		assert.Equal(t, []string{"page3"},
+			collect(t, models.URLQuery{Limit: 10, Sort: models.SortCreatedAsc, Search: "three"}))
+		assert.Empty(t, collect(t, models.URLQuery{Limit: 10, Sort: models.SortCreatedAsc, Search: "%"}))
+	})
+
+	t.Run("Метаданные", func(t *testing.T) {
+		page, err := store.GetUserURLsPage(ctx, "user1", models.URLQuery{Limit: 2, Sort: models.SortCreatedAsc})
+		require.NoError(t, err)
+		require.Len(t, page.URLs, 2)
+		assert.Equal(t, "https://one.example.com", page.URLs[0].OriginalURL)
+		assert.True(t, createdAt.Equal(page.URLs[0].CreatedAt))
+		assert.True(t, page.URLs[1].IsDeleted)
+	})
+
+	t.Run("Некорректный курсор", func(t *testing.T) {
+		_, err := store.GetUserURLsPage(ctx, "user1", models.URLQuery{Limit: 1, Cursor: "!!!", Sort: models.SortCreatedAsc})
+		assert.ErrorIs(t, err, storage.ErrInvalidCursor)
+
+		page, err := store.GetUserURLsPage(ctx, "user1", models.URLQuery{Limit: 1, Sort: models.SortCreatedAsc})
+		require.NoError(t, err)
+		_, err = store.GetUserURLsPage(ctx, "user1", models.URLQuery{Limit: 1, Cursor: page.NextCursor, Sort: models.SortCreatedDesc})
+		assert.ErrorIs(t, err, storage.ErrInvalidCursor, "Курсор привязан к сортировке")
+	})
+}
+
+func testSaveURLData(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	require.NoError(t, store.SaveURLData(ctx, []models.URLData{
+		{ShortURL: "imp1", OriginalURL: "https://imp1.example.com", UserID: "user1", CreatedAt: createdAt},
+		{ShortURL: "imp2", OriginalURL: "https://imp2.example.com", UserID: "user1", IsDeleted: true, CreatedAt: createdAt.Add(time.Minute)},
+	}))
+
+	_, isDeleted, err := store.GetURL(ctx, "imp2")
+	assert.NoError(t, err)
+	assert.True(t, isDeleted, "Признак удаления должен сохраняться")
+
+	urls, err := store.GetUserURLs(ctx, "user1")
+	assert.NoError(t, err)
+	require.Len(t, urls, 2)
+	assert.Equal(t, "imp1", urls[0].ShortURL)
+	assert.Equal(t, "imp2", urls[1].ShortURL)
+
+	require.NoError(t, store.MarkURLsAsDeleted(ctx, []string{"imp1"}, "user1"))
+	_, isDeleted, err = store.GetURL(ctx, "imp1")
+	assert.NoError(t, err)
+	assert.True(t, isDeleted, "Владелец должен сохраняться")
+
+	err = store.SaveURLData(ctx, []models.URLData{
+		{ShortURL: "imp3", OriginalURL: "https://imp3.example.com", UserID: "user2"},
+		{ShortURL: "imp1", OriginalURL: "https://imp4.example.com", UserID: "user2"},
+	})
+	assert.ErrorIs(t, err, storage.ErrShortIDExists)
+
+	longURL, _, err := store.GetURL(ctx, "imp3")
+	assert.NoError(t, err)
+	assert.Empty(t, longURL, "Пакет с конфликтом не должен сохраняться частично")
+}
+
+func testIterateURLs(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveURL(ctx, "iter1", "https://iter1.example.com", "user1"))
+	require.NoError(t, store.SaveURL(ctx, "iter2", "https://iter2.example.com", "user2"))
+	require.NoError(t, store.SaveURL(ctx, "iter3", "https://iter3.example.com", "user1"))
+	require.NoError(t, store.MarkURLsAsDeleted(ctx, []string{"iter3"}, "user1"))
+
+	byUser := make(map[string][]models.URLData)
+	err := store.IterateURLs(ctx, func(url models.URLData) error {
+		byUser[url.UserID] = append(byUser[url.UserID], url)
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.Len(t, byUser["user1"], 2)
+	require.Len(t, byUser["user2"], 1)
+	assert.Equal(t, "iter1", byUser["user1"][0].ShortURL, "URL пользователя должны идти в порядке создания")
+	assert.Equal(t, "https://iter1.example.com", byUser["user1"][0].OriginalURL)
+	assert.False(t, byUser["user1"][0].CreatedAt.IsZero())
+	assert.Equal(t, "iter3", byUser["user1"][1].ShortURL)
+	assert.True(t, byUser["user1"][1].IsDeleted, "Удаленные URL тоже обходятся")
+
+	errStop := errors.New("stop")
+	calls := 0
+	err = store.IterateURLs(ctx, func(models.URLData) error {
+		calls++
+		return errStop
+	})
+	assert.ErrorIs(t, err, errStop)
+	assert.Equal(t, 1, calls, "Ошибка должна прерывать обход")
+}
+
+func testPurgeDeletedURLs(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	old := now.Add(-10 * 24 * time.Hour)
+
+	require.NoError(t, store.SaveURLData(ctx, []models.URLData{
+		{ShortURL: "purge1", OriginalURL: "https://purge1.example.com", UserID: "user1", IsDeleted: true, CreatedAt: old, DeletedAt: &old},
+		{ShortURL: "purge2", OriginalURL: "https://purge2.example.com", UserID: "user1", IsDeleted: true, CreatedAt: old, DeletedAt: &old},
+		// Удален без сохраненного времени удаления, учитывается время создания
+		{ShortURL: "purge3", OriginalURL: "https://purge3.example.com", UserID: "user2", IsDeleted: true, CreatedAt: old},
+		{ShortURL: "keep1", OriginalURL: "https://keep1.example.com", UserID: "user1", CreatedAt: old},
+		{ShortURL: "keep2", OriginalURL: "https://keep2.example.com", UserID: "user1", CreatedAt: old},
+		// Истек давно, удаляется вместе с удаленными
+		{ShortURL: "purge5", OriginalURL: "https://purge5.example.com", UserID: "user2", CreatedAt: old, ExpiresAt: &old},
+		// Истек недавно, еще доступен владельцу в списке
+		{ShortURL: "keep3", OriginalURL: "https://keep3.example.com", UserID: "user2", CreatedAt: old, ExpiresAt: &now},
+	}))
+	require.NoError(t, store.MarkURLsAsDeleted(ctx, []string{"keep2"}, "user1"))
+
+	before := now.Add(-24 * time.Hour)
+	total := 0
+	for {
+		purged, err := store.PurgeDeletedURLs(ctx, before, 2)
+		require.NoError(t, err)
+		total += len(purged)
+		if len(purged) == 0 {
+			break
+		}
+	}
+	assert.Equal(t, 4, total)
+
+	for _, shortID := range []string{"purge1", "purge2", "purge3", "purge5"} {
+		longURL, _, err := store.GetURL(ctx, shortID)
+		assert.NoError(t, err)
+		assert.Empty(t, longURL, "URL %s должен быть удален окончательно", shortID)
+	}
+	shortID, err := store.GetShortIDByLongURL(ctx, "https://purge1.example.com")
+	assert.NoError(t, err)
+	assert.Empty(t, shortID, "Длинный URL должен удаляться из индекса")
+
+	urls, err := store.GetUserURLs(ctx, "user1")
+	require.NoError(t, err)
+	require.Len(t, urls, 2)
+	assert.Equal(t, "keep1", urls[0].ShortURL)
+	assert.Equal(t, "keep2", urls[1].ShortURL, "Недавно удаленный URL должен сохраняться")
+
+	urls, err = store.GetUserURLs(ctx, "user2")
+	require.NoError(t, err)
+	require.Len(t, urls, 1)
+	assert.Equal(t, "keep3", urls[0].ShortURL)
+
+	assert.NoError(t, store.SaveURL(ctx, "purge4", "https://purge1.example.com", "user1"),
+		"Длинный URL после окончательного удаления можно сократить заново")
+}
+
+// testPurgeDuringUpdate проверяет индекс длинных URL, когда адрес истекшей
+// ссылки меняется параллельно с ее окончательным удалением, а в индекс
+// записываются новые ссылки; гонки ловит -race
+func testPurgeDuringUpdate(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	const links, updates = 100, 20
+	old := time.Now().UTC().Add(-10 * 24 * time.Hour)
+
+	urls := make([]models.URLData, 0, links)
+	for i := range links {
+		urls = append(urls, models.URLData{
+			ShortURL:    fmt.Sprintf("race%d", i),
+			OriginalURL: fmt.Sprintf("https://race%d.example.com/v0", i),
+			UserID:      "user1",
+			CreatedAt:   old,
+			ExpiresAt:   &old,
+		})
+	}
+	require.NoError(t, store.SaveURLData(ctx, urls))
+
+	var updaters, others sync.WaitGroup
+	for i := range links {
+		updaters.Add(1)
+		go func() {
+			defer updaters.Done()
+			shortID := fmt.Sprintf("race%d", i)
+			for v := 1; v <= updates; v++ {
+				_, err := store.UpdateURL(ctx, shortID, "user1", fmt.Sprintf("https://race%d.example.com/v%d", i, v))
+				if errors.Is(err, storage.ErrURLNotFound) {
+					return
+				}
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	done := make(chan struct{})
+	others.Add(2)
+	go func() {
+		defer others.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			_, err := store.PurgeDeletedURLs(ctx, time.Now().UTC(), 3)
+			assert.NoError(t, err)
+		}
+	}()
+	go func() {
+		defer others.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			assert.NoError(t, store.SaveURL(ctx, fmt.Sprintf("fill%d", i), fmt.Sprintf("https://fill%d.example.com", i), "user2"))
+		}
+	}()
+	updaters.Wait()
+	close(done)
+	others.Wait()
+
+	_, err := store.PurgeDeletedURLs(ctx, time.Now().UTC(), links)
+	require.NoError(t, err)
+	for i := range links {
+		longURL, _, err := store.GetURL(ctx, fmt.Sprintf("race%d", i))
+		require.NoError(t, err)
+		assert.Empty(t, longURL, "Истекшая ссылка должна быть удалена")
+		for v := 0; v <= updates; v++ {
+			shortID, err := store.GetShortIDByLongURL(ctx, fmt.Sprintf("https://race%d.example.com/v%d", i, v))
+			require.NoError(t, err)
+			assert.Empty(t, shortID, "Индекс не должен ссылаться на удаленную запись")
+		}
+	}
+}
+
+func testPurgeDuringRestore(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	const links = 100
+
+	shortIDs := make([]string, links)
+	for i := range links {
+		shortIDs[i] = fmt.Sprintf("undo%d", i)
+		require.NoError(t, store.SaveURL(ctx, shortIDs[i], fmt.Sprintf("https://undo%d.example.com", i), "user1"))
+	}
+	require.NoError(t, store.MarkURLsAsDeleted(ctx, shortIDs, "user1"))
+	before := time.Now().UTC().Add(time.Hour)
+
+	restored := make(map[string]bool)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, shortID := range shortIDs {
+			ids, err := store.RestoreURLs(ctx, []string{shortID}, "user1")
+			assert.NoError(t, err)
+			for _, id := range ids {
+				restored[id] = true
+			}
+		}
+	}()
+	purged := make(map[string]bool)
+	for {
+		batch, err := store.PurgeDeletedURLs(ctx, before, 3)
+		require.NoError(t, err)
+		if len(batch) == 0 {
+			break
+		}
+		for _, url := range batch {
+			purged[url.ShortURL] = true
+		}
+	}
+	wg.Wait()
+	_, err := store.PurgeDeletedURLs(ctx, before, links)
+	require.NoError(t, err)
+
+	for _, shortID := range shortIDs {
+		url, found, err := store.GetURLData(ctx, shortID)
+		require.NoError(t, err)
+		if restored[shortID] {
+			assert.False(t, purged[shortID], "Восстановленная ссылка %s не должна удаляться", shortID)
+			require.True(t, found, shortID)
+			assert.False(t, url.IsDeleted)
+		} else {
+			assert.False(t, found, "Невосстановленная ссылка %s должна быть удалена", shortID)
+		}
+	}
+}
+
+func testReleaseURL(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	now := time.Now().UTC()
+	expiresAt := now.Add(time.Hour)
+	require.NoError(t, store.SaveURLData(ctx, []models.URLData{
+		{ShortURL: "ending", OriginalURL: "https://ending.example.com", UserID: "user1", ExpiresAt: &expiresAt},
+		{ShortURL: "forever", OriginalURL: "https://forever.example.com", UserID: "user1"},
+	}))
+
+	_, err := store.ReleaseURL(ctx, "ending", now)
+	assert.ErrorIs(t, err, storage.ErrURLExists, "Работающая ссылка не удаляется")
+	_, err = store.ReleaseURL(ctx, "forever", expiresAt.Add(time.Hour))
+	assert.ErrorIs(t, err, storage.ErrURLExists)
+	_, err = store.ReleaseURL(ctx, "missing", now)
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	released, err := store.ReleaseURL(ctx, "ending", expiresAt)
+	require.NoError(t, err)
+	assert.Equal(t, "ending", released.ShortURL)
+	assert.Equal(t, "https://ending.example.com", released.OriginalURL)
+
+	_, found, err := store.GetURLData(ctx, "ending")
+	require.NoError(t, err)
+	assert.False(t, found)
+	shortID, err := store.GetShortIDByLongURL(ctx, "https://ending.example.com")
+	require.NoError(t, err)
+	assert.Empty(t, shortID)
+	urls, err := store.GetUserURLs(ctx, "user1")
+	require.NoError(t, err)
+	require.Len(t, urls, 1)
+	assert.Equal(t, "forever", urls[0].ShortURL)
+
+	require.NoError(t, store.SaveURL(ctx, "renewed", "https://ending.example.com", "user2"), "Адрес освобожден")
+
+	clicks := 1
+	require.NoError(t, store.SaveURLData(ctx, []models.URLData{
+		{ShortURL: "once", OriginalURL: "https://once.example.com", UserID: "user1", ClicksLeft: &clicks},
+	}))
+	_, err = store.ReleaseURL(ctx, "once", now)
+	assert.ErrorIs(t, err, storage.ErrURLExists, "Переходы еще есть")
+	consumed, err := store.ConsumeClick(ctx, "once")
+	require.NoError(t, err)
+	require.True(t, consumed)
+	released, err = store.ReleaseURL(ctx, "once", now)
+	require.NoError(t, err, "Исчерпанные переходы освобождают адрес")
+	assert.Equal(t, "https://once.example.com", released.OriginalURL)
+}
+
+func testConsumeClick(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+	const limit = 5
+	clicks := limit
+
+	require.NoError(t, store.SaveURLData(ctx, []models.URLData{
+		{ShortURL: "click1", OriginalURL: "https://click1.example.com", UserID: "user1", ClicksLeft: &clicks},
+	}))
+	require.NoError(t, store.SaveURL(ctx, "click2", "https://click2.example.com", "user1"))
+
+	var wg sync.WaitGroup
+	var consumed atomic.Int32
+	for range 4 * limit {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := store.ConsumeClick(ctx, "click1")
+			assert.NoError(t, err)
+			if ok {
+				consumed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(limit), consumed.Load(), "Параллельные переходы не должны превышать лимит")
+	assert.Equal(t, limit, clicks, "Сохраненный счетчик не должен меняться у вызывающего")
+
+	url, found, err := store.GetURLData(ctx, "click1")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.NotNil(t, url.ClicksLeft)
+	assert.Equal(t, 0, *url.ClicksLeft)
+
+	ok, err := store.ConsumeClick(ctx, "click2")
+	assert.NoError(t, err)
+	assert.False(t, ok, "Ссылка без ограничения не списывает переходы")
+	url, _, err = store.GetURLData(ctx, "click2")
+	require.NoError(t, err)
+	assert.Nil(t, url.ClicksLeft)
+
+	ok, err = store.ConsumeClick(ctx, "missing")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func testUpdateURL(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveURL(ctx, "edit1", "https://v1.example.com", "user1"))
+	require.NoError(t, store.SaveURL(ctx, "edit2", "https://taken.example.com", "user1"))
+
+	_, err := store.UpdateURL(ctx, "edit1", "user2", "https://v2.example.com")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound, "Чужой URL изменять нельзя")
+	_, err = store.UpdateURL(ctx, "missing", "user1", "https://v2.example.com")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+	_, err = store.UpdateURL(ctx, "edit1", "user1", "https://taken.example.com")
+	assert.ErrorIs(t, err, storage.ErrURLExists)
+
+	previous, err := store.UpdateURL(ctx, "edit1", "user1", "https://v2.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "https://v1.example.com", previous)
+	_, err = store.UpdateURL(ctx, "edit1", "user1", "https://v3.example.com")
+	require.NoError(t, err)
+	previous, err = store.UpdateURL(ctx, "edit1", "user1", "https://v3.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "https://v3.example.com", previous, "Тот же адрес не создает новую версию")
+
+	longURL, _, err := store.GetURL(ctx, "edit1")
+	require.NoError(t, err)
+	assert.Equal(t, "https://v3.example.com", longURL)
+
+	// Индекс длинных URL указывает только на текущий адрес
+	shortID, err := store.GetShortIDByLongURL(ctx, "https://v3.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "edit1", shortID)
+	shortID, err = store.GetShortIDByLongURL(ctx, "https://v1.example.com")
+	require.NoError(t, err)
+	assert.Empty(t, shortID)
+	require.NoError(t, store.SaveURL(ctx, "edit3", "https://v1.example.com", "user2"), "Прежний адрес можно сократить заново")
+
+	versions, err := store.GetURLHistory(ctx, "edit1", "user1")
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	for i, expected := range []string{"https://v1.example.com", "https://v2.example.com", "https://v3.example.com"} {
+		assert.Equal(t, i+1, versions[i].Version)
+		assert.Equal(t, expected, versions[i].OriginalURL)
+	}
+	require.NotNil(t, versions[0].ActiveUntil)
+	assert.True(t, versions[0].ActiveUntil.Equal(versions[1].ActiveFrom))
+	assert.Nil(t, versions[2].ActiveUntil, "Текущая версия не заменена")
+
+	_, err = store.GetURLHistory(ctx, "edit1", "user2")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound)
+
+	versions, err = store.GetURLHistory(ctx, "edit2", "user1")
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	assert.Equal(t, "https://taken.example.com", versions[0].OriginalURL)
+
+	require.NoError(t, store.MarkURLsAsDeleted(ctx, []string{"edit2"}, "user1"))
+	_, err = store.UpdateURL(ctx, "edit2", "user1", "https://v4.example.com")
+	assert.ErrorIs(t, err, storage.ErrURLNotFound, "Удаленный URL изменять нельзя")
+}
+
+func testURLLabels(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	require.NoError(t, store.SaveURLData(ctx, []models.URLData{
+		{ShortURL: "tag1", OriginalURL: "https://tag1.example.com", UserID: "user1", Title: "Весна",
+			Tags: []string{"promo", "spring"}, Note: "Весенняя акция"},
+		{ShortURL: "tag2", OriginalURL: "https://tag2.example.com", UserID: "user1", Tags: []string{"promo"}},
+		{ShortURL: "tag3", OriginalURL: "https://tag3.example.com", UserID: "user1"},
+		{ShortURL: "tag4", OriginalURL: "https://tag4.example.com", UserID: "user2", Tags: []string{"promo"}},
+	}))
+
+	url, _, err := store.GetURLData(ctx, "tag1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"promo", "spring"}, url.Tags)
+	assert.Equal(t, "Весенняя акция", url.Note)
+	assert.Equal(t, "Весна", url.Title)
+
+	assert.ErrorIs(t, store.SetURLLabels(ctx, "tag1", "user2", models.URLLabels{Tags: []string{"stolen"}}), storage.ErrURLNotFound,
+		"Чужой URL изменять нельзя")
+	assert.ErrorIs(t, store.SetURLLabels(ctx, "missing", "user1", models.URLLabels{}), storage.ErrURLNotFound)
+
+	require.NoError(t, store.SetURLLabels(ctx, "tag3", "user1", models.URLLabels{Title: "Новости", Tags: []string{"spring", "news"}, Note: "Заметка"}))
+	require.NoError(t, store.SetURLLabels(ctx, "tag2", "user1", models.URLLabels{}))
+	url, _, err = store.GetURLData(ctx, "tag3")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"spring", "news"}, url.Tags)
+	assert.Equal(t, "Заметка", url.Note)
+	assert.Equal(t, "Новости", url.Title)
+	url, _, err = store.GetURLData(ctx, "tag2")
+	require.NoError(t, err)
+	assert.Empty(t, url.Tags, "Пустой список удаляет метки")
+	assert.Empty(t, url.Note)
+
+	page, err := store.GetUserURLsPage(ctx, "user1", models.URLQuery{Limit: 10, Tag: "spring"})
+	require.NoError(t, err)
+	require.Len(t, page.URLs, 2)
+	assert.Equal(t, "tag1", page.URLs[0].ShortURL)
+	assert.Equal(t, "tag3", page.URLs[1].ShortURL)
+
+	tags, err := store.GetUserTags(ctx, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, []models.TagCount{{Tag: "spring", Count: 2}, {Tag: "news", Count: 1}, {Tag: "promo", Count: 1}}, tags)
+
+	require.NoError(t, store.MarkURLsAsDeleted(ctx, []string{"tag1"}, "user1"))
+	assert.ErrorIs(t, store.SetURLLabels(ctx, "tag1", "user1", models.URLLabels{}), storage.ErrURLNotFound, "Удаленный URL изменять нельзя")
+	tags, err = store.GetUserTags(ctx, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, []models.TagCount{{Tag: "news", Count: 1}, {Tag: "spring", Count: 1}}, tags, "Удаленные URL не учитываются")
+
+	tags, err = store.GetUserTags(ctx, "nobody")
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+}
+
+func testURLRouting(t *testing.T, store storage.Storage) {
+	ctx := context.Background()
+
+	routing := &models.Routing{
+		Rules:    []models.RoutingRule{{Platform: "ios", Language: "ru", URL: "https://apps.example.com"}},
+		Variants: []models.RoutingVariant{{Name: "a", URL: "https://a.example.com", Weight: 2}, {Name: "b", URL: "https://b.example.com", Weight: 1}},
+	}
+	require.NoError(t, store.SaveURLData(ctx, []models.URLData{
+		{ShortURL: "route1", OriginalURL: "https://route1.example.com", UserID: "user1", Routing: routing},
+	}))
+	require.NoError(t, store.SaveURL(ctx, "route2", "https://route2.example.com", "user1"))
+
+	// Изменение переданных правил не должно менять сохраненные
+	routing.Variants[0].Weight = 100
+
+	url, _, err := store.GetURLData(ctx, "route1")
+	require.NoError(t, err)
+	require.NotNil(t, url.Routing)
+	assert.Equal(t, []models.RoutingRule{{Platform: "ios", Language: "ru", URL: "https://apps.example.com"}}, url.Routing.Rules)
+	assert.Equal(t, []models.RoutingVariant{{Name: "a", URL: "https://a.example.com", Weight: 2}, {Name: "b", URL: "https://b.example.com", Weight: 1}},
+		url.Routing.Variants)
+
+	url, _, err = store.GetURLData(ctx, "route2")
+	require.NoError(t, err)
+	assert.Nil(t, url.Routing)
+
+	assert.ErrorIs(t, store.SetURLRouting(ctx, "route2", "user2", routing), storage.ErrURLNotFound, "Чужой URL изменять нельзя")
+	assert.ErrorIs(t, store.SetURLRouting(ctx, "missing", "user1", routing), storage.ErrURLNotFound)
+
+	variants := &models.Routing{Variants: []models.RoutingVariant{{Name: "new", URL: "https://new.example.com", Weight: 1}}}
+	require.NoError(t, store.SetURLRouting(ctx, "route2", "user1", variants))
+	url, _, err = store.GetURLData(ctx, "route2")
+	require.NoError(t, err)
+	assert.Equal(t, variants, url.Routing)
+
+	require.NoError(t, store.SetURLRouting(ctx, "route1", "user1", nil))
+	url, _, err = store.GetURLData(ctx, "route1")
+	require.NoError(t, err)
+	assert.Nil(t, url.Routing, "nil удаляет правила")
+
+	require.NoError(t, store.MarkURLsAsDeleted(ctx, []string{"route2"}, "user1"))
+	assert.ErrorIs(t, store.SetURLRouting(ctx, "route2", "user1", nil), storage.ErrURLNotFound, "Удаленный URL изменять нельзя")
+}
+
+func testContextCancellation(t *testing.T, store storage.Storage) {
+	require.NoError(t, store.SaveURL(context.Background(), "ctx1", "https://ctx1.example.com", "user1"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.Error(t, store.SaveURL(ctx, "ctx2", "https://ctx2.example.com", "user1"))
+	assert.Error(t, store.SaveURLBatch(ctx, map[string]string{"ctx3": "https://ctx3.example.com"}, "user1"))
+	assert.Error(t, store.MarkURLsAsDeleted(ctx, []string{"ctx1"}, "user1"))
+	assert.Error(t, store.Ping(ctx))
+
+	_, _, err := store.GetURL(ctx, "ctx1")
+	assert.Error(t, err)
+	_, _, err = store.GetURLData(ctx, "ctx1")
+	assert.Error(t, err)
+	_, err = store.ConsumeClick(ctx, "ctx1")
+	assert.Error(t, err)
+	_, err = store.GetShortIDByLongURL(ctx, "https://ctx1.example.com")
+	assert.Error(t, err)
+	_, err = store.GetUserURLs(ctx, "user1")
+	assert.Error(t, err)
+	_, err = store.GetUserURLsPage(ctx, "user1", models.URLQuery{Limit: 1})
+	assert.Error(t, err)
+	assert.Error(t, store.SaveURLData(ctx, []models.URLData{{ShortURL: "ctx4", OriginalURL: "https://ctx4.example.com"}}))
+	assert.Error(t, store.IterateURLs(ctx, func(models.URLData) error { return nil }))
+	_, err = store.PurgeDeletedURLs(ctx, time.Now().Add(time.Hour), 10)
+	assert.Error(t, err)
+
+	background := context.Background()
+	for _, shortID := range []string{"ctx2", "ctx3", "ctx4"} {
+		longURL, _, err := store.GetURL(background, shortID)
+		assert.NoError(t, err)
+		assert.Empty(t, longURL, "Операция с отмененным контекстом не должна сохранять данные")
+	}
+	_, isDeleted, err := store.GetURL(background, "ctx1")
+	assert.NoError(t, err)
+	assert.False(t, isDeleted, "Операция с отмененным контекстом не должна удалять данные")
+}
@@ -0,0 +1,65 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Параметры хеширования паролей PBKDF2-HMAC-SHA256
+const (
+	passwordScheme     = "pbkdf2-sha256"
+	passwordIterations = 100_000
+	passwordSaltLength = 16
+	passwordKeyLength  = 32
+	// maxPasswordIterations защищает от импортированных хешей с огромной стоимостью проверки
+	maxPasswordIterations = 10_000_000
+)
+
+// HashPassword возвращает соленый хеш пароля в формате
+// "pbkdf2-sha256$<итерации>$<соль>$<ключ>" (соль и ключ в base64).
+// Алгоритм и число итераций хранятся в хеше, поэтому после смены
+// параметров старые хеши по-прежнему проверяются.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("failed to generate salt: %w", err)
+	}
+	key := pbkdf2.Key([]byte(password), salt, passwordIterations, passwordKeyLength, sha256.New)
+	return strings.Join([]string{
+		passwordScheme,
+		strconv.Itoa(passwordIterations),
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	}, "$"), nil
+}
+
+// CheckPassword сравнивает пароль с хешем, полученным HashPassword.
+// Поврежденный хеш не совпадает ни с одним паролем.
+func CheckPassword(hash, password string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 4 || parts[0] != passwordScheme {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 || iterations > maxPasswordIterations {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(key) == 0 {
+		return false
+	}
+
+	actual := pbkdf2.Key([]byte(password), salt, iterations, len(key), sha256.New)
+	return subtle.ConstantTimeCompare(actual, key) == 1
+}
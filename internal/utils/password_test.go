@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPassword_Iterations(t *testing.T) {
+	// Тестовые векторы PBKDF2-HMAC-SHA256 из RFC 7914: число итераций берется из хеша
+	tests := []struct {
+		password, salt string
+		iterations     int
+		expected       string
+	}{
+		{"passwd", "salt", 1, "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+		{"Password", "NaCl", 80000, "4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d"},
+	}
+
+	for _, tt := range tests {
+		key, err := hex.DecodeString(tt.expected)
+		require.NoError(t, err)
+		hash := strings.Join([]string{
+			passwordScheme,
+			strconv.Itoa(tt.iterations),
+			base64.RawStdEncoding.EncodeToString([]byte(tt.salt)),
+			base64.RawStdEncoding.EncodeToString(key),
+		}, "$")
+		assert.True(t, CheckPassword(hash, tt.password), hash)
+		assert.False(t, CheckPassword(hash, tt.password+"!"), hash)
+	}
+}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := HashPassword("secret")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, passwordScheme+"$"))
+	assert.NotContains(t, hash, "secret")
+
+	assert.True(t, CheckPassword(hash, "secret"))
+	assert.False(t, CheckPassword(hash, "Secret"))
+	assert.False(t, CheckPassword(hash, ""))
+
+	other, err := HashPassword("secret")
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, other, "Соль должна быть случайной")
+
+	for _, broken := range []string{"", "secret", "md5$1$c2FsdA$a2V5", "pbkdf2-sha256$0$c2FsdA$a2V5", "pbkdf2-sha256$1$!$a2V5"} {
+		assert.False(t, CheckPassword(broken, "secret"), broken)
+	}
+}
@@ -14,6 +14,8 @@ import (
 )
 
 // GenerateShortID генерирует короткий идентификатор для URL.
+//
+// Deprecated: сервис использует генераторы из пакета idgen.
 func GenerateShortID() string {
 	b := make([]byte, 6)
 	rand.Read(b)